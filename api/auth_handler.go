@@ -0,0 +1,188 @@
+package api
+
+import (
+    "net/http"
+    "subsmanager/config"
+    "subsmanager/internal/services"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// LoginRequest 登录请求
+type LoginRequest struct {
+    Username string `json:"username" binding:"required"`
+    Password string `json:"password" binding:"required"`
+}
+
+// Login 管理员登录，成功后设置会话Cookie
+func Login(c *gin.Context) {
+    var req LoginRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: "Invalid request parameters",
+        })
+        return
+    }
+    if !config.Get().Auth.Enabled {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: "Authentication is disabled",
+        })
+        return
+    }
+
+    sessionID, expiresAt, err := services.DefaultAuthService.Login(req.Username, req.Password)
+    if err != nil {
+        c.JSON(http.StatusUnauthorized, Response{
+            Code:    401,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    setSessionCookie(c, sessionID, int(time.Until(expiresAt).Seconds()))
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data: gin.H{
+            "username":   req.Username,
+            "expires_at": expiresAt,
+        },
+    })
+}
+
+// Logout 注销当前会话
+func Logout(c *gin.Context) {
+    if id, err := c.Cookie(services.SessionCookieName); err == nil && id != "" {
+        services.DefaultAuthService.Logout(id)
+    }
+    setSessionCookie(c, "", -1)
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+    })
+}
+
+// GetCurrentUser 获取当前访问者信息
+func GetCurrentUser(c *gin.Context) {
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data:    currentPrincipal(c),
+    })
+}
+
+// ChangePasswordRequest 修改密码请求
+type ChangePasswordRequest struct {
+    OldPassword string `json:"old_password" binding:"required"`
+    NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword 修改管理员密码，修改后所有会话需要重新登录
+func ChangePassword(c *gin.Context) {
+    var req ChangePasswordRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: "Invalid request parameters",
+        })
+        return
+    }
+
+    if err := services.DefaultAuthService.ChangePassword(req.OldPassword, req.NewPassword); err != nil {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    setSessionCookie(c, "", -1)
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+    })
+}
+
+// setSessionCookie 设置会话Cookie，maxAge小于0时删除Cookie
+func setSessionCookie(c *gin.Context, value string, maxAge int) {
+    c.SetSameSite(http.SameSiteLaxMode)
+    c.SetCookie(services.SessionCookieName, value, maxAge, "/", "", c.Request.TLS != nil, true)
+}
+
+// CreateTokenRequest 创建API令牌请求
+type CreateTokenRequest struct {
+    Name      string `json:"name" binding:"required"`
+    Scope     string `json:"scope" binding:"required,oneof=read manage"` // 权限范围：read/manage
+    ExpiresIn string `json:"expires_in"`                                 // 有效期，如 720h，为空表示永不过期
+}
+
+// ListTokens 获取API令牌列表，不包含令牌明文
+func ListTokens(c *gin.Context) {
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data:    services.DefaultAuthService.ListTokens(),
+    })
+}
+
+// CreateToken 创建API令牌，令牌明文只在本次响应中返回
+func CreateToken(c *gin.Context) {
+    var req CreateTokenRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: "Invalid request parameters",
+        })
+        return
+    }
+
+    var ttl time.Duration
+    if req.ExpiresIn != "" {
+        d, err := time.ParseDuration(req.ExpiresIn)
+        if err != nil || d <= 0 {
+            c.JSON(http.StatusBadRequest, Response{
+                Code:    400,
+                Message: "Invalid expires_in",
+            })
+            return
+        }
+        ttl = d
+    }
+
+    token, raw, err := services.DefaultAuthService.CreateToken(req.Name, req.Scope, ttl)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, Response{
+            Code:    500,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data: gin.H{
+            "token": raw,
+            "info":  token,
+        },
+    })
+}
+
+// DeleteToken 吊销API令牌
+func DeleteToken(c *gin.Context) {
+    if err := services.DefaultAuthService.DeleteToken(c.Param("id")); err != nil {
+        c.JSON(http.StatusNotFound, Response{
+            Code:    404,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+    })
+}
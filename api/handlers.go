@@ -1,9 +1,16 @@
 package api
 
 import (
+    "context"
+    "errors"
     "net/http"
+    "net/url"
+    "strings"
+    "subsmanager/config"
     "subsmanager/internal/models"
     "subsmanager/internal/services"
+    "subsmanager/internal/utils"
+    "time"
 
     "github.com/gin-gonic/gin"
 )
@@ -17,22 +24,61 @@ type Response struct {
 
 // ImportSubscriptionRequest 导入订阅请求
 type ImportSubscriptionRequest struct {
-    Name string `json:"name" binding:"required"`
-    URL  string `json:"url" binding:"required,url"`
+    Name    string   `json:"name" binding:"required"`
+    URL     string   `json:"url" binding:"omitempty,url"` // 订阅链接
+    Content string   `json:"content"`                     // 订阅内容（Clash配置、分享链接等），与url二选一
+    Tags    []string `json:"tags"`                        // 订阅标签，同步到订阅的节点
 }
 
 // ImportSubscription 导入订阅
+//
+// 提供url时从订阅链接获取；提供content或以multipart/form-data上传文件（字段name、file、tags）时创建本地订阅。
 func ImportSubscription(c *gin.Context) {
     var req ImportSubscriptionRequest
-    if err := c.ShouldBindJSON(&req); err != nil {
+    var content []byte
+    if isMultipart(c) {
+        data, uploadErr := readUpload(c, "file")
+        if uploadErr != nil {
+            c.JSON(uploadErr.status, Response{
+                Code:    uploadErr.status,
+                Message: uploadErr.Error(),
+            })
+            return
+        }
+        req.Name, req.Tags, content = c.PostForm("name"), splitTags(c.PostFormArray("tags")), data
+    } else {
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, Response{
+                Code:    400,
+                Message: "Invalid request parameters",
+            })
+            return
+        }
+        content = []byte(req.Content)
+    }
+    if req.Name == "" || (req.URL == "") == (len(content) == 0) {
         c.JSON(http.StatusBadRequest, Response{
             Code:    400,
-            Message: "Invalid request parameters",
+            Message: "name and exactly one of url, content or file are required",
         })
         return
     }
 
-    sub, err := services.DefaultSubscriptionService.ImportSubscription(req.Name, req.URL)
+    importSub := func() (*models.Subscription, error) {
+        if req.URL != "" {
+            return services.DefaultSubscriptionService.ImportSubscription(req.Name, req.URL, req.Tags)
+        }
+        return services.DefaultSubscriptionService.ImportSubscriptionContent(req.Name, content, req.Tags)
+    }
+
+    if isAsync(c) {
+        respondJob(c, services.DefaultJobManager.Start(models.JobTypeImport, "", func(ctx context.Context, h *services.JobHandle) (interface{}, error) {
+            return importSub()
+        }))
+        return
+    }
+
+    sub, err := importSub()
     if err != nil {
         c.JSON(http.StatusInternalServerError, Response{
             Code:    500,
@@ -48,9 +94,9 @@ func ImportSubscription(c *gin.Context) {
     })
 }
 
-// GetSubscriptions 获取所有订阅
+// GetSubscriptions 获取订阅，可按 ?tag= 筛选带有任一标签的订阅，多个标签可重复参数或用逗号分隔
 func GetSubscriptions(c *gin.Context) {
-    subs := services.DefaultSubscriptionService.GetSubscriptions()
+    subs := services.DefaultSubscriptionService.GetSubscriptionsByTags(splitTags(c.QueryArray("tag")))
     c.JSON(http.StatusOK, Response{
         Code:    200,
         Message: "Success",
@@ -58,10 +104,116 @@ func GetSubscriptions(c *gin.Context) {
     })
 }
 
-// DeleteSubscription 删除订阅
+// splitTags 拆分逗号分隔的标签参数
+func splitTags(values []string) []string {
+    var tags []string
+    for _, v := range values {
+        for _, tag := range strings.Split(v, ",") {
+            if tag = strings.TrimSpace(tag); tag != "" {
+                tags = append(tags, tag)
+            }
+        }
+    }
+    return tags
+}
+
+// UpdateSubscription 编辑订阅，修改订阅链接时重新获取订阅
+func UpdateSubscription(c *gin.Context) {
+    var req models.SubscriptionUpdate
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: "Invalid request parameters",
+        })
+        return
+    }
+
+    id := c.Param("id")
+    if _, err := services.DefaultSubscriptionService.GetSubscription(id); err != nil {
+        c.JSON(http.StatusNotFound, Response{
+            Code:    404,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    sub, err := services.DefaultSubscriptionService.UpdateSubscription(id, req)
+    if err != nil {
+        // 获取新订阅链接失败属于上游错误
+        status := http.StatusBadRequest
+        if errors.Is(err, services.ErrFetchSubscription) {
+            status = http.StatusBadGateway
+        }
+        c.JSON(status, Response{
+            Code:    status,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data:    sub,
+    })
+}
+
+// BulkSubscriptions 批量操作订阅，返回每个订阅的执行结果
+func BulkSubscriptions(c *gin.Context) {
+    var req models.SubscriptionBulkRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: "Invalid request parameters",
+        })
+        return
+    }
+    if req.Action == models.BulkActionTag && len(req.AddTags) == 0 && len(req.RemoveTags) == 0 {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: "add_tags or remove_tags is required",
+        })
+        return
+    }
+
+    report, err := services.DefaultSubscriptionService.BulkUpdateSubscriptions(req)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, Response{
+            Code:    500,
+            Message: err.Error(),
+        })
+        return
+    }
+    if !report.Applied {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: "bulk operation failed, no changes applied",
+            Data:    report,
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data:    report,
+    })
+}
+
+// DeleteSubscription 删除订阅及其节点，keep_nodes=true 时保留节点
 func DeleteSubscription(c *gin.Context) {
     id := c.Param("id")
-    if err := services.DefaultSubscriptionService.DeleteSubscription(id); err != nil {
+    if _, err := services.DefaultSubscriptionService.GetSubscription(id); err != nil {
+        c.JSON(http.StatusNotFound, Response{
+            Code:    404,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    keepNodes := c.Query("keep_nodes") == "true"
+    report, err := services.DefaultSubscriptionService.DeleteSubscription(id, keepNodes)
+    if err != nil {
         c.JSON(http.StatusInternalServerError, Response{
             Code:    500,
             Message: err.Error(),
@@ -72,12 +224,16 @@ func DeleteSubscription(c *gin.Context) {
     c.JSON(http.StatusOK, Response{
         Code:    200,
         Message: "Success",
+        Data:    report,
     })
 }
 
 // MergeSubscriptionsRequest 合并订阅请求
 type MergeSubscriptionsRequest struct {
-    IDs []string `json:"ids" binding:"required,min=1"`
+    IDs           []string             `json:"ids"`            // 订阅ID，与tags至少指定一项
+    Tags          []string             `json:"tags"`           // 订阅标签，合并带有任一标签的节点
+    DedupStrategy string               `json:"dedup_strategy"` // 去重策略，为空时使用配置默认值
+    Rename        models.RenameOptions `json:"rename"`         // 节点重命名选项
 }
 
 // MergeSubscriptions 合并订阅
@@ -91,7 +247,34 @@ func MergeSubscriptions(c *gin.Context) {
         return
     }
 
-    result, err := services.DefaultSubscriptionService.MergeSubscriptions(req.IDs)
+    if len(req.IDs) == 0 && len(req.Tags) == 0 {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: "ids or tags is required",
+        })
+        return
+    }
+
+    base := publicBaseURL(c)
+    merge := func() (*models.MergeResult, error) {
+        result, err := services.DefaultSubscriptionService.MergeSubscriptions(req.IDs, req.Tags, dedupStrategy(req.DedupStrategy), req.Rename)
+        if err != nil {
+            return nil, err
+        }
+        if result.FileURL, err = subscriptionURL(base, models.OutputMerge, result.FileName); err != nil {
+            return nil, err
+        }
+        return result, nil
+    }
+
+    if isAsync(c) {
+        respondJob(c, services.DefaultJobManager.Start(models.JobTypeMerge, "", func(ctx context.Context, h *services.JobHandle) (interface{}, error) {
+            return merge()
+        }))
+        return
+    }
+
+    result, err := merge()
     if err != nil {
         c.JSON(http.StatusInternalServerError, Response{
             Code:    500,
@@ -107,20 +290,55 @@ func MergeSubscriptions(c *gin.Context) {
     })
 }
 
-// TestNodesRequest 节点测试请求
+// TestNodesRequest 节点测试请求，未填写的参数使用配置文件中的默认值
 type TestNodesRequest struct {
-    MaxLatency int     `json:"max_latency" binding:"required"`
-    TestURL    string  `json:"test_url" binding:"required"`
-    Timeout    int     `json:"timeout" binding:"required"`
-    Concurrent int     `json:"concurrent" binding:"required"`
+    MaxLatency      int    `json:"max_latency"`      // 进入下载测试的延迟阈值(ms)
+    TestURL         string `json:"test_url"`
+    Timeout         int    `json:"timeout"`
+    Concurrent      int    `json:"concurrent"`       // 延迟测试并发数
+    SpeedConcurrent int    `json:"speed_concurrent"` // 下载测试并发数
+    MaxBytes        string `json:"max_bytes"`        // 单次下载的字节上限，如 10mb
+    RunBudget       string `json:"run_budget"`       // 本轮测速的本机下载流量预算，如 1gb
 }
 
 // TestNodes 测试节点速度
 func TestNodes(c *gin.Context) {
     var req TestNodesRequest
-    if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-        return
+    if c.Request.ContentLength > 0 {
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+    }
+
+    // 填充默认值
+    defaults := services.DefaultSpeedTestConfig()
+    if req.MaxLatency == 0 {
+        req.MaxLatency = defaults.MaxLatency
+    }
+    if req.Timeout == 0 {
+        req.Timeout = defaults.Timeout
+    }
+    if req.Concurrent == 0 {
+        req.Concurrent = defaults.Concurrent
+    }
+    if req.SpeedConcurrent == 0 {
+        req.SpeedConcurrent = defaults.SpeedConcurrent
+    }
+
+    maxBytes, runBudget := defaults.MaxBytes, defaults.RunBudget
+    var err error
+    if req.MaxBytes != "" {
+        if maxBytes, err = config.ParseByteSize(req.MaxBytes); err != nil || maxBytes <= 0 {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid max_bytes"})
+            return
+        }
+    }
+    if req.RunBudget != "" {
+        if runBudget, err = config.ParseByteSize(req.RunBudget); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid run_budget"})
+            return
+        }
     }
 
     // 验证参数
@@ -132,37 +350,47 @@ func TestNodes(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": "timeout must be greater than 0"})
         return
     }
-    if req.Concurrent <= 0 {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "concurrent must be greater than 0"})
+    if req.Concurrent <= 0 || req.SpeedConcurrent <= 0 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "concurrent and speed_concurrent must be greater than 0"})
         return
     }
-    if req.TestURL == "" {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "test_url is required"})
-        return
+    // 未指定下载测试URL时使用管理的测速目标
+    if req.TestURL != "" {
+        if u, err := url.Parse(req.TestURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "test_url must be an http or https url"})
+            return
+        }
     }
 
     // 创建测试配置
-    config := models.SpeedTestConfig{
-        MaxLatency: req.MaxLatency,
-        TestURL:    req.TestURL,
-        Timeout:    req.Timeout,
-        Concurrent: req.Concurrent,
+    testConfig := models.SpeedTestConfig{
+        MaxLatency:      req.MaxLatency,
+        TestURL:         req.TestURL,
+        Timeout:         req.Timeout,
+        Concurrent:      req.Concurrent,
+        SpeedConcurrent: req.SpeedConcurrent,
+        MaxBytes:        maxBytes,
+        RunBudget:       runBudget,
     }
 
-    // 执行节点测试
-    result, err := services.GetSubscriptionService().TestNodes(config)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-        return
-    }
-
-    c.JSON(http.StatusOK, result)
+    // 在后台执行节点测试，通过 /api/jobs/:id 查询进度
+    respondJob(c, services.DefaultSubscriptionService.StartTestJob(testConfig))
 }
 
 // FilterNodesRequest 筛选节点请求
 type FilterNodesRequest struct {
-    MaxLatency       int     `json:"max_latency" binding:"required,min=0"`
-    MinDownloadSpeed float64 `json:"min_download_speed" binding:"required,min=0"`
+    models.NodeQuery
+    MaxLatency       int     `json:"max_latency" binding:"min=0"`
+    MinDownloadSpeed float64 `json:"min_download_speed" binding:"min=0"`
+}
+
+// filterCondition 转换为筛选条件
+func (r FilterNodesRequest) filterCondition() models.FilterCondition {
+    return models.FilterCondition{
+        NodeQuery:        r.NodeQuery,
+        MaxLatency:       r.MaxLatency,
+        MinDownloadSpeed: r.MinDownloadSpeed,
+    }
 }
 
 // FilterNodes 筛选节点
@@ -176,15 +404,10 @@ func FilterNodes(c *gin.Context) {
         return
     }
 
-    condition := models.FilterCondition{
-        MaxLatency:       req.MaxLatency,
-        MinDownloadSpeed: req.MinDownloadSpeed,
-    }
-
-    nodes, err := services.DefaultSubscriptionService.FilterNodes(condition)
+    nodes, err := services.DefaultSubscriptionService.FilterNodes(req.filterCondition())
     if err != nil {
-        c.JSON(http.StatusInternalServerError, Response{
-            Code:    500,
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
             Message: err.Error(),
         })
         return
@@ -197,32 +420,122 @@ func FilterNodes(c *gin.Context) {
     })
 }
 
+// GenerateSubscriptionRequest 生成订阅请求
+type GenerateSubscriptionRequest struct {
+    FilterNodesRequest
+    Rename models.RenameOptions `json:"rename"` // 节点重命名选项
+}
+
 // GenerateSubscription 生成订阅
 func GenerateSubscription(c *gin.Context) {
-    // TODO: 实现生成订阅的处理逻辑
+    // 请求体可选，为空时使用全部节点
+    var req GenerateSubscriptionRequest
+    if c.Request.ContentLength > 0 {
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, Response{
+                Code:    400,
+                Message: "Invalid request parameters",
+            })
+            return
+        }
+    }
+
+    nodes, err := services.DefaultSubscriptionService.FilterNodes(req.filterCondition())
+    if err != nil {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    base := publicBaseURL(c)
+    generate := func() (*models.GenerateResult, error) {
+        fileName, err := services.DefaultSubscriptionService.GenerateSubscription(nodes, req.Rename)
+        if err != nil {
+            return nil, err
+        }
+
+        fileURL, err := subscriptionURL(base, fileName, fileName)
+        if err != nil {
+            return nil, err
+        }
+
+        result := &models.GenerateResult{
+            FileName:     fileName,
+            FileURL:      fileURL,
+            NodeCount:    len(nodes),
+            GenerateTime: time.Now(),
+        }
+        utils.LogSubscriptionGenerate(result.FileURL)
+        return result, nil
+    }
+
+    if isAsync(c) {
+        respondJob(c, services.DefaultJobManager.Start(models.JobTypeGenerate, "", func(ctx context.Context, h *services.JobHandle) (interface{}, error) {
+            return generate()
+        }))
+        return
+    }
+
+    result, err := generate()
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, Response{
+            Code:    500,
+            Message: err.Error(),
+        })
+        return
+    }
+
     c.JSON(http.StatusOK, Response{
         Code:    200,
         Message: "Success",
+        Data:    result,
     })
 }
 
-// ImportNodesRequest 导入节点请求
+// ImportNodesRequest 按路径导入节点请求
 type ImportNodesRequest struct {
-    FilePath string `json:"file_path" binding:"required"`
+    FilePath      string `json:"file_path" binding:"required"` // 导入目录中的相对路径
+    DedupStrategy string `json:"dedup_strategy"` // 去重策略，为空时使用配置默认值
+}
+
+// dedupStrategy 获取去重策略，未指定时使用配置默认值
+func dedupStrategy(strategy string) string {
+    if strategy == "" {
+        return config.Get().Subscription.DedupStrategy
+    }
+    return strategy
 }
 
 // ImportNodes 导入节点
+//
+// multipart/form-data 请求上传文件（字段file，可选字段dedup_strategy）；
+// JSON 请求按 file_path 读取导入目录中的文件。
 func ImportNodes(c *gin.Context) {
-    var req ImportNodesRequest
-    if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, Response{
-            Code:    400,
-            Message: "Invalid request parameters",
-        })
-        return
+    var result *models.ImportResult
+    var err error
+    if isMultipart(c) {
+        data, uploadErr := readUpload(c, "file")
+        if uploadErr != nil {
+            c.JSON(uploadErr.status, Response{
+                Code:    uploadErr.status,
+                Message: uploadErr.Error(),
+            })
+            return
+        }
+        result, err = services.DefaultSubscriptionService.ImportNodesFromContent(data, dedupStrategy(c.PostForm("dedup_strategy")))
+    } else {
+        var req ImportNodesRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, Response{
+                Code:    400,
+                Message: "Invalid request parameters",
+            })
+            return
+        }
+        result, err = services.DefaultSubscriptionService.ImportNodesFromFile(req.FilePath, dedupStrategy(req.DedupStrategy))
     }
-
-    result, err := services.DefaultSubscriptionService.ImportNodesFromFile(req.FilePath)
     if err != nil {
         c.JSON(http.StatusInternalServerError, Response{
             Code:    500,
@@ -251,8 +564,8 @@ func GetNodeList(c *gin.Context) {
 
     result, err := services.DefaultSubscriptionService.GetNodeList(query)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, Response{
-            Code:    500,
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
             Message: err.Error(),
         })
         return
@@ -263,4 +576,13 @@ func GetNodeList(c *gin.Context) {
         Message: "Success",
         Data:    result,
     })
-} 
\ No newline at end of file
+} 
+
+// GetNodeRegions 获取各地区节点数
+func GetNodeRegions(c *gin.Context) {
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data:    services.DefaultSubscriptionService.GetRegionStats(),
+    })
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+    "io"
+    "net/http"
+    "subsmanager/internal/models"
+    "subsmanager/internal/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+// ListJobs 获取后台任务列表，支持按类型和状态筛选
+func ListJobs(c *gin.Context) {
+    var query models.JobQuery
+    if err := c.ShouldBindQuery(&query); err != nil {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: "Invalid query parameters",
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data:    services.DefaultJobManager.List(query),
+    })
+}
+
+// GetJob 获取后台任务状态、进度和部分结果
+func GetJob(c *gin.Context) {
+    job, err := services.DefaultJobManager.Get(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, Response{
+            Code:    404,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data:    job,
+    })
+}
+
+// CancelJob 取消后台任务
+func CancelJob(c *gin.Context) {
+    if err := services.DefaultJobManager.Cancel(c.Param("id")); err != nil {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    job, _ := services.DefaultJobManager.Get(c.Param("id"))
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Job cancellation requested",
+        Data:    job,
+    })
+}
+
+// StreamJobEvents 以Server-Sent Events推送后台任务事件
+//
+// 连接建立后先推送一次job事件作为当前快照，之后推送status/progress/node事件，任务结束时推送done事件并关闭连接。
+func StreamJobEvents(c *gin.Context) {
+    job, events, cancel, err := services.DefaultJobManager.Subscribe(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, Response{
+            Code:    404,
+            Message: err.Error(),
+        })
+        return
+    }
+    defer cancel()
+
+    c.Header("Cache-Control", "no-cache")
+    c.Header("Connection", "keep-alive")
+    c.Header("X-Accel-Buffering", "no")
+
+    c.SSEvent("job", job)
+    if job.Finished() {
+        c.SSEvent(services.JobEventDone, job)
+        return
+    }
+
+    c.Stream(func(w io.Writer) bool {
+        select {
+        case event, ok := <-events:
+            if !ok {
+                return false
+            }
+            c.SSEvent(event.Event, event.Data)
+            return event.Event != services.JobEventDone
+        case <-c.Request.Context().Done():
+            return false
+        }
+    })
+}
+
+// isAsync 请求是否要求以后台任务执行（?async=true）
+func isAsync(c *gin.Context) bool {
+    return c.Query("async") == "true"
+}
+
+// respondJob 返回已提交的后台任务，客户端通过 /api/jobs/:id 查询结果
+func respondJob(c *gin.Context, job *models.Job) {
+    c.JSON(http.StatusAccepted, Response{
+        Code:    202,
+        Message: "Job submitted",
+        Data:    job,
+    })
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+    "fmt"
+    "net"
+    "net/http"
+    "strings"
+    "subsmanager/config"
+    "subsmanager/internal/models"
+    "subsmanager/internal/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+// 上下文中保存已认证访问者的键
+const principalKey = "principal"
+
+// CORS 只允许配置的来源跨域访问管理API
+func CORS() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if origin := c.GetHeader("Origin"); origin != "" && originAllowed(origin) {
+            c.Header("Access-Control-Allow-Origin", origin)
+            c.Header("Access-Control-Allow-Credentials", "true")
+            c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+            c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+            c.Header("Vary", "Origin")
+        }
+        if c.Request.Method == http.MethodOptions {
+            c.AbortWithStatus(http.StatusNoContent)
+            return
+        }
+        c.Next()
+    }
+}
+
+// originAllowed 来源是否在允许跨域的列表中
+func originAllowed(origin string) bool {
+    for _, allowed := range config.Get().Auth.AllowedOrigins {
+        if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+            return true
+        }
+    }
+    return false
+}
+
+// AuthRequired 校验会话Cookie或Bearer令牌
+//
+// 查询接口（GET）需要read权限，其他接口需要manage权限；未启用认证时不做校验。
+func AuthRequired() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if !config.Get().Auth.Enabled {
+            c.Set(principalKey, &models.Principal{Scope: models.ScopeManage})
+            c.Next()
+            return
+        }
+
+        principal, err := authenticate(c)
+        if err != nil {
+            c.AbortWithStatusJSON(http.StatusUnauthorized, Response{
+                Code:    401,
+                Message: err.Error(),
+            })
+            return
+        }
+
+        scope := models.ScopeManage
+        if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+            scope = models.ScopeRead
+        }
+        if !principal.Allows(scope) {
+            c.AbortWithStatusJSON(http.StatusForbidden, Response{
+                Code:    403,
+                Message: fmt.Sprintf("token scope %q does not allow this operation", principal.Scope),
+            })
+            return
+        }
+
+        c.Set(principalKey, principal)
+        c.Next()
+    }
+}
+
+// LocalOrAuthRequired 本机发起的请求直接放行，其他来源需要通过认证
+//
+// 用于内置测速端点：下载测试由本机发起，远程访问必须携带令牌，
+// 未启用认证时拒绝远程访问，避免端点被当作公开的流量源。
+func LocalOrAuthRequired() gin.HandlerFunc {
+    auth := AuthRequired()
+    return func(c *gin.Context) {
+        if isLocalRequest(c) {
+            c.Next()
+            return
+        }
+        if !config.Get().Auth.Enabled {
+            c.AbortWithStatusJSON(http.StatusForbidden, Response{
+                Code:    403,
+                Message: "this endpoint only accepts local requests when auth is disabled",
+            })
+            return
+        }
+        auth(c)
+    }
+}
+
+// isLocalRequest 判断请求是否来自本机，即回环地址或服务监听的地址
+//
+// 使用连接的对端地址，不信任 X-Forwarded-For 等可伪造的请求头。
+func isLocalRequest(c *gin.Context) bool {
+    ip := net.ParseIP(c.RemoteIP())
+    if ip == nil {
+        return false
+    }
+    return ip.IsLoopback() || ip.Equal(net.ParseIP(config.Get().Server.Host))
+}
+
+// SessionRequired 只允许通过登录会话访问，用于令牌管理等不应由API令牌自行调用的接口
+func SessionRequired() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if p := currentPrincipal(c); p == nil || p.ViaToken() {
+            c.AbortWithStatusJSON(http.StatusForbidden, Response{
+                Code:    403,
+                Message: "this operation requires a login session",
+            })
+            return
+        }
+        c.Next()
+    }
+}
+
+// authenticate 按Authorization头或会话Cookie识别访问者
+func authenticate(c *gin.Context) (*models.Principal, error) {
+    if header := c.GetHeader("Authorization"); header != "" {
+        raw := strings.TrimPrefix(header, "Bearer ")
+        if raw == header || raw == "" {
+            return nil, fmt.Errorf("invalid authorization header")
+        }
+        return services.DefaultAuthService.VerifyToken(raw)
+    }
+
+    if id, err := c.Cookie(services.SessionCookieName); err == nil && id != "" {
+        return services.DefaultAuthService.VerifySession(id)
+    }
+    return nil, fmt.Errorf("authentication required")
+}
+
+// currentPrincipal 获取当前请求的访问者
+func currentPrincipal(c *gin.Context) *models.Principal {
+    if v, ok := c.Get(principalKey); ok {
+        if p, ok := v.(*models.Principal); ok {
+            return p
+        }
+    }
+    return nil
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+    "fmt"
+    "net/http"
+    "strings"
+    "subsmanager/config"
+    "subsmanager/internal/models"
+    "subsmanager/internal/services"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// DownloadSubscription 通过发布链接下载订阅文件，无需登录
+func DownloadSubscription(c *gin.Context) {
+    path, status := services.DefaultPublishService.Resolve(c.Param("token"), c.ClientIP(), c.GetHeader("User-Agent"))
+    switch status {
+    case http.StatusOK:
+    case http.StatusForbidden:
+        c.JSON(http.StatusForbidden, Response{
+            Code:    403,
+            Message: "Subscription link revoked or expired",
+        })
+        return
+    default:
+        c.JSON(http.StatusNotFound, Response{
+            Code:    404,
+            Message: "Subscription not found",
+        })
+        return
+    }
+
+    c.Header("Content-Type", "text/yaml; charset=utf-8")
+    c.Header("Cache-Control", "no-store")
+    c.File(path)
+}
+
+// subscriptionURL 获取输出的默认下载地址
+func subscriptionURL(base, output, fileName string) (string, error) {
+    link, err := services.DefaultPublishService.Publish(output, fileName)
+    if err != nil {
+        return "", err
+    }
+    return linkURL(base, link.Token), nil
+}
+
+// linkURL 下载令牌对应的订阅地址
+func linkURL(base, token string) string {
+    return fmt.Sprintf("%s/sub/%s", base, token)
+}
+
+// publicBaseURL 获取对外访问地址，优先使用配置的 server.public_url，
+// 否则按TLS连接或反向代理的 X-Forwarded-Proto 确定协议
+func publicBaseURL(c *gin.Context) string {
+    if u := config.Get().Server.PublicURL; u != "" {
+        return strings.TrimSuffix(u, "/")
+    }
+
+    scheme := "http"
+    if c.Request.TLS != nil {
+        scheme = "https"
+    }
+    if proto := strings.ToLower(strings.TrimSpace(strings.Split(c.GetHeader("X-Forwarded-Proto"), ",")[0])); proto == "http" || proto == "https" {
+        scheme = proto
+    }
+    return scheme + "://" + c.Request.Host
+}
+
+// PublishedLinkResponse 发布链接及其订阅地址
+type PublishedLinkResponse struct {
+    *models.PublishedLink
+    URL string `json:"url,omitempty"` // 只读权限的访问者不返回
+}
+
+// ListPublishedLinks 获取发布链接，可按 ?file_name= 筛选
+//
+// 下载令牌可直接获取订阅内容，只读权限的访问者看不到令牌和下载地址。
+func ListPublishedLinks(c *gin.Context) {
+    links := services.DefaultPublishService.ListLinks(c.Query("file_name"))
+    redact := !currentPrincipal(c).Allows(models.ScopeManage)
+    base := publicBaseURL(c)
+    result := make([]*PublishedLinkResponse, 0, len(links))
+    for _, link := range links {
+        if redact {
+            link.Token = ""
+            result = append(result, &PublishedLinkResponse{PublishedLink: link})
+            continue
+        }
+        result = append(result, &PublishedLinkResponse{PublishedLink: link, URL: linkURL(base, link.Token)})
+    }
+
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data:    result,
+    })
+}
+
+// CreatePublishedLinkRequest 创建发布链接请求
+type CreatePublishedLinkRequest struct {
+    FileName  string `json:"file_name" binding:"required"` // 订阅文件名
+    Consumer  string `json:"consumer" binding:"required"`  // 使用方名称
+    ExpiresIn string `json:"expires_in"`                   // 有效期，如 720h，为空表示永不过期
+}
+
+// CreatePublishedLink 为使用方创建单独的订阅下载链接
+func CreatePublishedLink(c *gin.Context) {
+    var req CreatePublishedLinkRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: "Invalid request parameters",
+        })
+        return
+    }
+
+    var ttl time.Duration
+    if req.ExpiresIn != "" {
+        d, err := time.ParseDuration(req.ExpiresIn)
+        if err != nil || d <= 0 {
+            c.JSON(http.StatusBadRequest, Response{
+                Code:    400,
+                Message: "Invalid expires_in",
+            })
+            return
+        }
+        ttl = d
+    }
+
+    link, err := services.DefaultPublishService.CreateLink(req.FileName, req.Consumer, ttl)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data:    &PublishedLinkResponse{PublishedLink: link, URL: linkURL(publicBaseURL(c), link.Token)},
+    })
+}
+
+// RevokePublishedLink 吊销发布链接
+func RevokePublishedLink(c *gin.Context) {
+    link, err := services.DefaultPublishService.RevokeLink(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data:    link,
+    })
+}
+
+// GetPublishAccessLog 获取发布链接的访问记录，可按 ?link_id= 筛选
+func GetPublishAccessLog(c *gin.Context) {
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data:    services.DefaultPublishService.AccessLog(c.Query("link_id")),
+    })
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+    "subsmanager/config"
+
     "github.com/gin-gonic/gin"
 )
 
@@ -8,37 +10,80 @@ import (
 func SetupRouter() *gin.Engine {
     r := gin.Default()
 
-    // 允许跨域
-    r.Use(func(c *gin.Context) {
-        c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-        c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-        c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-        if c.Request.Method == "OPTIONS" {
-            c.AbortWithStatus(204)
-            return
-        }
-        c.Next()
-    })
+    // 只允许配置的来源跨域
+    r.Use(CORS())
+
+    // 登录和订阅下载无需认证
+    r.POST("/api/auth/login", Login)
+    r.GET("/sub/:token", DownloadSubscription)
 
     // API路由组
-    api := r.Group("/api")
+    api := r.Group("/api", AuthRequired())
     {
+        // 认证
+        api.POST("/auth/logout", Logout)
+        api.GET("/auth/me", GetCurrentUser)
+        api.PUT("/auth/password", SessionRequired(), ChangePassword)
+
+        // API令牌只能通过登录会话管理，避免泄露的令牌自行续期或提权
+        tokens := api.Group("/tokens", SessionRequired())
+        {
+            tokens.GET("", ListTokens)
+            tokens.POST("", CreateToken)
+            tokens.DELETE("/:id", DeleteToken)
+        }
+
+        // 订阅发布链接
+        api.GET("/published", ListPublishedLinks)
+        api.POST("/published", CreatePublishedLink)
+        api.DELETE("/published/:id", RevokePublishedLink)
+        api.GET("/published/access", GetPublishAccessLog)
+
         // 订阅管理
         api.POST("/subscriptions", ImportSubscription)
         api.GET("/subscriptions", GetSubscriptions)
+        api.PUT("/subscriptions/:id", UpdateSubscription)
         api.DELETE("/subscriptions/:id", DeleteSubscription)
         api.POST("/subscriptions/merge", MergeSubscriptions)
+        api.POST("/subscriptions/bulk", BulkSubscriptions)
 
         // 节点管理
         api.POST("/nodes/import", ImportNodes)
         api.GET("/nodes/list", GetNodeList)
+        api.GET("/nodes/regions", GetNodeRegions)
         api.POST("/nodes/test", TestNodes)
         api.POST("/nodes/filter", FilterNodes)
         api.POST("/nodes/generate", GenerateSubscription)
+
+        // 后台任务
+        api.GET("/jobs", ListJobs)
+        api.GET("/jobs/:id", GetJob)
+        api.POST("/jobs/:id/cancel", CancelJob)
+        api.GET("/jobs/:id/events", StreamJobEvents)
+
+        // 定时任务
+        api.GET("/tasks", ListTasks)
+        api.POST("/tasks", CreateTask)
+        api.GET("/tasks/:id", GetTask)
+        api.PUT("/tasks/:id", UpdateTask)
+        api.DELETE("/tasks/:id", DeleteTask)
+        api.GET("/tasks/:id/results", GetTaskResults)
+
+        // 测速目标
+        api.GET("/speedtest/targets", GetTestTargets)
+        api.POST("/speedtest/targets/check", CheckTestTargets)
+
+        // 系统设置
+        api.GET("/settings", GetSettings)
+        api.PUT("/settings", UpdateSettings)
     }
 
-    // 静态文件服务
-    r.Static("/data", "./data")
+    // 内置测速端点，远程访问需要认证
+    if config.Get().Test.BuiltinServer {
+        speedtest := r.Group("/speedtest", LocalOrAuthRequired())
+        speedtest.GET("/:size", SpeedTest)
+        speedtest.HEAD("/:size", SpeedTest)
+    }
 
     return r
 } 
\ No newline at end of file
@@ -0,0 +1,45 @@
+package api
+
+import (
+    "net/http"
+    "subsmanager/internal/models"
+    "subsmanager/internal/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+// GetSettings 获取系统设置
+func GetSettings(c *gin.Context) {
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data:    services.GetSettings(),
+    })
+}
+
+// UpdateSettings 更新系统设置
+func UpdateSettings(c *gin.Context) {
+    var req models.Settings
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: "Invalid request parameters",
+        })
+        return
+    }
+
+    settings, err := services.UpdateSettings(&req)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data:    settings,
+    })
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "net/http"
+    "strconv"
+    "subsmanager/config"
+    "subsmanager/internal/services"
+    "subsmanager/internal/utils"
+
+    "github.com/gin-gonic/gin"
+)
+
+// 内置测速端点写入块，内容全部为0
+var speedTestChunk = make([]byte, 64*1024)
+
+// SpeedTest 内置测速端点，返回指定大小的数据，如 /speedtest/10mb
+//
+// 单次最多返回 test.max_bytes 字节，与下载测试的字节上限一致。
+func SpeedTest(c *gin.Context) {
+    maxSize := config.ByteSize(config.Get().Test.MaxBytes, services.DefaultMaxBytes)
+    size, err := config.ParseByteSize(c.Param("size"))
+    if err != nil || size <= 0 || size > maxSize {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: fmt.Sprintf("Invalid size, expected e.g. 512kb, 10mb (max %s)", utils.FormatByteSize(maxSize)),
+        })
+        return
+    }
+
+    c.Header("Content-Type", "application/octet-stream")
+    c.Header("Content-Length", strconv.FormatInt(size, 10))
+    c.Header("Cache-Control", "no-store")
+    c.Status(http.StatusOK)
+    if c.Request.Method == http.MethodHead {
+        return
+    }
+
+    remaining := size
+    c.Stream(func(w io.Writer) bool {
+        chunk := speedTestChunk
+        if remaining < int64(len(chunk)) {
+            chunk = chunk[:remaining]
+        }
+        n, err := w.Write(chunk)
+        remaining -= int64(n)
+        return err == nil && remaining > 0
+    })
+}
+
+// GetTestTargets 获取测速目标及其健康状态
+func GetTestTargets(c *gin.Context) {
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data:    services.DefaultTestTargets.List(),
+    })
+}
+
+// CheckTestTargets 立即检查所有测速目标的健康状态
+func CheckTestTargets(c *gin.Context) {
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data:    services.DefaultTestTargets.CheckHealth(context.Background()),
+    })
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+    "fmt"
+    "net/http"
+    "subsmanager/internal/models"
+    "subsmanager/internal/services"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// TaskRequest 创建/更新定时任务请求
+type TaskRequest struct {
+    Name   string            `json:"name" binding:"required"`
+    Type   models.TaskType   `json:"type" binding:"required"`
+    Cron   string            `json:"cron" binding:"required"`
+    Status models.TaskStatus `json:"status"`
+
+    // 完整流程任务配置，仅pipeline类型有效
+    Pipeline *models.PipelineConfig `json:"pipeline"`
+}
+
+// ListTasks 获取定时任务列表
+func ListTasks(c *gin.Context) {
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data:    services.DefaultSchedulerService.ListTasks(),
+    })
+}
+
+// GetTask 获取定时任务
+func GetTask(c *gin.Context) {
+    task, err := services.DefaultSchedulerService.GetTask(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, Response{
+            Code:    404,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data:    task,
+    })
+}
+
+// GetTaskResults 获取定时任务执行记录，完整流程任务包含各步骤结果
+func GetTaskResults(c *gin.Context) {
+    if _, err := services.DefaultSchedulerService.GetTask(c.Param("id")); err != nil {
+        c.JSON(http.StatusNotFound, Response{
+            Code:    404,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Success",
+        Data:    services.DefaultSchedulerService.TaskResults(c.Param("id")),
+    })
+}
+
+// CreateTask 创建定时任务
+func CreateTask(c *gin.Context) {
+    var req TaskRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: "Invalid request parameters",
+        })
+        return
+    }
+
+    now := time.Now()
+    task := &models.Task{
+        ID:         fmt.Sprintf("task_%d", now.UnixNano()),
+        Type:       req.Type,
+        Name:       req.Name,
+        Status:     taskStatus(req.Status),
+        Cron:       req.Cron,
+        Pipeline:   req.Pipeline,
+        CreateTime: now,
+        UpdateTime: now,
+    }
+    if err := services.DefaultSchedulerService.AddTask(task); err != nil {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Task created successfully",
+        Data:    task,
+    })
+}
+
+// UpdateTask 更新定时任务
+func UpdateTask(c *gin.Context) {
+    var req TaskRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: "Invalid request parameters",
+        })
+        return
+    }
+
+    old, err := services.DefaultSchedulerService.GetTask(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, Response{
+            Code:    404,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    task := *old
+    task.Type = req.Type
+    task.Name = req.Name
+    task.Status = taskStatus(req.Status)
+    task.Cron = req.Cron
+    task.Pipeline = req.Pipeline
+    task.UpdateTime = time.Now()
+    if err := services.DefaultSchedulerService.UpdateTask(&task); err != nil {
+        c.JSON(http.StatusBadRequest, Response{
+            Code:    400,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Task updated successfully",
+        Data:    &task,
+    })
+}
+
+// DeleteTask 删除定时任务
+func DeleteTask(c *gin.Context) {
+    if err := services.DefaultSchedulerService.RemoveTask(c.Param("id")); err != nil {
+        c.JSON(http.StatusNotFound, Response{
+            Code:    404,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, Response{
+        Code:    200,
+        Message: "Task deleted successfully",
+    })
+}
+
+// taskStatus 未指定状态时默认启用
+func taskStatus(status models.TaskStatus) models.TaskStatus {
+    if status == "" {
+        return models.TaskStatusEnabled
+    }
+    return status
+}
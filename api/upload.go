@@ -0,0 +1,61 @@
+package api
+
+import (
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+    "subsmanager/config"
+
+    "github.com/gin-gonic/gin"
+)
+
+// 除文件内容外，multipart请求中其他字段和分隔符允许占用的字节数
+const multipartOverhead = 64 * 1024
+
+// uploadError 上传文件错误及对应的响应状态码
+type uploadError struct {
+    status int
+    err    error
+}
+
+func (e *uploadError) Error() string {
+    return e.err.Error()
+}
+
+// isMultipart 请求是否为multipart/form-data上传
+func isMultipart(c *gin.Context) bool {
+    return strings.HasPrefix(c.ContentType(), "multipart/form-data")
+}
+
+// readUpload 读取上传的文件内容，超过 storage.max_upload_size 时返回413
+func readUpload(c *gin.Context, field string) ([]byte, *uploadError) {
+    max := config.ByteSize(config.Get().Storage.MaxUploadSize, 10<<20)
+    c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max+multipartOverhead)
+
+    header, err := c.FormFile(field)
+    if err != nil {
+        if strings.Contains(err.Error(), "request body too large") {
+            return nil, &uploadError{http.StatusRequestEntityTooLarge, fmt.Errorf("file too large (max %d bytes)", max)}
+        }
+        return nil, &uploadError{http.StatusBadRequest, fmt.Errorf("missing upload file %q", field)}
+    }
+    if header.Size > max {
+        return nil, &uploadError{http.StatusRequestEntityTooLarge, fmt.Errorf("file too large (max %d bytes)", max)}
+    }
+
+    f, err := header.Open()
+    if err != nil {
+        return nil, &uploadError{http.StatusBadRequest, fmt.Errorf("read upload file failed: %v", err)}
+    }
+    defer f.Close()
+
+    data, err := io.ReadAll(io.LimitReader(f, max+1))
+    if err != nil {
+        return nil, &uploadError{http.StatusBadRequest, fmt.Errorf("read upload file failed: %v", err)}
+    }
+    if int64(len(data)) > max {
+        return nil, &uploadError{http.StatusRequestEntityTooLarge, fmt.Errorf("file too large (max %d bytes)", max)}
+    }
+    return data, nil
+}
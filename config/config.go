@@ -1,32 +1,655 @@
 package config
 
+import (
+    "bytes"
+    "fmt"
+    "net/url"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "gopkg.in/yaml.v3"
+)
+
+// 环境变量前缀，如 server.port → SUBSMANAGER_SERVER_PORT
+const EnvPrefix = "SUBSMANAGER_"
+
+// 默认配置文件路径
+const DefaultConfigFile = "config.yaml"
+
+// DedupStrategies 支持的节点去重策略
+var DedupStrategies = []string{"endpoint", "endpoint_credential", "config_hash", "resolved_ip"}
+
+// 内置测速端点目标前缀
+const BuiltinTargetPrefix = "builtin:"
+
 type Config struct {
     Server struct {
-        Port int    `yaml:"port"`
-        Host string `yaml:"host"`
+        Port      int    `yaml:"port"`
+        Host      string `yaml:"host"`
+        PublicURL string `yaml:"public_url"` // 对外访问地址，用于生成订阅下载地址，为空时按请求推断
     } `yaml:"server"`
-    
+
     Storage struct {
-        Path string `yaml:"path"`
+        Path          string `yaml:"path"`
+        ImportDir     string `yaml:"import_dir"`      // 按路径导入节点、读取file类型proxy-provider时允许访问的目录，相对于存储目录
+        MaxUploadSize string `yaml:"max_upload_size"` // 上传导入文件的大小上限
     } `yaml:"storage"`
-    
+
     Subscription struct {
         UpdateInterval string `yaml:"update_interval"`
         TestInterval  string `yaml:"test_interval"`
         MaxConcurrent int    `yaml:"max_concurrent"`
+        DedupStrategy string `yaml:"dedup_strategy"` // 节点去重策略
+        Pipeline      bool   `yaml:"pipeline"`       // 默认任务使用完整流程（更新→整合→测速→筛选→生成）
     } `yaml:"subscription"`
+
+    Filter struct {
+        MaxLatency   int      `yaml:"max_latency"`   // 延迟上限(ms)
+        MinSpeed     float64  `yaml:"min_speed"`     // 速度下限(MB/s)
+        InfoKeywords []string `yaml:"info_keywords"` // 追加的信息节点关键字
+        InfoPatterns []string `yaml:"info_patterns"` // 追加的信息节点正则
+    } `yaml:"filter"`
+
+    Test struct {
+        LatencyTimeout  string `yaml:"latency_timeout"`  // 延迟测试超时
+        SpeedTimeout    string `yaml:"speed_timeout"`    // 下载测试超时
+        Concurrent      int    `yaml:"concurrent"`       // 延迟测试并发数
+        SpeedConcurrent int    `yaml:"speed_concurrent"` // 下载测试并发数
+        LatencyCutoff   int    `yaml:"latency_cutoff"`   // 延迟不超过该值(ms)的节点才进行下载测试
+        TestURL         string `yaml:"test_url"`         // 下载测试URL，未配置download_targets时作为唯一下载目标
+
+        LatencyProbes       []TestTarget `yaml:"latency_probes"`        // 网络连通性探测URL，全部不可达时不进行测速
+        DownloadTargets     []TestTarget `yaml:"download_targets"`      // 下载测试目标，按权重选择，不可达时自动切换
+        HealthCheckInterval string       `yaml:"health_check_interval"` // 测速目标健康检查间隔
+        BuiltinServer       bool         `yaml:"builtin_server"`        // 启用内置测速端点 /speedtest/:size
+
+        MaxBytes           string `yaml:"max_bytes"`           // 单次下载的字节上限，如 10mb
+        MaxDuration        string `yaml:"max_duration"`        // 单次下载的时长上限，与字节上限先到者为准
+        SpeedWindow        string `yaml:"speed_window"`        // 计算下载速度的滑动窗口
+        RunBudget          string `yaml:"run_budget"`          // 每轮测速的本机下载流量预算，为空不限制
+    } `yaml:"test"`
+
+    Fetch struct {
+        UserAgent string `yaml:"user_agent"` // 获取订阅时使用的UA
+        Timeout   string `yaml:"timeout"`    // 获取订阅超时
+    } `yaml:"fetch"`
+
+    Log struct {
+        Path       string `yaml:"path"`        // 日志文件路径，为空时写入存储目录
+        MaxEntries int    `yaml:"max_entries"` // 内存中保留的最大日志条数
+    } `yaml:"log"`
+
+    Jobs struct {
+        MaxConcurrent int    `yaml:"max_concurrent"` // 同时执行的后台任务数，其余任务排队
+        Retention     string `yaml:"retention"`      // 已结束任务的保留时长
+        MaxFinished   int    `yaml:"max_finished"`   // 最多保留的已结束任务数
+
+        UpdateTimeout   string `yaml:"update_timeout"`   // 定时订阅更新任务的超时时间
+        TestTimeout     string `yaml:"test_timeout"`     // 定时节点检测任务的超时时间
+        PipelineTimeout string `yaml:"pipeline_timeout"` // 定时完整流程任务的超时时间
+    } `yaml:"jobs"`
+
+    GeoIP struct {
+        MMDBPath       string `yaml:"mmdb_path"`       // 本地mmdb文件路径，为空则仅按别名识别
+        ResolveDomains bool   `yaml:"resolve_domains"` // 是否解析域名后查询mmdb
+    } `yaml:"geoip"`
+
+    Auth struct {
+        Enabled        bool     `yaml:"enabled"`         // 管理API是否需要登录
+        Username       string   `yaml:"username"`        // 管理员用户名
+        PasswordHash   string   `yaml:"password_hash"`   // 管理员密码的bcrypt哈希，为空时首次启动自动生成密码
+        SessionTTL     string   `yaml:"session_ttl"`     // 登录会话有效期
+        AllowedOrigins []string `yaml:"allowed_origins"` // 允许跨域访问管理API的来源，设置为空列表时不允许跨域
+    } `yaml:"auth"`
 }
 
-var GlobalConfig Config
+// TestTarget 测速目标
+//
+// URL 可使用 builtin:<size> 指向内置测速端点，如 builtin:10mb；
+// Weight 为0的目标只在其他目标不可用时使用。
+type TestTarget struct {
+    URL    string `yaml:"url" json:"url"`
+    Weight int    `yaml:"weight" json:"weight"`
+}
+
+// current 当前生效的配置，更新时整体替换为新的快照，未加载配置文件时为默认配置
+var current atomic.Pointer[Config]
+
+// ConfigFile 当前使用的配置文件路径
+var ConfigFile string
 
-func Init() error {
-    // 设置默认配置
-    GlobalConfig.Server.Port = 3355
-    GlobalConfig.Server.Host = "localhost"
-    GlobalConfig.Storage.Path = "./data"
-    GlobalConfig.Subscription.MaxConcurrent = 5
-    GlobalConfig.Subscription.UpdateInterval = "24h"
-    GlobalConfig.Subscription.TestInterval = "4h"
-    
+var (
+    updateMux sync.Mutex
+    listeners []func(old, cur Config)
+)
+
+func init() {
+    Init()
+}
+
+// Get 获取当前配置的快照，快照只读，修改配置需通过 Update
+func Get() *Config {
+    return current.Load()
+}
+
+// Init 恢复默认配置
+func Init() {
+    cfg := defaultConfig()
+    current.Store(&cfg)
+}
+
+// defaultConfig 默认配置
+func defaultConfig() Config {
+    var cfg Config
+    cfg.Server.Port = 3355
+    cfg.Server.Host = "localhost"
+    cfg.Storage.Path = "./data"
+    cfg.Storage.ImportDir = "import"
+    cfg.Storage.MaxUploadSize = "10mb"
+    cfg.Subscription.MaxConcurrent = 5
+    cfg.Subscription.UpdateInterval = "24h"
+    cfg.Subscription.TestInterval = "4h"
+    cfg.Subscription.DedupStrategy = "endpoint_credential"
+    cfg.Filter.MaxLatency = 400
+    cfg.Filter.MinSpeed = 2.0
+    cfg.Test.LatencyTimeout = "5s"
+    cfg.Test.SpeedTimeout = "30s"
+    cfg.Test.Concurrent = 10
+    cfg.Test.SpeedConcurrent = 3
+    cfg.Test.LatencyCutoff = 400
+    cfg.Test.TestURL = "http://cachefly.cachefly.net/100mb.test"
+    cfg.Test.LatencyProbes = []TestTarget{
+        {URL: "http://www.gstatic.com/generate_204", Weight: 1},
+        {URL: "http://cp.cloudflare.com/generate_204", Weight: 1},
+    }
+    cfg.Test.HealthCheckInterval = "10m"
+    cfg.Test.MaxBytes = "10mb"
+    cfg.Test.MaxDuration = "10s"
+    cfg.Test.SpeedWindow = "2s"
+    cfg.Fetch.UserAgent = "clash.meta"
+    cfg.Fetch.Timeout = "30s"
+    cfg.Log.MaxEntries = 1000
+    cfg.Jobs.MaxConcurrent = 1
+    cfg.Jobs.Retention = "24h"
+    cfg.Jobs.MaxFinished = 100
+    cfg.Jobs.UpdateTimeout = "10m"
+    cfg.Jobs.TestTimeout = "2h"
+    cfg.Jobs.PipelineTimeout = "3h"
+    cfg.Auth.Enabled = true
+    cfg.Auth.Username = "admin"
+    cfg.Auth.SessionTTL = "24h"
+    // 前端开发服务器和本机部署的管理界面
+    cfg.Auth.AllowedOrigins = []string{"http://localhost:3000", "http://localhost:3355"}
+
+    return cfg
+}
+
+// Load 加载配置，优先级：环境变量 > 配置文件 > 默认配置
+//
+// path 为空时尝试读取当前目录下的 config.yaml，文件不存在则只使用默认配置。
+func Load(path string) error {
+    cfg := defaultConfig()
+
+    explicit := path != ""
+    if !explicit {
+        path = DefaultConfigFile
+    }
+
+    data, err := os.ReadFile(path)
+    switch {
+    case err == nil:
+        if err := yaml.Unmarshal(data, &cfg); err != nil {
+            return fmt.Errorf("parse config file %s failed: %v", path, err)
+        }
+    case os.IsNotExist(err) && !explicit:
+        // 未指定配置文件且默认文件不存在，使用默认配置
+    default:
+        return fmt.Errorf("read config file %s failed: %v", path, err)
+    }
+    if err := applyEnv(reflect.ValueOf(&cfg).Elem(), EnvPrefix); err != nil {
+        return err
+    }
+    if err := Validate(&cfg); err != nil {
+        return err
+    }
+
+    updateMux.Lock()
+    defer updateMux.Unlock()
+    ConfigFile = path
+    current.Store(&cfg)
     return nil
-} 
\ No newline at end of file
+}
+
+// Validate 校验配置
+func Validate(cfg *Config) error {
+    if cfg.Server.Port <= 0 || cfg.Server.Port > 65535 {
+        return fmt.Errorf("invalid server.port: %d", cfg.Server.Port)
+    }
+    if cfg.Server.PublicURL != "" {
+        if u, err := url.Parse(cfg.Server.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+            return fmt.Errorf("invalid server.public_url: %s", cfg.Server.PublicURL)
+        }
+    }
+    if cfg.Storage.Path == "" {
+        return fmt.Errorf("storage.path is required")
+    }
+    // 导入目录必须是存储目录下的子目录，不能是存储目录本身，避免读取订阅数据、令牌等文件
+    if dir := filepath.Clean(cfg.Storage.ImportDir); cfg.Storage.ImportDir == "" || filepath.IsAbs(dir) || dir == "." || dir == ".." || strings.HasPrefix(dir, ".."+string(filepath.Separator)) {
+        return fmt.Errorf("storage.import_dir must be a subdirectory of storage.path")
+    }
+    if n, err := ParseByteSize(cfg.Storage.MaxUploadSize); err != nil || n <= 0 {
+        return fmt.Errorf("invalid storage.max_upload_size %q", cfg.Storage.MaxUploadSize)
+    }
+    if cfg.Subscription.MaxConcurrent <= 0 {
+        return fmt.Errorf("subscription.max_concurrent must be greater than 0")
+    }
+    if !validDedupStrategy(cfg.Subscription.DedupStrategy) {
+        return fmt.Errorf("unsupported subscription.dedup_strategy: %s", cfg.Subscription.DedupStrategy)
+    }
+    if cfg.Test.Concurrent <= 0 || cfg.Test.SpeedConcurrent <= 0 {
+        return fmt.Errorf("test.concurrent and test.speed_concurrent must be greater than 0")
+    }
+    if cfg.Test.LatencyCutoff <= 0 {
+        return fmt.Errorf("test.latency_cutoff must be greater than 0")
+    }
+    if cfg.Jobs.MaxConcurrent <= 0 {
+        return fmt.Errorf("jobs.max_concurrent must be greater than 0")
+    }
+    if cfg.Jobs.MaxFinished < 0 {
+        return fmt.Errorf("jobs.max_finished must not be negative")
+    }
+    if cfg.Filter.MaxLatency < 0 || cfg.Filter.MinSpeed < 0 {
+        return fmt.Errorf("filter thresholds must not be negative")
+    }
+    if cfg.Auth.Enabled && cfg.Auth.Username == "" {
+        return fmt.Errorf("auth.username is required when auth is enabled")
+    }
+
+    for _, t := range append(append([]TestTarget{}, cfg.Test.LatencyProbes...), cfg.Test.DownloadTargets...) {
+        if t.Weight < 0 {
+            return fmt.Errorf("invalid weight %d for test target %s", t.Weight, t.URL)
+        }
+        if strings.HasPrefix(t.URL, BuiltinTargetPrefix) {
+            if !cfg.Test.BuiltinServer {
+                return fmt.Errorf("test target %s requires test.builtin_server", t.URL)
+            }
+            continue
+        }
+        if u, err := url.Parse(t.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+            return fmt.Errorf("invalid test target url: %s", t.URL)
+        }
+    }
+
+    durations := map[string]string{
+        "subscription.update_interval": cfg.Subscription.UpdateInterval,
+        "subscription.test_interval":   cfg.Subscription.TestInterval,
+        "test.latency_timeout":         cfg.Test.LatencyTimeout,
+        "test.speed_timeout":           cfg.Test.SpeedTimeout,
+        "fetch.timeout":                cfg.Fetch.Timeout,
+        "jobs.retention":               cfg.Jobs.Retention,
+        "jobs.update_timeout":          cfg.Jobs.UpdateTimeout,
+        "jobs.test_timeout":            cfg.Jobs.TestTimeout,
+        "jobs.pipeline_timeout":        cfg.Jobs.PipelineTimeout,
+        "test.health_check_interval":   cfg.Test.HealthCheckInterval,
+        "test.max_duration":            cfg.Test.MaxDuration,
+        "test.speed_window":            cfg.Test.SpeedWindow,
+        "auth.session_ttl":             cfg.Auth.SessionTTL,
+    }
+    for name, value := range durations {
+        d, err := time.ParseDuration(value)
+        if err != nil {
+            return fmt.Errorf("invalid %s %q: %v", name, value, err)
+        }
+        if d <= 0 {
+            return fmt.Errorf("%s must be greater than 0", name)
+        }
+    }
+    if Duration(cfg.Test.MaxDuration, 0) > Duration(cfg.Test.SpeedTimeout, 0) {
+        return fmt.Errorf("test.max_duration must not exceed test.speed_timeout")
+    }
+
+    maxBytes, err := ParseByteSize(cfg.Test.MaxBytes)
+    if err != nil || maxBytes <= 0 {
+        return fmt.Errorf("invalid test.max_bytes %q", cfg.Test.MaxBytes)
+    }
+    // 内置测速端点最多返回 test.max_bytes 字节
+    for _, t := range append(append([]TestTarget{}, cfg.Test.LatencyProbes...), cfg.Test.DownloadTargets...) {
+        if !strings.HasPrefix(t.URL, BuiltinTargetPrefix) {
+            continue
+        }
+        if n, err := ParseByteSize(strings.TrimPrefix(t.URL, BuiltinTargetPrefix)); err != nil || n <= 0 || n > maxBytes {
+            return fmt.Errorf("test target %s must be a size within test.max_bytes", t.URL)
+        }
+    }
+    if cfg.Test.RunBudget != "" {
+        if _, err := ParseByteSize(cfg.Test.RunBudget); err != nil {
+            return fmt.Errorf("invalid test.run_budget %q: %v", cfg.Test.RunBudget, err)
+        }
+    }
+
+    return nil
+}
+
+// validDedupStrategy 判断是否为支持的去重策略，为空时使用默认策略
+func validDedupStrategy(strategy string) bool {
+    if strategy == "" {
+        return true
+    }
+    for _, s := range DedupStrategies {
+        if s == strategy {
+            return true
+        }
+    }
+    return false
+}
+
+// OnChange 注册配置变更回调，配置更新成功后按注册顺序调用
+func OnChange(fn func(old, cur Config)) {
+    updateMux.Lock()
+    defer updateMux.Unlock()
+    listeners = append(listeners, fn)
+}
+
+// Update 修改配置：校验通过后将修改的配置项写入配置文件，再应用到运行时并通知回调
+func Update(fn func(cfg *Config) error) error {
+    return update(fn, true)
+}
+
+// UpdateRuntime 只在本次运行中修改配置，不写入配置文件
+func UpdateRuntime(fn func(cfg *Config) error) error {
+    return update(fn, false)
+}
+
+// Override 临时修改配置且不保存，返回恢复修改前配置的函数，供测试使用
+//
+// 修改后的配置校验失败时panic。
+func Override(fn func(cfg *Config)) (restore func()) {
+    old := current.Load()
+    if err := UpdateRuntime(func(cfg *Config) error {
+        fn(cfg)
+        return nil
+    }); err != nil {
+        panic(fmt.Sprintf("config override failed: %v", err))
+    }
+    return func() { current.Store(old) }
+}
+
+// update 在副本上修改配置，persist 为 true 时写入配置文件
+func update(fn func(cfg *Config) error, persist bool) error {
+    updateMux.Lock()
+    defer updateMux.Unlock()
+
+    old := current.Load()
+    cur := *old
+    // 切片字段需要深拷贝，避免修改影响旧配置
+    cur.Filter.InfoKeywords = append([]string(nil), old.Filter.InfoKeywords...)
+    cur.Filter.InfoPatterns = append([]string(nil), old.Filter.InfoPatterns...)
+    cur.Test.LatencyProbes = append([]TestTarget(nil), old.Test.LatencyProbes...)
+    cur.Test.DownloadTargets = append([]TestTarget(nil), old.Test.DownloadTargets...)
+    cur.Auth.AllowedOrigins = append([]string(nil), old.Auth.AllowedOrigins...)
+
+    if err := fn(&cur); err != nil {
+        return err
+    }
+    if err := Validate(&cur); err != nil {
+        return err
+    }
+    if persist {
+        if err := save(old, &cur); err != nil {
+            return err
+        }
+    }
+
+    current.Store(&cur)
+    for _, l := range listeners {
+        l(*old, cur)
+    }
+    return nil
+}
+
+// save 将修改过的配置项写入配置文件
+//
+// 只改写 old 与 cur 不同的配置项，文件中的其他内容和注释保持不变，
+// 默认值和环境变量覆盖的配置项不会写入文件。
+func save(old, cur *Config) error {
+    path := ConfigFile
+    if path == "" {
+        path = DefaultConfigFile
+    }
+
+    var doc yaml.Node
+    data, err := os.ReadFile(path)
+    switch {
+    case err == nil:
+        if err := yaml.Unmarshal(data, &doc); err != nil {
+            return fmt.Errorf("parse config file %s failed: %v", path, err)
+        }
+    case os.IsNotExist(err):
+    default:
+        return fmt.Errorf("read config file %s failed: %v", path, err)
+    }
+    if len(doc.Content) == 0 {
+        doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+    }
+    root := doc.Content[0]
+    if root.Kind != yaml.MappingNode {
+        return fmt.Errorf("config file %s is not a mapping", path)
+    }
+
+    changed := 0
+    err = diffFields(reflect.ValueOf(old).Elem(), reflect.ValueOf(cur).Elem(), nil, func(keys []string, value reflect.Value) error {
+        var node yaml.Node
+        if err := node.Encode(value.Interface()); err != nil {
+            return err
+        }
+        setNode(root, keys, &node)
+        changed++
+        return nil
+    })
+    if err != nil {
+        return fmt.Errorf("marshal config failed: %v", err)
+    }
+    if changed == 0 {
+        return nil
+    }
+
+    // 与示例配置文件一致使用两个空格缩进
+    var buf bytes.Buffer
+    enc := yaml.NewEncoder(&buf)
+    enc.SetIndent(2)
+    if err := enc.Encode(&doc); err != nil {
+        return fmt.Errorf("marshal config failed: %v", err)
+    }
+    if err := enc.Close(); err != nil {
+        return fmt.Errorf("marshal config failed: %v", err)
+    }
+    if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+        return fmt.Errorf("write config file %s failed: %v", path, err)
+    }
+
+    ConfigFile = path
+    return nil
+}
+
+// diffFields 按yaml标签递归比较配置，对每个值不同的配置项调用fn
+func diffFields(old, cur reflect.Value, keys []string, fn func(keys []string, value reflect.Value) error) error {
+    t := cur.Type()
+    for i := 0; i < t.NumField(); i++ {
+        tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+        if tag == "" || tag == "-" {
+            continue
+        }
+        path := append(append([]string(nil), keys...), tag)
+        o, c := old.Field(i), cur.Field(i)
+
+        if c.Kind() == reflect.Struct {
+            if err := diffFields(o, c, path, fn); err != nil {
+                return err
+            }
+            continue
+        }
+        // nil 和空切片视为相同
+        if c.Kind() == reflect.Slice && o.Len() == 0 && c.Len() == 0 {
+            continue
+        }
+        if reflect.DeepEqual(o.Interface(), c.Interface()) {
+            continue
+        }
+        if err := fn(path, c); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// setNode 在yaml映射中设置指定路径的值，缺少的中间节点自动创建，原有的行尾注释保留
+func setNode(m *yaml.Node, keys []string, value *yaml.Node) {
+    for i := 0; i+1 < len(m.Content); i += 2 {
+        if m.Content[i].Value != keys[0] {
+            continue
+        }
+        if len(keys) == 1 {
+            value.LineComment = m.Content[i+1].LineComment
+            m.Content[i+1] = value
+            return
+        }
+        if m.Content[i+1].Kind != yaml.MappingNode {
+            m.Content[i+1] = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+        }
+        setNode(m.Content[i+1], keys[1:], value)
+        return
+    }
+
+    key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: keys[0]}
+    if len(keys) == 1 {
+        m.Content = append(m.Content, key, value)
+        return
+    }
+    child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+    m.Content = append(m.Content, key, child)
+    setNode(child, keys[1:], value)
+}
+
+// Duration 解析已校验过的时长配置，解析失败时返回默认值
+func Duration(value string, def time.Duration) time.Duration {
+    d, err := time.ParseDuration(value)
+    if err != nil || d <= 0 {
+        return def
+    }
+    return d
+}
+
+// 字节单位，按1024进制
+var byteUnits = map[string]int64{
+    "":   1,
+    "b":  1,
+    "k":  1 << 10,
+    "kb": 1 << 10,
+    "m":  1 << 20,
+    "mb": 1 << 20,
+    "g":  1 << 30,
+    "gb": 1 << 30,
+}
+
+// ParseByteSize 解析字节大小，如 1024、512kb、10MB、1g
+func ParseByteSize(value string) (int64, error) {
+    s := strings.ToLower(strings.TrimSpace(value))
+    i := len(s)
+    for i > 0 && (s[i-1] < '0' || s[i-1] > '9') {
+        i--
+    }
+
+    unit, ok := byteUnits[s[i:]]
+    if !ok || i == 0 {
+        return 0, fmt.Errorf("invalid byte size: %q", value)
+    }
+    n, err := strconv.ParseInt(s[:i], 10, 64)
+    if err != nil || n < 0 {
+        return 0, fmt.Errorf("invalid byte size: %q", value)
+    }
+    return n * unit, nil
+}
+
+// ByteSize 解析字节大小，格式错误时返回默认值
+func ByteSize(value string, def int64) int64 {
+    n, err := ParseByteSize(value)
+    if err != nil {
+        return def
+    }
+    return n
+}
+
+// applyEnv 按yaml标签递归应用环境变量覆盖
+func applyEnv(v reflect.Value, prefix string) error {
+    t := v.Type()
+    for i := 0; i < t.NumField(); i++ {
+        tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+        if tag == "" || tag == "-" {
+            continue
+        }
+        name := prefix + strings.ToUpper(tag)
+        field := v.Field(i)
+
+        if field.Kind() == reflect.Struct {
+            if err := applyEnv(field, name+"_"); err != nil {
+                return err
+            }
+            continue
+        }
+
+        value, ok := os.LookupEnv(name)
+        if !ok {
+            continue
+        }
+        if err := setField(field, value); err != nil {
+            return fmt.Errorf("invalid env %s: %v", name, err)
+        }
+    }
+    return nil
+}
+
+// setField 将字符串值写入配置字段
+func setField(field reflect.Value, value string) error {
+    switch field.Kind() {
+    case reflect.String:
+        field.SetString(value)
+    case reflect.Int:
+        n, err := strconv.Atoi(value)
+        if err != nil {
+            return err
+        }
+        field.SetInt(int64(n))
+    case reflect.Float64:
+        f, err := strconv.ParseFloat(value, 64)
+        if err != nil {
+            return err
+        }
+        field.SetFloat(f)
+    case reflect.Bool:
+        b, err := strconv.ParseBool(value)
+        if err != nil {
+            return err
+        }
+        field.SetBool(b)
+    case reflect.Slice:
+        if field.Type().Elem().Kind() != reflect.String {
+            return fmt.Errorf("unsupported slice type")
+        }
+        var items []string
+        for _, item := range strings.Split(value, ",") {
+            if item = strings.TrimSpace(item); item != "" {
+                items = append(items, item)
+            }
+        }
+        field.Set(reflect.ValueOf(items))
+    default:
+        return fmt.Errorf("unsupported field type %s", field.Kind())
+    }
+    return nil
+}
@@ -1,12 +1,31 @@
 import axios from 'axios'
+import router from '@/router'
 
 const baseURL = 'http://localhost:3355'
 
 const api = axios.create({
   baseURL,
-  timeout: 30000
+  timeout: 30000,
+  // 携带登录会话Cookie
+  withCredentials: true
 })
 
+// 未登录或会话过期时跳转到登录页面
+api.interceptors.response.use(
+  response => response,
+  error => {
+    const route = router.currentRoute.value
+    if (error.response?.status === 401 && route.name !== 'Login') {
+      router.push({ name: 'Login', query: { redirect: route.fullPath } })
+    }
+    return Promise.reject(error)
+  }
+)
+
+// 登录
+export const login = (data: { username: string; password: string }) => api.post('/api/auth/login', data)
+export const logout = () => api.post('/api/auth/logout')
+
 // 状态监控
 export const getSystemStatus = () => api.get('/api/status')
 
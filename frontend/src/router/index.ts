@@ -6,6 +6,15 @@ import MainLayout from '@/layouts/MainLayout.vue'
 
 // 路由配置
 const routes: RouteRecordRaw[] = [
+  // 登录页面
+  {
+    path: '/login',
+    name: 'Login',
+    component: () => import('@/views/login/index.vue'),
+    meta: {
+      title: '登录'
+    }
+  },
   {
     path: '/',
     component: MainLayout,
@@ -40,7 +40,7 @@ var (
 
 func init() {
     // 初始化订阅文件路径
-    subscriptionFile = filepath.Join(config.GlobalConfig.Storage.Path, "subscriptions.json")
+    subscriptionFile = filepath.Join(config.Get().Storage.Path, "subscriptions.json")
     // 加载已有订阅
     loadSubscriptions()
 }
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// API令牌权限范围
+const (
+    ScopeRead   = "read"   // 只读，只能调用查询接口
+    ScopeManage = "manage" // 管理，可调用所有接口
+)
+
+// APIToken 长期有效的API访问令牌，只保存令牌的哈希
+type APIToken struct {
+    ID         string    `json:"id"`
+    Name       string    `json:"name"`                   // 令牌用途说明
+    Scope      string    `json:"scope"`                  // 权限范围：read/manage
+    Prefix     string    `json:"prefix"`                 // 令牌前几位，便于识别
+    Hash       string    `json:"hash,omitempty"`         // 令牌的SHA-256哈希，接口返回时不包含
+    CreatedAt  time.Time `json:"created_at"`
+    LastUsedAt time.Time `json:"last_used_at,omitempty"` // 最近一次使用时间
+    ExpiresAt  time.Time `json:"expires_at,omitempty"`   // 过期时间，为空表示永不过期
+}
+
+// Principal 已认证的访问者
+type Principal struct {
+    Username string `json:"username,omitempty"` // 通过会话登录的用户名
+    TokenID  string `json:"token_id,omitempty"` // 通过API令牌访问时的令牌ID
+    Scope    string `json:"scope"`              // 权限范围
+}
+
+// ViaToken 是否通过API令牌访问
+func (p *Principal) ViaToken() bool {
+    return p.TokenID != ""
+}
+
+// Allows 是否具有指定权限，manage 包含 read
+func (p *Principal) Allows(scope string) bool {
+    return p.Scope == ScopeManage || p.Scope == scope
+}
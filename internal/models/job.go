@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// JobStatus 后台任务状态
+type JobStatus string
+
+const (
+    JobStatusQueued    JobStatus = "queued"    // 排队中
+    JobStatusRunning   JobStatus = "running"   // 执行中
+    JobStatusSucceeded JobStatus = "succeeded" // 执行成功
+    JobStatusFailed    JobStatus = "failed"    // 执行失败
+    JobStatusCancelled JobStatus = "cancelled" // 已取消
+)
+
+// 后台任务类型
+const (
+    JobTypeSpeedTest = "speed_test"          // 节点测速
+    JobTypeImport    = "import_subscription" // 导入订阅
+    JobTypeMerge     = "merge_subscriptions" // 整合订阅
+    JobTypeGenerate  = "generate"            // 生成订阅
+    JobTypeTask      = "scheduled_task"      // 定时任务
+)
+
+// Job 后台任务
+type Job struct {
+    ID         string         `json:"id"`                    // 任务ID
+    Type       string         `json:"type"`                  // 任务类型
+    Key        string         `json:"key,omitempty"`         // 去重键，相同键的任务同时只执行一个
+    Status     JobStatus      `json:"status"`                // 任务状态
+    Progress   float64        `json:"progress"`              // 进度(0-100)
+    Stage      string         `json:"stage,omitempty"`       // 当前阶段
+    Counts     map[string]int `json:"counts,omitempty"`      // 各阶段统计
+    Result     interface{}    `json:"result,omitempty"`      // 任务结果，执行中为部分结果
+    Error      string         `json:"error,omitempty"`       // 错误信息
+    CreatedAt  time.Time      `json:"created_at"`            // 创建时间
+    StartedAt  time.Time      `json:"started_at,omitempty"`  // 开始执行时间
+    FinishedAt time.Time      `json:"finished_at,omitempty"` // 结束时间
+}
+
+// Finished 任务是否已结束
+func (j *Job) Finished() bool {
+    return j.Status == JobStatusSucceeded || j.Status == JobStatusFailed || j.Status == JobStatusCancelled
+}
+
+// JobQuery 后台任务列表查询条件
+type JobQuery struct {
+    Type   string    `form:"type"`   // 任务类型
+    Status JobStatus `form:"status"` // 任务状态
+}
+
+// JobEvent 后台任务推送事件
+type JobEvent struct {
+    Event string      `json:"event"` // 事件类型：progress/node/done
+    Data  interface{} `json:"data"`  // 事件数据
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// DefaultConsumer 生成订阅时自动创建的发布链接的使用方名称
+const DefaultConsumer = "default"
+
+// OutputMerge 合并订阅生成的文件对应的输出名称，合并结果的默认链接始终指向最近一次合并的文件
+const OutputMerge = "merge"
+
+// PublishedLink 订阅文件的发布链接，通过 /sub/:token 下载
+//
+// 每个使用方（设备、朋友等）可以使用单独的链接，吊销一个链接不影响其他使用方。
+type PublishedLink struct {
+    ID           string    `json:"id"`
+    Token        string    `json:"token"`                    // 随机令牌，与API令牌相互独立
+    FileName     string    `json:"file_name"`                // 存储目录中的订阅文件名
+    Output       string    `json:"output,omitempty"`         // 默认链接对应的输出，重新生成后指向最新的文件
+    Consumer     string    `json:"consumer"`                 // 使用方名称
+    CreatedAt    time.Time `json:"created_at"`
+    ExpiresAt    time.Time `json:"expires_at,omitempty"`     // 过期时间，为空表示永不过期
+    RevokedAt    time.Time `json:"revoked_at,omitempty"`     // 吊销时间，为空表示有效
+    LastAccessAt time.Time `json:"last_access_at,omitempty"` // 最近一次下载时间
+    AccessCount  int       `json:"access_count"`             // 下载次数
+}
+
+// Active 链接当前是否可用
+func (l *PublishedLink) Active() bool {
+    if !l.RevokedAt.IsZero() {
+        return false
+    }
+    return l.ExpiresAt.IsZero() || time.Now().Before(l.ExpiresAt)
+}
+
+// PublishAccess 发布链接的访问记录
+type PublishAccess struct {
+    LinkID    string    `json:"link_id"`
+    Consumer  string    `json:"consumer"`
+    Time      time.Time `json:"time"`
+    IP        string    `json:"ip"`
+    UserAgent string    `json:"user_agent"`
+    Status    int       `json:"status"` // 响应状态码，吊销或过期的链接为403
+}
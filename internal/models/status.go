@@ -2,8 +2,8 @@ package models
 
 import "time"
 
-// SubscriptionHistory 订阅历史记录
-type SubscriptionHistory struct {
+// SubFileHistory 订阅文件生成记录
+type SubFileHistory struct {
     FileName    string    `json:"file_name"`    // 文件名
     LocalURL    string    `json:"local_url"`    // 本地访问地址
     GenerateTime time.Time `json:"generate_time"` // 生成时间
@@ -12,10 +12,11 @@ type SubscriptionHistory struct {
 
 // NodeStatus 节点状态统计
 type NodeStatus struct {
-    TotalNodes     int `json:"total_nodes"`      // 总节点数
-    CurrentNodes   int `json:"current_nodes"`    // 当前筛选后的节点数
-    SlowNodes      int `json:"slow_nodes"`       // 慢速节点数
-    FaultNodes     int `json:"fault_nodes"`      // 故障节点数（延迟>400ms）
+    TotalNodes     int            `json:"total_nodes"`      // 总节点数
+    CurrentNodes   int            `json:"current_nodes"`    // 当前筛选后的节点数
+    SlowNodes      int            `json:"slow_nodes"`       // 慢速节点数
+    FaultNodes     int            `json:"fault_nodes"`      // 故障节点数（延迟>400ms）
+    RegionNodes    map[string]int `json:"region_nodes"`     // 各地区节点数，key为地区二位代码
 }
 
 // SystemStatus 系统状态
@@ -23,6 +24,6 @@ type SystemStatus struct {
     NodeStatus         NodeStatus            `json:"node_status"`          // 节点状态
     LatestSubFile     string                `json:"latest_sub_file"`      // 最新的sub文件本地地址
     LatestInputFile   string                `json:"latest_input_file"`    // 最新的Sub-Input文件本地地址
-    SubHistory        []SubscriptionHistory `json:"sub_history"`          // 近三次的订阅历史记录
+    SubHistory        []SubFileHistory      `json:"sub_history"`          // 近三次的订阅历史记录
     LastUpdateTime    time.Time             `json:"last_update_time"`     // 最后更新时间
 } 
\ No newline at end of file
@@ -4,13 +4,94 @@ import "time"
 
 // Subscription 订阅信息
 type Subscription struct {
-    ID        string    `json:"id"`
-    Name      string    `json:"name"`
-    Type      string    `json:"type"`
-    URL       string    `json:"url"`
-    NodeCount int       `json:"node_count"`
-    CreatedAt time.Time `json:"created_at"`
-    UpdatedAt time.Time `json:"updated_at"`
+    ID        string            `json:"id"`
+    Name      string            `json:"name"`
+    Type      string            `json:"type"`
+    Source    string            `json:"source,omitempty"` // 订阅来源：url/local，为空表示url
+    URL       string            `json:"url"`
+    NodeCount int               `json:"node_count"`
+    Info      *SubscriptionInfo `json:"info,omitempty"` // 订阅信息（流量、到期等）
+    UserAgent string            `json:"user_agent,omitempty"` // 获取订阅时使用的UA，为空时使用配置的UA
+    Headers   map[string]string `json:"headers,omitempty"`    // 获取订阅时附加的请求头
+    Tags      []string          `json:"tags,omitempty"`       // 订阅标签
+    Disabled  bool              `json:"disabled,omitempty"`   // 是否停用，停用的订阅不参与批量更新
+    CreatedAt time.Time         `json:"created_at"`
+    UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// 订阅来源
+const (
+    SubscriptionSourceURL   = "url"   // 从订阅链接获取，可定时更新
+    SubscriptionSourceLocal = "local" // 粘贴内容或上传文件导入，不可更新
+)
+
+// Refreshable 订阅是否可以重新获取更新
+func (s *Subscription) Refreshable() bool {
+    return s.Source != SubscriptionSourceLocal
+}
+
+// SubscriptionUpdate 订阅编辑内容，为空的字段保持不变
+type SubscriptionUpdate struct {
+    Name      *string            `json:"name" binding:"omitempty,min=1"`
+    URL       *string            `json:"url" binding:"omitempty,url"` // 修改后重新获取订阅并更新节点
+    UserAgent *string            `json:"user_agent"`                  // 设为空字符串时恢复使用配置的UA
+    Headers   *map[string]string `json:"headers"`                     // 整体替换，设为空对象时清除
+    Tags      *[]string          `json:"tags"`                        // 整体替换，设为空数组时清除
+    Enabled   *bool              `json:"enabled"`                     // 启用或停用订阅
+}
+
+// 订阅批量操作
+const (
+    BulkActionDelete  = "delete"  // 删除
+    BulkActionRefresh = "refresh" // 重新获取订阅
+    BulkActionEnable  = "enable"  // 启用
+    BulkActionDisable = "disable" // 停用
+    BulkActionTag     = "tag"     // 添加或移除标签
+)
+
+// SubscriptionBulkRequest 订阅批量操作请求
+type SubscriptionBulkRequest struct {
+    Action       string   `json:"action" binding:"required,oneof=delete refresh enable disable tag"`
+    IDs          []string `json:"ids" binding:"required,min=1"`
+    AddTags      []string `json:"add_tags"`      // tag操作添加的标签
+    RemoveTags   []string `json:"remove_tags"`   // tag操作移除的标签
+    KeepNodes    bool     `json:"keep_nodes"`    // delete操作是否保留节点
+    AllowPartial bool     `json:"allow_partial"` // 是否允许部分成功，默认任一订阅失败时不做任何修改
+}
+
+// SubscriptionBulkResult 单个订阅的批量操作结果
+type SubscriptionBulkResult struct {
+    ID      string                    `json:"id"`
+    Success bool                      `json:"success"`
+    Error   string                    `json:"error,omitempty"`   // 失败原因，因其他订阅失败而撤销时为 rolled back
+    Deleted *SubscriptionDeleteReport `json:"deleted,omitempty"` // delete操作的删除结果
+}
+
+// SubscriptionDeleteReport 订阅删除结果
+type SubscriptionDeleteReport struct {
+    SubscriptionID     string   `json:"subscription_id"`
+    Name               string   `json:"name"`
+    RemovedNodes       int      `json:"removed_nodes"`        // 删除的节点数
+    RemovedNodeIDs     []string `json:"removed_node_ids"`     // 删除的节点ID
+    OrphanedNodes      int      `json:"orphaned_nodes"`       // 保留并解除关联的节点数
+    ClearedTestRecords int      `json:"cleared_test_records"` // 清除的测试记录数
+}
+
+// SubscriptionBulkReport 订阅批量操作结果
+type SubscriptionBulkReport struct {
+    Action  string                    `json:"action"`
+    Applied bool                      `json:"applied"` // 修改是否已保存
+    Results []*SubscriptionBulkResult `json:"results"`
+}
+
+// SubscriptionInfo 订阅信息，提取自服务商嵌入的信息节点
+type SubscriptionInfo struct {
+    Traffic      string   `json:"traffic,omitempty"`       // 剩余流量
+    TrafficUsed  string   `json:"traffic_used,omitempty"`  // 已用流量
+    TrafficTotal string   `json:"traffic_total,omitempty"` // 总流量
+    ExpireAt     string   `json:"expire_at,omitempty"`     // 套餐到期
+    Website      string   `json:"website,omitempty"`       // 官网
+    Notes        []string `json:"notes,omitempty"`         // 其他信息，如流量重置日期
 }
 
 // Node 节点信息
@@ -21,13 +102,49 @@ type Node struct {
     Address         string  `json:"address"`
     Port            int     `json:"port"`
     Protocol        string  `json:"protocol"`
+    Region          string  `json:"region"`          // 地区二位代码，如 HK、JP
     SubscriptionID  string  `json:"subscription_id"`
     Group           string  `json:"group"`
+    Tags            []string `json:"tags,omitempty"` // 继承自所属订阅的标签
+    Params          map[string]interface{} `json:"params,omitempty"` // 代理参数（密码、UUID、传输设置等，OpenClash字段）
     Latency         int     `json:"latency"`         // 延迟(ms)
     DownloadSpeed   float64 `json:"download_speed"`  // 下载速度(MB/s)
+    TestStatus      string  `json:"test_status,omitempty"` // 最近一次测试状态，为空表示未测试
+    TestError       string  `json:"test_error,omitempty"`  // 最近一次测试的错误信息
+    LatencyTestedAt time.Time `json:"latency_tested_at"`   // 最近一次延迟测试时间
+    SpeedTestedAt   time.Time `json:"speed_tested_at"`     // 最近一次下载测试时间
     LastTestedAt    time.Time `json:"last_tested_at"`
 }
 
+// 节点测试状态
+const (
+    TestStatusOK                = "ok"                 // 延迟和下载测试均已完成
+    TestStatusSkippedLatency    = "skipped_latency"    // 延迟超过阈值，未进行下载测试
+    TestStatusSkippedBudget     = "skipped_budget"     // 流量预算已用完，未进行下载测试
+    TestStatusTimeout           = "timeout"            // 连接或下载超时
+    TestStatusConnectionRefused = "connection_refused" // 连接被拒绝
+    TestStatusHandshakeFailed   = "handshake_failed"   // TLS握手失败
+    TestStatusFailed            = "failed"             // 其他错误
+)
+
+// TestFailed 最近一次测试是否失败
+func (n *Node) TestFailed() bool {
+    switch n.TestStatus {
+    case TestStatusTimeout, TestStatusConnectionRefused, TestStatusHandshakeFailed, TestStatusFailed:
+        return true
+    }
+    return false
+}
+
+// SpeedMeasured 是否测得下载速度，未进行下载测试的节点与0MB/s的节点区分开
+func (n *Node) SpeedMeasured() bool {
+    if n.TestStatus == "" {
+        // 兼容没有测试状态的旧数据
+        return !n.LastTestedAt.IsZero()
+    }
+    return n.TestStatus == TestStatusOK
+}
+
 // TestResult 节点测试结果
 type TestResult struct {
     NodeID        string    `json:"node_id"`
@@ -36,25 +153,80 @@ type TestResult struct {
     TestedAt     time.Time `json:"tested_at"`
 }
 
+// NodeQuery 节点查询条件（列表、筛选、生成共用）
+type NodeQuery struct {
+    IncludeAlias    string   `json:"include_alias" form:"include_alias"`       // 别名匹配(正则)
+    ExcludeAlias    string   `json:"exclude_alias" form:"exclude_alias"`       // 别名排除(正则)
+    Types           []string `json:"types" form:"types"`                       // 节点类型
+    Protocols       []string `json:"protocols" form:"protocols"`               // 传输协议
+    Regions         []string `json:"regions" form:"regions"`                   // 地区二位代码
+    SubscriptionIDs []string `json:"subscription_ids" form:"subscription_ids"` // 订阅ID
+    Tags            []string `json:"tags" form:"tags"`                         // 订阅标签，匹配任一标签即可
+    Ports           []string `json:"ports" form:"ports"`                       // 端口或端口范围，如 443、8000-9000
+    Tested          *bool    `json:"tested" form:"tested"`                     // 是否已测速
+    TestedWithin    string   `json:"tested_within" form:"tested_within"`       // 最近测速时间范围，如 6h
+}
+
 // FilterCondition 节点筛选条件
 type FilterCondition struct {
+    NodeQuery
     MaxLatency       int     `json:"max_latency"`        // 最大延迟(ms)
     MinDownloadSpeed float64 `json:"min_download_speed"` // 最小下载速度(MB/s)
 }
 
+// RenameRule 节点重命名正则替换规则
+type RenameRule struct {
+    Pattern string `json:"pattern"` // 正则表达式
+    Replace string `json:"replace"` // 替换内容，支持 $1 引用
+}
+
+// RenameOptions 节点重命名选项
+type RenameOptions struct {
+    Rules    []RenameRule `json:"rules"`    // 正则替换规则，按顺序执行
+    Template string       `json:"template"` // 命名模板，如 "{flag} {region} {provider} {index}"
+    AddFlag  bool         `json:"add_flag"` // 在名称前插入地区旗帜
+}
+
+// GenerateResult 订阅生成结果
+type GenerateResult struct {
+    FileName     string    `json:"file_name"`     // 文件名
+    FileURL      string    `json:"file_url"`      // 订阅文件URL
+    NodeCount    int       `json:"node_count"`    // 节点总数
+    GenerateTime time.Time `json:"generate_time"` // 生成时间
+}
+
 // MergeResult 订阅整合结果
 type MergeResult struct {
-    Timestamp  time.Time `json:"timestamp"`  // 整合时间
-    FileURL    string    `json:"file_url"`   // 订阅文件URL
-    NodeCount  int       `json:"node_count"` // 节点总数
+    Timestamp  time.Time    `json:"timestamp"`  // 整合时间
+    FileName   string       `json:"file_name"`  // 文件名
+    FileURL    string       `json:"file_url"`   // 订阅文件URL
+    NodeCount  int          `json:"node_count"` // 节点总数
+    Dedup      *DedupReport `json:"dedup"`      // 去重报告
+}
+
+// DedupEntry 去重记录
+type DedupEntry struct {
+    NodeID     string `json:"node_id"`      // 被移除的节点ID
+    Alias      string `json:"alias"`        // 被移除的节点名称
+    KeptNodeID string `json:"kept_node_id"` // 保留的节点ID
+    KeptAlias  string `json:"kept_alias"`   // 保留的节点名称
+    Reason     string `json:"reason"`       // 去重原因
+}
+
+// DedupReport 去重报告
+type DedupReport struct {
+    Strategy string        `json:"strategy"` // 去重策略
+    Kept     int           `json:"kept"`     // 保留节点数
+    Removed  []*DedupEntry `json:"removed"`  // 移除的重复节点
 }
 
 // ImportResult 节点导入结果
 type ImportResult struct {
-    TotalCount     int       `json:"total_count"`      // 总节点数
-    ImportedCount  int       `json:"imported_count"`   // 成功导入数
-    DuplicateCount int       `json:"duplicate_count"`  // 重复节点数
-    Nodes          []*Node   `json:"nodes"`            // 导入的节点列表
+    TotalCount     int          `json:"total_count"`      // 总节点数
+    ImportedCount  int          `json:"imported_count"`   // 成功导入数
+    DuplicateCount int          `json:"duplicate_count"`  // 重复节点数
+    Nodes          []*Node      `json:"nodes"`            // 导入的节点列表
+    Dedup          *DedupReport `json:"dedup"`            // 去重报告
 }
 
 // NodeList 节点列表（支持分页）
@@ -67,6 +239,7 @@ type NodeList struct {
 
 // NodeListQuery 节点列表查询参数
 type NodeListQuery struct {
+    NodeQuery
     Page     int    `form:"page" binding:"required,min=1"`
     PageSize int    `form:"page_size" binding:"required,min=10,max=100"`
     Type     string `form:"type"`       // 节点类型筛选
@@ -78,16 +251,57 @@ type SpeedTestResult struct {
     LatencyTested   int       `json:"latency_tested"`    // 延迟测速节点数
     LatencyDropped  int       `json:"latency_dropped"`   // 延迟测速丢弃数
     SpeedTested     int       `json:"speed_tested"`      // 下载测速节点数
+    BudgetSkipped   int       `json:"budget_skipped"`    // 因流量预算不足跳过下载测试的节点数
+    BytesConsumed   int64     `json:"bytes_consumed"`    // 下载测试消耗的本机流量(字节)
+    BudgetScope     string    `json:"budget_scope"`      // 流量预算的统计范围，固定为 host：下载测试不经过节点，不消耗订阅流量
     Progress        float64   `json:"progress"`          // 测速进度(0-100)
-    TestedNodes     []*Node   `json:"tested_nodes"`      // 已测速节点
+    TestedNodes     []*Node   `json:"tested_nodes"`      // 已完成测试的节点，包括失败和跳过下载测试的节点
+    StatusCounts    map[string]int  `json:"status_counts"` // 各测试状态的节点数
+    Stats           *SpeedTestStats `json:"stats"`         // 测速统计
 }
 
+// BudgetScopeHost 下载测试由本机直接请求测速目标，不经过节点，流量预算只限制本机流量
+const BudgetScopeHost = "host"
+
 // SpeedTestConfig 测速配置
 type SpeedTestConfig struct {
-    MaxLatency      int     `json:"max_latency"`       // 延迟阈值(ms)
-    TestURL         string  `json:"test_url"`          // 下载测试URL
+    MaxLatency      int     `json:"max_latency"`       // 进入下载测试的延迟阈值(ms)
+    TestURL         string  `json:"test_url"`          // 下载测试URL，为空时使用配置的测速目标
     Timeout         int     `json:"timeout"`           // 超时时间(秒)
-    Concurrent      int     `json:"concurrent"`        // 并发数
+    Concurrent      int     `json:"concurrent"`        // 延迟测试并发数
+    SpeedConcurrent int     `json:"speed_concurrent"`  // 下载测试并发数
+    MaxBytes        int64   `json:"max_bytes"`         // 单次下载的字节上限
+    RunBudget       int64   `json:"run_budget"`        // 本轮测速的本机下载流量预算(字节)，0表示不限制
+}
+
+// Settings 系统设置（可在运行时修改的配置项）
+type Settings struct {
+    MaxLatency      int     `json:"max_latency" binding:"min=1"`              // 延迟上限(ms)
+    MinSpeed        float64 `json:"min_speed" binding:"min=0"`                // 速度下限(MB/s)
+    Concurrent      int     `json:"concurrent" binding:"min=1,max=100"`       // 延迟测试并发数
+    SpeedConcurrent int     `json:"speed_concurrent" binding:"min=1,max=100"` // 下载测试并发数
+    LatencyCutoff   int     `json:"latency_cutoff" binding:"min=1"`           // 延迟不超过该值(ms)的节点才进行下载测试
+    TestURL         string  `json:"test_url" binding:"required,url"`          // 下载测试URL
+    UpdateInterval  string  `json:"update_interval" binding:"required"`       // 订阅更新周期，如 24h
+    TestInterval    string  `json:"test_interval" binding:"required"`         // 节点检测周期，如 4h
+    UserAgent       string  `json:"user_agent" binding:"required"`            // 获取订阅时使用的UA
+}
+
+// 测速目标类型
+const (
+    TestTargetLatency  = "latency"  // 网络连通性探测
+    TestTargetDownload = "download" // 下载测试
+)
+
+// TestTargetStatus 测速目标及其健康状态
+type TestTargetStatus struct {
+    Kind      string    `json:"kind"`                 // 目标类型：latency/download
+    URL       string    `json:"url"`                  // 配置的URL
+    Weight    int       `json:"weight"`               // 权重，0表示仅作为备用
+    Healthy   bool      `json:"healthy"`              // 最近一次检查或使用是否成功
+    Latency   int       `json:"latency"`              // 最近一次健康检查耗时(ms)
+    LastCheck time.Time `json:"last_check"`           // 最近一次检查时间
+    LastError string    `json:"last_error,omitempty"` // 最近一次错误信息
 }
 
 // SpeedTestStats 测速统计
@@ -8,6 +8,7 @@ type TaskType string
 const (
 	TaskTypeSubscriptionUpdate TaskType = "subscription_update" // 订阅更新任务
 	TaskTypeNodeTest          TaskType = "node_test"           // 节点测速任务
+	TaskTypePipeline          TaskType = "pipeline"            // 完整流程任务（更新→整合→测速→筛选→生成）
 )
 
 // TaskStatus 定义任务状态
@@ -25,6 +26,8 @@ type Task struct {
 	Name        string     `json:"name"`         // 任务名称
 	Status      TaskStatus `json:"status"`       // 任务状态
 	Cron        string     `json:"cron"`         // Cron表达式
+	Default     bool       `json:"default"`      // 是否为根据配置自动创建的默认任务
+	Pipeline    *PipelineConfig `json:"pipeline,omitempty"` // 完整流程任务配置，为空时使用默认配置
 	LastRunTime time.Time  `json:"last_run_time"` // 上次运行时间
 	CreateTime  time.Time  `json:"create_time"`   // 创建时间
 	UpdateTime  time.Time  `json:"update_time"`   // 更新时间
@@ -38,4 +41,41 @@ type TaskResult struct {
 	Status      string    `json:"status"`        // 执行状态
 	Message     string    `json:"message"`       // 执行信息
 	Error       string    `json:"error"`         // 错误信息
+	Duration    int64     `json:"duration"`      // 耗时(ms)
+	Step        PipelineStep   `json:"step,omitempty"`   // 步骤名称，仅完整流程的步骤结果
+	Counts      map[string]int `json:"counts,omitempty"` // 步骤统计数据
+	Steps       []*TaskResult  `json:"steps,omitempty"`  // 完整流程各步骤结果
+}
+
+// PipelineStep 定义完整流程的步骤
+type PipelineStep string
+
+const (
+	PipelineStepUpdate   PipelineStep = "update"   // 订阅解析
+	PipelineStepMerge    PipelineStep = "merge"    // 节点汇总
+	PipelineStepTest     PipelineStep = "test"     // 节点检测
+	PipelineStepFilter   PipelineStep = "filter"   // 节点筛选
+	PipelineStepGenerate PipelineStep = "generate" // 生成订阅
+)
+
+// 步骤失败处理策略
+const (
+	PipelineAbortOnFailure    = "abort"    // 步骤失败后终止流程
+	PipelineContinueOnFailure = "continue" // 步骤失败后继续执行后续步骤
+)
+
+// PipelineConfig 定义完整流程任务配置
+type PipelineConfig struct {
+	Steps           []PipelineStep   `json:"steps"`            // 执行步骤，为空时依次执行全部步骤
+	SubscriptionIDs []string         `json:"subscription_ids"` // 参与的订阅，为空时为全部订阅
+	DedupStrategy   string           `json:"dedup_strategy"`   // 汇总时的去重策略，为空时使用配置文件
+	Filter          *FilterCondition `json:"filter"`           // 筛选条件，为空时使用配置文件中的阈值
+	Output          PipelineOutput   `json:"output"`           // 输出目标
+	OnFailure       string           `json:"on_failure"`       // 失败策略：abort/continue，默认abort
+}
+
+// PipelineOutput 定义完整流程的输出目标
+type PipelineOutput struct {
+	FileName string        `json:"file_name"` // 输出文件名，默认sub.yaml
+	Rename   RenameOptions `json:"rename"`    // 节点重命名选项
 } 
\ No newline at end of file
@@ -0,0 +1,317 @@
+package services
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "log"
+    "os"
+    "path/filepath"
+    "sort"
+    "subsmanager/config"
+    "subsmanager/internal/models"
+    "sync"
+    "time"
+
+    "golang.org/x/crypto/bcrypt"
+)
+
+const (
+    // SessionCookieName 登录会话Cookie名称
+    SessionCookieName = "subsmanager_session"
+    // API令牌前缀
+    apiTokenPrefix = "sm_"
+    // API令牌持久化文件名
+    tokensFileName = "tokens.json"
+)
+
+// session 登录会话，只保存在内存中，重启后需要重新登录
+type session struct {
+    username  string
+    expiresAt time.Time
+}
+
+// AuthService 管理员登录会话和API令牌
+type AuthService struct {
+    mu       sync.RWMutex
+    sessions map[string]*session         // 按会话ID哈希索引
+    tokens   map[string]*models.APIToken // 按令牌ID索引
+}
+
+// DefaultAuthService 全局认证服务
+var DefaultAuthService = &AuthService{
+    sessions: make(map[string]*session),
+    tokens:   make(map[string]*models.APIToken),
+}
+
+// InitAuth 加载API令牌；启用认证但未配置管理员密码时生成随机密码并写入配置
+func InitAuth() error {
+    if err := DefaultAuthService.LoadTokens(); err != nil {
+        return err
+    }
+    if !config.Get().Auth.Enabled || config.Get().Auth.PasswordHash != "" {
+        return nil
+    }
+
+    password := randomToken(12)
+    hash, err := HashPassword(password)
+    if err != nil {
+        return err
+    }
+    if err := config.Update(func(cfg *config.Config) error {
+        cfg.Auth.PasswordHash = hash
+        return nil
+    }); err != nil {
+        // 配置文件不可写时只在本次运行中生效
+        log.Printf("Failed to save generated admin password: %v", err)
+        if err := config.UpdateRuntime(func(cfg *config.Config) error {
+            cfg.Auth.PasswordHash = hash
+            return nil
+        }); err != nil {
+            return err
+        }
+    }
+    log.Printf("Generated admin password for user %q: %s", config.Get().Auth.Username, password)
+    return nil
+}
+
+// HashPassword 生成密码的bcrypt哈希
+func HashPassword(password string) (string, error) {
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil {
+        return "", fmt.Errorf("hash password failed: %v", err)
+    }
+    return string(hash), nil
+}
+
+// Login 校验管理员用户名和密码，成功后创建会话，返回会话ID和过期时间
+func (a *AuthService) Login(username, password string) (string, time.Time, error) {
+    cfg := config.Get().Auth
+    userOK := subtle.ConstantTimeCompare([]byte(username), []byte(cfg.Username)) == 1
+    // 用户名错误时仍然比较密码，避免通过响应时间判断用户名
+    passOK := bcrypt.CompareHashAndPassword([]byte(cfg.PasswordHash), []byte(password)) == nil
+    if !userOK || !passOK {
+        return "", time.Time{}, fmt.Errorf("invalid username or password")
+    }
+
+    id := randomToken(32)
+    expiresAt := time.Now().Add(config.Duration(cfg.SessionTTL, 24*time.Hour))
+
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.pruneSessions()
+    a.sessions[hashToken(id)] = &session{username: username, expiresAt: expiresAt}
+    return id, expiresAt, nil
+}
+
+// Logout 注销会话
+func (a *AuthService) Logout(sessionID string) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    delete(a.sessions, hashToken(sessionID))
+}
+
+// VerifySession 校验会话，登录的管理员具有全部权限
+func (a *AuthService) VerifySession(sessionID string) (*models.Principal, error) {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+
+    s, exists := a.sessions[hashToken(sessionID)]
+    if !exists || time.Now().After(s.expiresAt) {
+        return nil, fmt.Errorf("session expired or invalid")
+    }
+    return &models.Principal{Username: s.username, Scope: models.ScopeManage}, nil
+}
+
+// ChangePassword 校验原密码后修改管理员密码，并注销所有会话
+func (a *AuthService) ChangePassword(oldPassword, newPassword string) error {
+    if err := bcrypt.CompareHashAndPassword([]byte(config.Get().Auth.PasswordHash), []byte(oldPassword)); err != nil {
+        return fmt.Errorf("invalid password")
+    }
+    if len(newPassword) < 8 {
+        return fmt.Errorf("password must be at least 8 characters")
+    }
+
+    hash, err := HashPassword(newPassword)
+    if err != nil {
+        return err
+    }
+    if err := config.Update(func(cfg *config.Config) error {
+        cfg.Auth.PasswordHash = hash
+        return nil
+    }); err != nil {
+        return err
+    }
+
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.sessions = make(map[string]*session)
+    return nil
+}
+
+// pruneSessions 清理过期会话，调用方需持有锁
+func (a *AuthService) pruneSessions() {
+    now := time.Now()
+    for id, s := range a.sessions {
+        if now.After(s.expiresAt) {
+            delete(a.sessions, id)
+        }
+    }
+}
+
+// CreateToken 创建API令牌，返回令牌信息和令牌明文，明文只在创建时返回一次
+func (a *AuthService) CreateToken(name, scope string, ttl time.Duration) (*models.APIToken, string, error) {
+    if scope != models.ScopeRead && scope != models.ScopeManage {
+        return nil, "", fmt.Errorf("invalid scope: %s", scope)
+    }
+
+    raw := apiTokenPrefix + randomToken(32)
+    token := &models.APIToken{
+        ID:        fmt.Sprintf("token_%d", time.Now().UnixNano()),
+        Name:      name,
+        Scope:     scope,
+        Prefix:    raw[:len(apiTokenPrefix)+6],
+        Hash:      hashToken(raw),
+        CreatedAt: time.Now(),
+    }
+    if ttl > 0 {
+        token.ExpiresAt = token.CreatedAt.Add(ttl)
+    }
+
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.tokens[token.ID] = token
+    if err := a.saveTokens(); err != nil {
+        delete(a.tokens, token.ID)
+        return nil, "", err
+    }
+    return publicToken(token), raw, nil
+}
+
+// ListTokens 获取所有API令牌，按创建时间排序
+func (a *AuthService) ListTokens() []*models.APIToken {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+
+    tokens := make([]*models.APIToken, 0, len(a.tokens))
+    for _, token := range a.tokens {
+        tokens = append(tokens, publicToken(token))
+    }
+    sort.Slice(tokens, func(i, j int) bool {
+        return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+    })
+    return tokens
+}
+
+// DeleteToken 吊销API令牌
+func (a *AuthService) DeleteToken(id string) error {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    token, exists := a.tokens[id]
+    if !exists {
+        return fmt.Errorf("token not found: %s", id)
+    }
+    delete(a.tokens, id)
+    if err := a.saveTokens(); err != nil {
+        a.tokens[id] = token
+        return err
+    }
+    return nil
+}
+
+// VerifyToken 校验API令牌并记录使用时间
+func (a *AuthService) VerifyToken(raw string) (*models.Principal, error) {
+    hash := hashToken(raw)
+
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    for _, token := range a.tokens {
+        if subtle.ConstantTimeCompare([]byte(token.Hash), []byte(hash)) != 1 {
+            continue
+        }
+        if !token.ExpiresAt.IsZero() && time.Now().After(token.ExpiresAt) {
+            return nil, fmt.Errorf("token expired")
+        }
+        token.LastUsedAt = time.Now()
+        return &models.Principal{TokenID: token.ID, Scope: token.Scope}, nil
+    }
+    return nil, fmt.Errorf("invalid token")
+}
+
+// LoadTokens 从存储目录加载API令牌
+func (a *AuthService) LoadTokens() error {
+    data, err := os.ReadFile(a.tokensFile())
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil
+        }
+        return fmt.Errorf("failed to read tokens file: %v", err)
+    }
+
+    var tokens []*models.APIToken
+    if err := json.Unmarshal(data, &tokens); err != nil {
+        return fmt.Errorf("failed to parse tokens file: %v", err)
+    }
+
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    for _, token := range tokens {
+        a.tokens[token.ID] = token
+    }
+    return nil
+}
+
+// saveTokens 保存API令牌，调用方需持有锁
+//
+// 最近使用时间只在令牌增删时随之保存，避免每次请求都写文件。
+func (a *AuthService) saveTokens() error {
+    tokens := make([]*models.APIToken, 0, len(a.tokens))
+    for _, token := range a.tokens {
+        tokens = append(tokens, token)
+    }
+    sort.Slice(tokens, func(i, j int) bool {
+        return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+    })
+
+    data, err := json.MarshalIndent(tokens, "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to marshal tokens: %v", err)
+    }
+    // 令牌哈希仅允许当前用户读取
+    if err := os.WriteFile(a.tokensFile(), data, 0600); err != nil {
+        return fmt.Errorf("failed to write tokens file: %v", err)
+    }
+    return nil
+}
+
+// tokensFile API令牌持久化文件路径
+func (a *AuthService) tokensFile() string {
+    return filepath.Join(config.Get().Storage.Path, tokensFileName)
+}
+
+// publicToken 复制令牌信息，去掉哈希
+func publicToken(token *models.APIToken) *models.APIToken {
+    c := *token
+    c.Hash = ""
+    return &c
+}
+
+// hashToken 计算令牌的SHA-256哈希
+func hashToken(raw string) string {
+    sum := sha256.Sum256([]byte(raw))
+    return hex.EncodeToString(sum[:])
+}
+
+// randomToken 生成n字节随机数的十六进制字符串
+func randomToken(n int) string {
+    b := make([]byte, n)
+    if _, err := rand.Read(b); err != nil {
+        panic(fmt.Sprintf("crypto/rand failed: %v", err))
+    }
+    return hex.EncodeToString(b)
+}
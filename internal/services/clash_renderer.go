@@ -0,0 +1,95 @@
+package services
+
+import (
+    "sort"
+    "subsmanager/internal/models"
+
+    "gopkg.in/yaml.v3"
+)
+
+// 默认代理组名称
+const DefaultProxyGroupName = "节点选择"
+
+// ClashProxyGroup OpenClash代理组
+type ClashProxyGroup struct {
+    Name    string   `yaml:"name"`
+    Type    string   `yaml:"type"`
+    Proxies []string `yaml:"proxies"`
+}
+
+// ClashConfig OpenClash订阅文件结构
+type ClashConfig struct {
+    Proxies     []map[string]interface{} `yaml:"proxies"`
+    ProxyGroups []ClashProxyGroup        `yaml:"proxy-groups"`
+    Rules       []string                 `yaml:"rules"`
+}
+
+// renderClashConfig 将节点渲染为OpenClash订阅内容
+//
+// 节点带有订阅标签时，按标签生成代理组并加入默认代理组。
+func renderClashConfig(nodes []*models.Node) ([]byte, error) {
+    cfg := ClashConfig{
+        Proxies: make([]map[string]interface{}, 0, len(nodes)),
+        Rules:   []string{"MATCH," + DefaultProxyGroupName},
+    }
+
+    names := make([]string, 0, len(nodes))
+    used := map[string]bool{DefaultProxyGroupName: true}
+    tagged := make(map[string][]string)
+    for _, node := range nodes {
+        cfg.Proxies = append(cfg.Proxies, clashProxy(node))
+        names = append(names, node.Alias)
+        used[node.Alias] = true
+        for _, tag := range node.Tags {
+            tagged[tag] = append(tagged[tag], node.Alias)
+        }
+    }
+
+    tagGroups := tagProxyGroups(tagged, used)
+    groupNames := make([]string, 0, len(tagGroups)+len(names))
+    for _, group := range tagGroups {
+        groupNames = append(groupNames, group.Name)
+    }
+
+    cfg.ProxyGroups = append([]ClashProxyGroup{
+        {Name: DefaultProxyGroupName, Type: "select", Proxies: append(groupNames, names...)},
+    }, tagGroups...)
+
+    return yaml.Marshal(cfg)
+}
+
+// tagProxyGroups 按标签名称顺序生成代理组，与节点或其他代理组重名的标签跳过
+func tagProxyGroups(tagged map[string][]string, used map[string]bool) []ClashProxyGroup {
+    tags := make([]string, 0, len(tagged))
+    for tag := range tagged {
+        if !used[tag] {
+            tags = append(tags, tag)
+        }
+    }
+    sort.Strings(tags)
+
+    groups := make([]ClashProxyGroup, 0, len(tags))
+    for _, tag := range tags {
+        groups = append(groups, ClashProxyGroup{Name: tag, Type: "select", Proxies: tagged[tag]})
+    }
+    return groups
+}
+
+// clashProxy 将节点转换为OpenClash代理配置
+func clashProxy(node *models.Node) map[string]interface{} {
+    proxy := make(map[string]interface{}, len(node.Params)+4)
+    for k, v := range node.Params {
+        proxy[k] = v
+    }
+
+    proxy["name"] = node.Alias
+    proxy["type"] = node.Type
+    proxy["server"] = node.Address
+    proxy["port"] = node.Port
+
+    if _, ok := proxy["network"]; !ok && node.Type == "vmess" && node.Protocol != "" {
+        proxy["network"] = node.Protocol
+    }
+
+    return proxy
+}
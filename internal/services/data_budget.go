@@ -0,0 +1,65 @@
+package services
+
+import (
+    "errors"
+    "sync"
+)
+
+// 单次下载至少需要的字节数，预算剩余不足时不再测试，避免测得的速度失真
+const minDownloadBytes = 256 * 1024
+
+// ErrBudgetExhausted 流量预算已用完
+var ErrBudgetExhausted = errors.New("data budget exhausted")
+
+// DataBudget 单轮测速的流量预算
+//
+// 下载测试由本机直接请求测速目标，不经过节点，因此预算限制的是本机的下载流量，
+// 不代表订阅流量的消耗。下载开始前按字节上限预留流量，结束后按实际消耗归还
+// 未使用的部分，保证并发下载时总消耗不超过预算。
+type DataBudget struct {
+    mu       sync.Mutex
+    limit    int64 // 整轮预算，0表示不限制
+    reserved int64 // 已预留（含已消耗）
+    consumed int64 // 实际消耗
+}
+
+// NewDataBudget 创建流量预算，limit 为0表示不限制
+func NewDataBudget(limit int64) *DataBudget {
+    return &DataBudget{limit: limit}
+}
+
+// Reserve 为一次下载预留流量，返回本次最多可下载的字节数，预算不足时返回0
+func (b *DataBudget) Reserve(want int64) int64 {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    n := want
+    if b.limit > 0 && b.limit-b.reserved < n {
+        n = b.limit - b.reserved
+    }
+    if n < minDownloadBytes && n < want {
+        return 0
+    }
+
+    b.reserved += n
+    return n
+}
+
+// Release 下载结束后记录实际消耗，并归还未使用的预留流量
+func (b *DataBudget) Release(reserved, used int64) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    if used > reserved {
+        used = reserved
+    }
+    b.reserved -= reserved - used
+    b.consumed += used
+}
+
+// Consumed 获取实际消耗的流量
+func (b *DataBudget) Consumed() int64 {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return b.consumed
+}
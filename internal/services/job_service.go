@@ -0,0 +1,350 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "subsmanager/config"
+    "subsmanager/internal/models"
+    "sync"
+    "time"
+)
+
+// 后台任务事件类型
+const (
+    JobEventStatus   = "status"   // 状态变化
+    JobEventProgress = "progress" // 进度更新
+    JobEventNode     = "node"     // 单个节点测试完成
+    JobEventDone     = "done"     // 任务结束
+)
+
+// 订阅者事件缓冲区大小，缓冲区满时丢弃事件，避免慢客户端阻塞任务
+const jobEventBuffer = 256
+
+// JobFunc 后台任务执行函数，需在ctx取消后尽快返回
+type JobFunc func(ctx context.Context, h *JobHandle) (interface{}, error)
+
+// jobEntry 后台任务及其运行状态
+type jobEntry struct {
+    job       *models.Job
+    run       JobFunc
+    ctx       context.Context
+    cancel    context.CancelFunc
+    cancelled bool          // 是否已请求取消
+    done      chan struct{} // 任务结束时关闭
+}
+
+// JobManager 后台任务管理器
+//
+// 任务按提交顺序排队，同时执行的任务数不超过 jobs.max_concurrent；
+// 相同去重键的任务未结束时再次提交直接返回已有任务。
+type JobManager struct {
+    mu          sync.RWMutex
+    jobs        map[string]*jobEntry
+    queue       []string // 排队中的任务ID
+    running     int
+    subscribers map[string][]chan *models.JobEvent
+}
+
+// DefaultJobManager 全局后台任务管理器
+var DefaultJobManager = NewJobManager()
+
+// NewJobManager 创建后台任务管理器
+func NewJobManager() *JobManager {
+    return &JobManager{
+        jobs:        make(map[string]*jobEntry),
+        subscribers: make(map[string][]chan *models.JobEvent),
+    }
+}
+
+// JobHandle 任务执行过程中用于上报进度、部分结果和事件
+type JobHandle struct {
+    manager *JobManager
+    id      string
+}
+
+// Start 提交后台任务，返回任务快照
+//
+// key 不为空且存在相同key的未结束任务时，不再创建新任务，返回已有任务。
+func (m *JobManager) Start(jobType, key string, run JobFunc) *models.Job {
+    job, _ := m.start(jobType, key, run)
+    return job
+}
+
+// start 提交后台任务，返回任务快照以及是否创建了新任务
+func (m *JobManager) start(jobType, key string, run JobFunc) (*models.Job, bool) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if key != "" {
+        for _, e := range m.jobs {
+            if e.job.Key == key && !e.job.Finished() {
+                return copyJob(e.job), false
+            }
+        }
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    e := &jobEntry{
+        job: &models.Job{
+            ID:        fmt.Sprintf("job_%d", time.Now().UnixNano()),
+            Type:      jobType,
+            Key:       key,
+            Status:    models.JobStatusQueued,
+            CreatedAt: time.Now(),
+        },
+        run:    run,
+        ctx:    ctx,
+        cancel: cancel,
+        done:   make(chan struct{}),
+    }
+    m.jobs[e.job.ID] = e
+    m.queue = append(m.queue, e.job.ID)
+
+    m.prune()
+    m.schedule()
+    return copyJob(e.job), true
+}
+
+// Get 获取任务快照
+func (m *JobManager) Get(id string) (*models.Job, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    e, exists := m.jobs[id]
+    if !exists {
+        return nil, fmt.Errorf("job not found: %s", id)
+    }
+    return copyJob(e.job), nil
+}
+
+// List 按条件列出任务，按创建时间倒序
+func (m *JobManager) List(query models.JobQuery) []*models.Job {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    m.prune()
+    jobs := make([]*models.Job, 0, len(m.jobs))
+    for _, e := range m.jobs {
+        if query.Type != "" && e.job.Type != query.Type {
+            continue
+        }
+        if query.Status != "" && e.job.Status != query.Status {
+            continue
+        }
+        jobs = append(jobs, copyJob(e.job))
+    }
+    sort.Slice(jobs, func(i, j int) bool {
+        return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+    })
+    return jobs
+}
+
+// Cancel 取消任务：排队中的任务直接取消，执行中的任务通过context通知退出
+func (m *JobManager) Cancel(id string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    e, exists := m.jobs[id]
+    if !exists {
+        return fmt.Errorf("job not found: %s", id)
+    }
+    if e.job.Finished() {
+        return fmt.Errorf("job already finished: %s", id)
+    }
+
+    e.cancelled = true
+    e.cancel()
+    if e.job.Status == models.JobStatusQueued {
+        for i, qid := range m.queue {
+            if qid == id {
+                m.queue = append(m.queue[:i], m.queue[i+1:]...)
+                break
+            }
+        }
+        m.complete(e, nil, context.Canceled)
+    }
+    return nil
+}
+
+// Wait 等待任务结束，返回最终快照
+func (m *JobManager) Wait(id string) (*models.Job, error) {
+    m.mu.RLock()
+    e, exists := m.jobs[id]
+    m.mu.RUnlock()
+    if !exists {
+        return nil, fmt.Errorf("job not found: %s", id)
+    }
+
+    <-e.done
+    return m.Get(id)
+}
+
+// Subscribe 订阅任务事件，返回当前快照和事件通道
+//
+// 任务结束后通道关闭；已结束的任务返回已关闭的通道。调用方不再接收时需调用取消函数。
+func (m *JobManager) Subscribe(id string) (*models.Job, <-chan *models.JobEvent, func(), error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    e, exists := m.jobs[id]
+    if !exists {
+        return nil, nil, nil, fmt.Errorf("job not found: %s", id)
+    }
+
+    ch := make(chan *models.JobEvent, jobEventBuffer)
+    if e.job.Finished() {
+        close(ch)
+        return copyJob(e.job), ch, func() {}, nil
+    }
+
+    m.subscribers[id] = append(m.subscribers[id], ch)
+    cancel := func() {
+        m.mu.Lock()
+        defer m.mu.Unlock()
+        subs := m.subscribers[id]
+        for i, c := range subs {
+            if c == ch {
+                m.subscribers[id] = append(subs[:i], subs[i+1:]...)
+                close(ch)
+                break
+            }
+        }
+    }
+    return copyJob(e.job), ch, cancel, nil
+}
+
+// schedule 在并发上限内启动排队中的任务，调用方需持有锁
+func (m *JobManager) schedule() {
+    limit := config.Get().Jobs.MaxConcurrent
+    if limit <= 0 {
+        limit = 1
+    }
+
+    for m.running < limit && len(m.queue) > 0 {
+        e := m.jobs[m.queue[0]]
+        m.queue = m.queue[1:]
+
+        m.running++
+        e.job.Status = models.JobStatusRunning
+        e.job.StartedAt = time.Now()
+        m.publish(e.job.ID, &models.JobEvent{Event: JobEventStatus, Data: copyJob(e.job)})
+
+        go func(e *jobEntry) {
+            result, err := e.run(e.ctx, &JobHandle{manager: m, id: e.job.ID})
+
+            m.mu.Lock()
+            defer m.mu.Unlock()
+            m.running--
+            m.complete(e, result, err)
+            m.schedule()
+        }(e)
+    }
+}
+
+// complete 结束任务并通知订阅者，调用方需持有锁
+func (m *JobManager) complete(e *jobEntry, result interface{}, err error) {
+    job := e.job
+    job.FinishedAt = time.Now()
+    if result != nil {
+        job.Result = result
+    }
+    switch {
+    case e.cancelled:
+        job.Status = models.JobStatusCancelled
+        job.Error = "job cancelled"
+    case err != nil:
+        job.Status = models.JobStatusFailed
+        job.Error = err.Error()
+    default:
+        job.Status = models.JobStatusSucceeded
+        job.Progress = 100
+    }
+    e.cancel()
+
+    m.publish(job.ID, &models.JobEvent{Event: JobEventDone, Data: copyJob(job)})
+    for _, ch := range m.subscribers[job.ID] {
+        close(ch)
+    }
+    delete(m.subscribers, job.ID)
+    close(e.done)
+}
+
+// prune 按保留时长和数量清理已结束的任务，调用方需持有锁
+func (m *JobManager) prune() {
+    retention := config.Duration(config.Get().Jobs.Retention, 24*time.Hour)
+    finished := make([]*models.Job, 0)
+    for id, e := range m.jobs {
+        if !e.job.Finished() {
+            continue
+        }
+        if time.Since(e.job.FinishedAt) > retention {
+            delete(m.jobs, id)
+            continue
+        }
+        finished = append(finished, e.job)
+    }
+
+    max := config.Get().Jobs.MaxFinished
+    if max <= 0 || len(finished) <= max {
+        return
+    }
+    sort.Slice(finished, func(i, j int) bool {
+        return finished[i].FinishedAt.Before(finished[j].FinishedAt)
+    })
+    for _, job := range finished[:len(finished)-max] {
+        delete(m.jobs, job.ID)
+    }
+}
+
+// publish 向订阅者推送事件，调用方需持有锁
+func (m *JobManager) publish(id string, event *models.JobEvent) {
+    for _, ch := range m.subscribers[id] {
+        select {
+        case ch <- event:
+        default:
+        }
+    }
+}
+
+// Progress 更新任务进度、阶段和统计
+func (h *JobHandle) Progress(progress float64, stage string, counts map[string]int) {
+    h.manager.mu.Lock()
+    defer h.manager.mu.Unlock()
+
+    job := h.manager.jobs[h.id].job
+    job.Progress = progress
+    job.Stage = stage
+    job.Counts = counts
+    h.manager.publish(h.id, &models.JobEvent{Event: JobEventProgress, Data: map[string]interface{}{
+        "progress": progress,
+        "stage":    stage,
+        "counts":   counts,
+    }})
+}
+
+// SetResult 更新任务的部分结果，result 需为调用方不再修改的快照
+func (h *JobHandle) SetResult(result interface{}) {
+    h.manager.mu.Lock()
+    defer h.manager.mu.Unlock()
+    h.manager.jobs[h.id].job.Result = result
+}
+
+// Emit 推送自定义事件
+func (h *JobHandle) Emit(event string, data interface{}) {
+    h.manager.mu.Lock()
+    defer h.manager.mu.Unlock()
+    h.manager.publish(h.id, &models.JobEvent{Event: event, Data: data})
+}
+
+// copyJob 复制任务快照
+func copyJob(job *models.Job) *models.Job {
+    c := *job
+    if job.Counts != nil {
+        c.Counts = make(map[string]int, len(job.Counts))
+        for k, v := range job.Counts {
+            c.Counts[k] = v
+        }
+    }
+    return &c
+}
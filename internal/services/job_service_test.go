@@ -0,0 +1,175 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "subsmanager/internal/models"
+    "testing"
+)
+
+// waitForCancel 阻塞到任务被取消
+func waitForCancel(ctx context.Context, h *JobHandle) (interface{}, error) {
+    <-ctx.Done()
+    return nil, ctx.Err()
+}
+
+func TestJobManagerOutcome(t *testing.T) {
+    tests := []struct {
+        name     string
+        run      JobFunc
+        cancel   bool
+        status   models.JobStatus
+        err      string
+        result   interface{}
+        progress float64
+    }{
+        {
+            name:     "succeeded",
+            run:      func(ctx context.Context, h *JobHandle) (interface{}, error) { return "ok", nil },
+            status:   models.JobStatusSucceeded,
+            result:   "ok",
+            progress: 100,
+        },
+        {
+            name:   "failed",
+            run:    func(ctx context.Context, h *JobHandle) (interface{}, error) { return nil, errors.New("boom") },
+            status: models.JobStatusFailed,
+            err:    "boom",
+        },
+        {
+            name: "failed keeps partial result",
+            run: func(ctx context.Context, h *JobHandle) (interface{}, error) {
+                h.Progress(40, "testing", map[string]int{"tested": 2})
+                h.SetResult("partial")
+                return nil, errors.New("boom")
+            },
+            status:   models.JobStatusFailed,
+            err:      "boom",
+            result:   "partial",
+            progress: 40,
+        },
+        {
+            name:   "cancelled",
+            run:    waitForCancel,
+            cancel: true,
+            status: models.JobStatusCancelled,
+            err:    "job cancelled",
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            m := NewJobManager()
+            job := m.Start("test", "", tt.run)
+            if tt.cancel {
+                if err := m.Cancel(job.ID); err != nil {
+                    t.Fatalf("Cancel() error = %v", err)
+                }
+            }
+
+            got, err := m.Wait(job.ID)
+            if err != nil {
+                t.Fatalf("Wait() error = %v", err)
+            }
+            if got.Status != tt.status {
+                t.Errorf("status = %s, want %s", got.Status, tt.status)
+            }
+            if got.Error != tt.err {
+                t.Errorf("error = %q, want %q", got.Error, tt.err)
+            }
+            if got.Result != tt.result {
+                t.Errorf("result = %v, want %v", got.Result, tt.result)
+            }
+            if got.Progress != tt.progress {
+                t.Errorf("progress = %v, want %v", got.Progress, tt.progress)
+            }
+            if got.FinishedAt.IsZero() {
+                t.Error("FinishedAt not set")
+            }
+            if err := m.Cancel(job.ID); err == nil {
+                t.Error("Cancel() on finished job should fail")
+            }
+        })
+    }
+}
+
+func TestJobManagerQueue(t *testing.T) {
+    m := NewJobManager()
+
+    // 默认并发数为1，第一个任务执行期间其余任务排队
+    release := make(chan struct{})
+    first := m.Start("test", "first", func(ctx context.Context, h *JobHandle) (interface{}, error) {
+        <-release
+        return nil, nil
+    })
+    second := m.Start("test", "second", waitForCancel)
+    third := m.Start("other", "", func(ctx context.Context, h *JobHandle) (interface{}, error) { return nil, nil })
+
+    tests := []struct {
+        name string
+        key  string
+        want string
+    }{
+        {name: "running job", key: "first", want: first.ID},
+        {name: "queued job", key: "second", want: second.ID},
+    }
+    for _, tt := range tests {
+        t.Run("dedup "+tt.name, func(t *testing.T) {
+            if got := m.Start("test", tt.key, waitForCancel); got.ID != tt.want {
+                t.Errorf("Start(%q) = %s, want existing job %s", tt.key, got.ID, tt.want)
+            }
+        })
+    }
+
+    if got, _ := m.Get(second.ID); got.Status != models.JobStatusQueued {
+        t.Fatalf("second job status = %s, want queued", got.Status)
+    }
+    if err := m.Cancel(second.ID); err != nil {
+        t.Fatalf("Cancel() error = %v", err)
+    }
+    if got, _ := m.Get(second.ID); got.Status != models.JobStatusCancelled || !got.StartedAt.IsZero() {
+        t.Errorf("cancelled queued job = %s (started %v), want cancelled without starting", got.Status, got.StartedAt)
+    }
+
+    close(release)
+    for _, id := range []string{first.ID, third.ID} {
+        if got, err := m.Wait(id); err != nil || got.Status != models.JobStatusSucceeded {
+            t.Fatalf("Wait(%s) = %v, %v, want succeeded", id, got, err)
+        }
+    }
+
+    // 已结束的任务不再参与去重
+    if got := m.Start("test", "first", func(ctx context.Context, h *JobHandle) (interface{}, error) { return nil, nil }); got.ID == first.ID {
+        t.Error("Start() returned finished job for the same key")
+    } else if _, err := m.Wait(got.ID); err != nil {
+        t.Fatalf("Wait() error = %v", err)
+    }
+
+    listTests := []struct {
+        name  string
+        query models.JobQuery
+        want  int
+    }{
+        {name: "all", query: models.JobQuery{}, want: 4},
+        {name: "by type", query: models.JobQuery{Type: "other"}, want: 1},
+        {name: "by status", query: models.JobQuery{Status: models.JobStatusCancelled}, want: 1},
+        {name: "by type and status", query: models.JobQuery{Type: "test", Status: models.JobStatusSucceeded}, want: 2},
+    }
+    for _, tt := range listTests {
+        t.Run("list "+tt.name, func(t *testing.T) {
+            jobs := m.List(tt.query)
+            if len(jobs) != tt.want {
+                t.Fatalf("List() returned %d jobs, want %d", len(jobs), tt.want)
+            }
+            for i := 1; i < len(jobs); i++ {
+                if jobs[i].CreatedAt.After(jobs[i-1].CreatedAt) {
+                    t.Errorf("List() not sorted by creation time desc")
+                }
+            }
+        })
+    }
+
+    if _, err := m.Get("missing"); err == nil {
+        t.Error("Get() on missing job should fail")
+    }
+}
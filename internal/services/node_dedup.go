@@ -0,0 +1,195 @@
+package services
+
+import (
+    "context"
+    "crypto/sha1"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "net"
+    "sort"
+    "strings"
+    "subsmanager/internal/models"
+    "sync"
+    "time"
+)
+
+// 节点去重策略
+const (
+    DedupEndpoint           = "endpoint"            // 类型+地址+端口
+    DedupEndpointCredential = "endpoint_credential" // 类型+地址+端口+凭据
+    DedupConfigHash         = "config_hash"         // 完整配置哈希
+    DedupResolvedIP         = "resolved_ip"         // 解析后的IP+端口+凭据
+)
+
+// 域名解析的超时时间和并发数，避免个别解析缓慢的域名拖慢整合
+const (
+    dedupResolveTimeout    = 5 * time.Second
+    dedupResolveConcurrent = 16
+)
+
+// 去重原因描述
+var dedupReasons = map[string]string{
+    DedupEndpoint:           "same type, server and port",
+    DedupEndpointCredential: "same endpoint and credential",
+    DedupConfigHash:         "identical configuration",
+    DedupResolvedIP:         "same resolved IP, port and credential",
+}
+
+// nodeDeduper 节点去重器
+type nodeDeduper struct {
+    strategy string
+    resolved map[string]string // 域名解析缓存
+}
+
+// newNodeDeduper 创建节点去重器
+func newNodeDeduper(strategy string) (*nodeDeduper, error) {
+    if strategy == "" {
+        strategy = DedupEndpointCredential
+    }
+    if _, ok := dedupReasons[strategy]; !ok {
+        return nil, fmt.Errorf("unsupported dedup strategy: %s", strategy)
+    }
+    return &nodeDeduper{
+        strategy: strategy,
+        resolved: make(map[string]string),
+    }, nil
+}
+
+// dedupNodes 按策略去重，保留最先出现的节点，并返回去重报告
+func dedupNodes(nodes []*models.Node, strategy string) ([]*models.Node, *models.DedupReport, error) {
+    d, err := newNodeDeduper(strategy)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    report := &models.DedupReport{
+        Strategy: d.strategy,
+        Removed:  make([]*models.DedupEntry, 0),
+    }
+    if d.strategy == DedupResolvedIP {
+        d.resolveAll(nodes)
+    }
+
+    kept := make([]*models.Node, 0, len(nodes))
+    seen := make(map[string]*models.Node, len(nodes))
+
+    for _, node := range nodes {
+        key := d.key(node)
+        if first, ok := seen[key]; ok {
+            report.Removed = append(report.Removed, &models.DedupEntry{
+                NodeID:     node.ID,
+                Alias:      node.Alias,
+                KeptNodeID: first.ID,
+                KeptAlias:  first.Alias,
+                Reason:     dedupReasons[d.strategy],
+            })
+            continue
+        }
+        seen[key] = node
+        kept = append(kept, node)
+    }
+
+    report.Kept = len(kept)
+    return kept, report, nil
+}
+
+// key 计算节点去重键
+func (d *nodeDeduper) key(node *models.Node) string {
+    switch d.strategy {
+    case DedupEndpoint:
+        return fmt.Sprintf("%s-%s-%d", node.Type, strings.ToLower(node.Address), node.Port)
+    case DedupConfigHash:
+        return configHash(node)
+    case DedupResolvedIP:
+        return fmt.Sprintf("%s-%s-%d-%s", node.Type, d.resolve(node.Address), node.Port, nodeCredential(node))
+    default:
+        return fmt.Sprintf("%s-%s-%d-%s", node.Type, strings.ToLower(node.Address), node.Port, nodeCredential(node))
+    }
+}
+
+// resolveAll 并发解析节点中的域名并写入缓存
+func (d *nodeDeduper) resolveAll(nodes []*models.Node) {
+    hosts := make(map[string]bool)
+    for _, node := range nodes {
+        address := strings.ToLower(node.Address)
+        if _, ok := d.resolved[address]; !ok && address != "" && net.ParseIP(address) == nil {
+            hosts[address] = true
+        }
+    }
+
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    sem := make(chan struct{}, dedupResolveConcurrent)
+    for host := range hosts {
+        wg.Add(1)
+        go func(host string) {
+            defer wg.Done()
+            sem <- struct{}{}
+            defer func() { <-sem }()
+
+            ip := lookupFirstIP(host)
+            mu.Lock()
+            d.resolved[host] = ip
+            mu.Unlock()
+        }(host)
+    }
+    wg.Wait()
+}
+
+// resolve 获取域名解析结果，失败时返回原地址
+func (d *nodeDeduper) resolve(address string) string {
+    address = strings.ToLower(address)
+    if net.ParseIP(address) != nil {
+        return address
+    }
+    if ip, ok := d.resolved[address]; ok {
+        return ip
+    }
+    ip := lookupFirstIP(address)
+    d.resolved[address] = ip
+    return ip
+}
+
+// lookupFirstIP 在超时时间内解析域名，失败或超时返回原地址
+func lookupFirstIP(host string) string {
+    ctx, cancel := context.WithTimeout(context.Background(), dedupResolveTimeout)
+    defer cancel()
+
+    ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+    if err != nil || len(ips) == 0 {
+        return host
+    }
+    // 取排序后的第一个地址，保证多条A记录时结果稳定
+    addrs := make([]string, 0, len(ips))
+    for _, v := range ips {
+        addrs = append(addrs, v.IP.String())
+    }
+    sort.Strings(addrs)
+    return addrs[0]
+}
+
+// nodeCredential 获取节点凭据（UUID或密码）
+func nodeCredential(node *models.Node) string {
+    var parts []string
+    for _, k := range []string{"uuid", "password", "auth", "auth-str"} {
+        if v, ok := node.Params[k]; ok {
+            parts = append(parts, fmt.Sprint(v))
+        }
+    }
+    return strings.Join(parts, ":")
+}
+
+// configHash 计算节点完整配置哈希
+func configHash(node *models.Node) string {
+    // encoding/json 会对map按键排序，保证哈希稳定
+    data, _ := json.Marshal(struct {
+        Type    string                 `json:"type"`
+        Address string                 `json:"address"`
+        Port    int                    `json:"port"`
+        Params  map[string]interface{} `json:"params"`
+    }{node.Type, strings.ToLower(node.Address), node.Port, node.Params})
+
+    sum := sha1.Sum(data)
+    return hex.EncodeToString(sum[:])
+}
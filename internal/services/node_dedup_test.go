@@ -0,0 +1,171 @@
+package services
+
+import (
+    "reflect"
+    "sort"
+    "subsmanager/config"
+    "subsmanager/internal/models"
+    "testing"
+)
+
+func TestDedupNodes(t *testing.T) {
+    nodes := []*models.Node{
+        {ID: "a", Type: "vmess", Alias: "A", Address: "hk.example.com", Port: 443, Params: map[string]interface{}{"uuid": "u1", "network": "ws"}},
+        {ID: "b", Type: "vmess", Alias: "B", Address: "HK.example.com", Port: 443, Params: map[string]interface{}{"uuid": "u1", "network": "ws"}},
+        {ID: "c", Type: "vmess", Alias: "C", Address: "hk.example.com", Port: 443, Params: map[string]interface{}{"uuid": "u2", "network": "ws"}},
+        {ID: "d", Type: "vmess", Alias: "D", Address: "hk.example.com", Port: 443, Params: map[string]interface{}{"uuid": "u1", "network": "grpc"}},
+        {ID: "e", Type: "trojan", Alias: "E", Address: "hk.example.com", Port: 443, Params: map[string]interface{}{"password": "u1"}},
+        {ID: "f", Type: "vmess", Alias: "F", Address: "hk.example.com", Port: 8443, Params: map[string]interface{}{"uuid": "u1", "network": "ws"}},
+    }
+
+    tests := []struct {
+        strategy string
+        kept     []string
+        removed  map[string]string // 被移除的节点ID → 保留的节点ID
+    }{
+        {
+            strategy: DedupEndpoint,
+            kept:     []string{"a", "e", "f"},
+            removed:  map[string]string{"b": "a", "c": "a", "d": "a"},
+        },
+        {
+            strategy: DedupEndpointCredential,
+            kept:     []string{"a", "c", "e", "f"},
+            removed:  map[string]string{"b": "a", "d": "a"},
+        },
+        {
+            strategy: "",
+            kept:     []string{"a", "c", "e", "f"},
+            removed:  map[string]string{"b": "a", "d": "a"},
+        },
+        {
+            strategy: DedupConfigHash,
+            kept:     []string{"a", "c", "d", "e", "f"},
+            removed:  map[string]string{"b": "a"},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.strategy, func(t *testing.T) {
+            kept, report, err := dedupNodes(nodes, tt.strategy)
+            if err != nil {
+                t.Fatalf("dedupNodes() error = %v", err)
+            }
+            if got := nodeIDs(kept); !reflect.DeepEqual(got, tt.kept) {
+                t.Errorf("kept = %v, want %v", got, tt.kept)
+            }
+            if report.Kept != len(tt.kept) {
+                t.Errorf("report.Kept = %d, want %d", report.Kept, len(tt.kept))
+            }
+            removed := make(map[string]string, len(report.Removed))
+            for _, entry := range report.Removed {
+                removed[entry.NodeID] = entry.KeptNodeID
+                if entry.Reason == "" {
+                    t.Errorf("removed node %s has no reason", entry.NodeID)
+                }
+            }
+            if !reflect.DeepEqual(removed, tt.removed) {
+                t.Errorf("removed = %v, want %v", removed, tt.removed)
+            }
+        })
+    }
+}
+
+func TestDedupNodesUnsupportedStrategy(t *testing.T) {
+    if _, _, err := dedupNodes(nil, "by_name"); err == nil {
+        t.Error("dedupNodes() error = nil, want error")
+    }
+}
+
+func TestDedupResolvedIP(t *testing.T) {
+    d, err := newNodeDeduper(DedupResolvedIP)
+    if err != nil {
+        t.Fatalf("newNodeDeduper() error = %v", err)
+    }
+    // 预置解析结果，避免测试依赖网络
+    d.resolved["hk.example.com"] = "203.0.113.1"
+
+    creds := map[string]interface{}{"password": "p"}
+    tests := []struct {
+        name string
+        a, b *models.Node
+        same bool
+    }{
+        {
+            name: "domain resolves to the same IP",
+            a:    &models.Node{Type: "ss", Address: "HK.example.com", Port: 8388, Params: creds},
+            b:    &models.Node{Type: "ss", Address: "203.0.113.1", Port: 8388, Params: creds},
+            same: true,
+        },
+        {
+            name: "different port",
+            a:    &models.Node{Type: "ss", Address: "hk.example.com", Port: 8388, Params: creds},
+            b:    &models.Node{Type: "ss", Address: "203.0.113.1", Port: 8389, Params: creds},
+        },
+        {
+            name: "different credential",
+            a:    &models.Node{Type: "ss", Address: "hk.example.com", Port: 8388, Params: creds},
+            b:    &models.Node{Type: "ss", Address: "203.0.113.1", Port: 8388, Params: map[string]interface{}{"password": "q"}},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if same := d.key(tt.a) == d.key(tt.b); same != tt.same {
+                t.Errorf("same key = %v, want %v", same, tt.same)
+            }
+        })
+    }
+}
+
+func TestNodeCredential(t *testing.T) {
+    tests := []struct {
+        name   string
+        params map[string]interface{}
+        want   string
+    }{
+        {"none", nil, ""},
+        {"uuid", map[string]interface{}{"uuid": "u1", "alterId": 0}, "u1"},
+        {"password", map[string]interface{}{"password": "p"}, "p"},
+        {"hysteria auth", map[string]interface{}{"auth-str": "s"}, "s"},
+        {"multiple", map[string]interface{}{"uuid": "u1", "password": "p"}, "u1:p"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := nodeCredential(&models.Node{Params: tt.params}); got != tt.want {
+                t.Errorf("nodeCredential() = %q, want %q", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestDedupStrategiesMatchConfig(t *testing.T) {
+    var supported []string
+    for strategy := range dedupReasons {
+        supported = append(supported, strategy)
+    }
+    sort.Strings(supported)
+
+    configured := append([]string{}, config.DedupStrategies...)
+    sort.Strings(configured)
+    if !reflect.DeepEqual(supported, configured) {
+        t.Errorf("config.DedupStrategies = %v, want %v", configured, supported)
+    }
+}
+
+func TestDedupNodesResolvedIP(t *testing.T) {
+    nodes := []*models.Node{
+        {ID: "a", Type: "trojan", Address: "127.0.0.1", Port: 443, Params: map[string]interface{}{"password": "p"}},
+        {ID: "b", Type: "trojan", Address: "LOCALHOST", Port: 443, Params: map[string]interface{}{"password": "p"}},
+        {ID: "c", Type: "trojan", Address: "localhost", Port: 443, Params: map[string]interface{}{"password": "q"}},
+    }
+
+    kept, _, err := dedupNodes(nodes, DedupResolvedIP)
+    if err != nil {
+        t.Fatalf("dedupNodes() error = %v", err)
+    }
+    if got, want := nodeIDs(kept), []string{"a", "c"}; !reflect.DeepEqual(got, want) {
+        t.Errorf("kept = %v, want %v", got, want)
+    }
+}
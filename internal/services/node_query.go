@@ -0,0 +1,165 @@
+package services
+
+import (
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+    "subsmanager/internal/models"
+    "time"
+)
+
+// portRange 端口范围
+type portRange struct {
+    min int
+    max int
+}
+
+// nodeMatcher 编译后的节点查询条件
+type nodeMatcher struct {
+    include       *regexp.Regexp
+    exclude       *regexp.Regexp
+    types         map[string]bool
+    protocols     map[string]bool
+    regions       map[string]bool
+    subscriptions map[string]bool
+    tags          map[string]bool
+    ports         []portRange
+    tested        *bool
+    testedWithin  time.Duration
+}
+
+// newNodeMatcher 编译节点查询条件
+func newNodeMatcher(query models.NodeQuery) (*nodeMatcher, error) {
+    m := &nodeMatcher{
+        types:         toLowerSet(query.Types),
+        protocols:     toLowerSet(query.Protocols),
+        regions:       toLowerSet(query.Regions),
+        subscriptions: toSet(query.SubscriptionIDs),
+        tags:          toSet(query.Tags),
+        tested:        query.Tested,
+    }
+
+    var err error
+    if query.IncludeAlias != "" {
+        if m.include, err = regexp.Compile(query.IncludeAlias); err != nil {
+            return nil, fmt.Errorf("invalid include_alias: %v", err)
+        }
+    }
+    if query.ExcludeAlias != "" {
+        if m.exclude, err = regexp.Compile(query.ExcludeAlias); err != nil {
+            return nil, fmt.Errorf("invalid exclude_alias: %v", err)
+        }
+    }
+
+    for _, p := range query.Ports {
+        r, err := parsePortRange(p)
+        if err != nil {
+            return nil, err
+        }
+        m.ports = append(m.ports, r)
+    }
+
+    if query.TestedWithin != "" {
+        if m.testedWithin, err = time.ParseDuration(query.TestedWithin); err != nil {
+            return nil, fmt.Errorf("invalid tested_within: %v", err)
+        }
+    }
+
+    return m, nil
+}
+
+// Match 判断节点是否满足查询条件
+func (m *nodeMatcher) Match(node *models.Node) bool {
+    if m.include != nil && !m.include.MatchString(node.Alias) {
+        return false
+    }
+    if m.exclude != nil && m.exclude.MatchString(node.Alias) {
+        return false
+    }
+    if len(m.types) > 0 && !m.types[strings.ToLower(node.Type)] {
+        return false
+    }
+    if len(m.protocols) > 0 && !m.protocols[strings.ToLower(node.Protocol)] {
+        return false
+    }
+    if len(m.regions) > 0 && !m.regions[strings.ToLower(node.Region)] {
+        return false
+    }
+    if len(m.subscriptions) > 0 && !m.subscriptions[node.SubscriptionID] {
+        return false
+    }
+    if len(m.tags) > 0 && !m.matchTags(node.Tags) {
+        return false
+    }
+    if len(m.ports) > 0 && !m.matchPort(node.Port) {
+        return false
+    }
+
+    tested := !node.LastTestedAt.IsZero()
+    if m.tested != nil && *m.tested != tested {
+        return false
+    }
+    if m.testedWithin > 0 && (!tested || time.Since(node.LastTestedAt) > m.testedWithin) {
+        return false
+    }
+
+    return true
+}
+
+// matchPort 判断端口是否落在任一范围内
+func (m *nodeMatcher) matchPort(port int) bool {
+    for _, r := range m.ports {
+        if port >= r.min && port <= r.max {
+            return true
+        }
+    }
+    return false
+}
+
+// matchTags 判断节点是否带有任一查询标签
+func (m *nodeMatcher) matchTags(tags []string) bool {
+    return hasAnyTag(tags, m.tags)
+}
+
+// parsePortRange 解析端口范围，支持 443 与 8000-9000 两种写法
+func parsePortRange(s string) (portRange, error) {
+    s = strings.TrimSpace(s)
+    lo, hi := s, s
+    if i := strings.Index(s, "-"); i > 0 {
+        lo, hi = s[:i], s[i+1:]
+    }
+
+    from, err := strconv.Atoi(strings.TrimSpace(lo))
+    if err != nil {
+        return portRange{}, fmt.Errorf("invalid port range: %s", s)
+    }
+    to, err := strconv.Atoi(strings.TrimSpace(hi))
+    if err != nil || from < 1 || to > 65535 || to < from {
+        return portRange{}, fmt.Errorf("invalid port range: %s", s)
+    }
+
+    return portRange{min: from, max: to}, nil
+}
+
+// toSet 将字符串切片转换为集合
+func toSet(values []string) map[string]bool {
+    set := make(map[string]bool, len(values))
+    for _, v := range values {
+        if v = strings.TrimSpace(v); v != "" {
+            set[v] = true
+        }
+    }
+    return set
+}
+
+// toLowerSet 将字符串切片转换为小写集合
+func toLowerSet(values []string) map[string]bool {
+    set := make(map[string]bool, len(values))
+    for _, v := range values {
+        if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+            set[v] = true
+        }
+    }
+    return set
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+    "subsmanager/internal/models"
+    "testing"
+    "time"
+)
+
+func TestNodeMatcher(t *testing.T) {
+    tested := true
+    untested := false
+    node := &models.Node{
+        ID:             "node_1",
+        Type:           "vmess",
+        Alias:          "香港 01 | IPLC",
+        Port:           8443,
+        Protocol:       "ws",
+        Region:         "HK",
+        SubscriptionID: "sub_1",
+        Tags:           []string{"work", "fast"},
+        LastTestedAt:   time.Now().Add(-time.Hour),
+    }
+
+    tests := []struct {
+        name  string
+        query models.NodeQuery
+        want  bool
+    }{
+        {"empty query", models.NodeQuery{}, true},
+        {"include alias", models.NodeQuery{IncludeAlias: "香港|HK"}, true},
+        {"include alias miss", models.NodeQuery{IncludeAlias: "日本"}, false},
+        {"exclude alias", models.NodeQuery{ExcludeAlias: "IPLC"}, false},
+        {"type case insensitive", models.NodeQuery{Types: []string{"VMess"}}, true},
+        {"type miss", models.NodeQuery{Types: []string{"trojan", "ss"}}, false},
+        {"protocol", models.NodeQuery{Protocols: []string{"ws", "grpc"}}, true},
+        {"region", models.NodeQuery{Regions: []string{"hk"}}, true},
+        {"region miss", models.NodeQuery{Regions: []string{"JP"}}, false},
+        {"subscription", models.NodeQuery{SubscriptionIDs: []string{"sub_2", "sub_1"}}, true},
+        {"subscription miss", models.NodeQuery{SubscriptionIDs: []string{"sub_2"}}, false},
+        {"any tag", models.NodeQuery{Tags: []string{"home", "fast"}}, true},
+        {"tag miss", models.NodeQuery{Tags: []string{"home"}}, false},
+        {"single port", models.NodeQuery{Ports: []string{"8443"}}, true},
+        {"port range", models.NodeQuery{Ports: []string{"443", "8000-9000"}}, true},
+        {"port miss", models.NodeQuery{Ports: []string{"443"}}, false},
+        {"tested", models.NodeQuery{Tested: &tested}, true},
+        {"untested", models.NodeQuery{Tested: &untested}, false},
+        {"tested within", models.NodeQuery{TestedWithin: "6h"}, true},
+        {"tested too long ago", models.NodeQuery{TestedWithin: "30m"}, false},
+        {"all conditions", models.NodeQuery{Types: []string{"vmess"}, Regions: []string{"HK"}, Tags: []string{"work"}, Ports: []string{"8000-9000"}}, true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            m, err := newNodeMatcher(tt.query)
+            if err != nil {
+                t.Fatalf("newNodeMatcher() error = %v", err)
+            }
+            if got := m.Match(node); got != tt.want {
+                t.Errorf("Match() = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestNodeMatcherInvalidQuery(t *testing.T) {
+    tests := []struct {
+        name  string
+        query models.NodeQuery
+    }{
+        {"include alias", models.NodeQuery{IncludeAlias: "("}},
+        {"exclude alias", models.NodeQuery{ExcludeAlias: "[a-"}},
+        {"port", models.NodeQuery{Ports: []string{"https"}}},
+        {"reversed port range", models.NodeQuery{Ports: []string{"9000-8000"}}},
+        {"tested within", models.NodeQuery{TestedWithin: "yesterday"}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if _, err := newNodeMatcher(tt.query); err == nil {
+                t.Error("newNodeMatcher() error = nil, want error")
+            }
+        })
+    }
+}
+
+func TestParsePortRange(t *testing.T) {
+    tests := []struct {
+        input   string
+        want    portRange
+        wantErr bool
+    }{
+        {"443", portRange{443, 443}, false},
+        {" 8000-9000 ", portRange{8000, 9000}, false},
+        {"8000 - 9000", portRange{8000, 9000}, false},
+        {"9000-8000", portRange{}, true},
+        {"-443", portRange{}, true},
+        {"443-", portRange{}, true},
+        {"0", portRange{}, true},
+        {"1-65536", portRange{}, true},
+        {"", portRange{}, true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.input, func(t *testing.T) {
+            got, err := parsePortRange(tt.input)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("parsePortRange(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+            }
+            if got != tt.want {
+                t.Errorf("parsePortRange(%q) = %+v, want %+v", tt.input, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestQueryNodesSortedByID(t *testing.T) {
+    s := &SubscriptionService{
+        subscriptions: make(map[string]*models.Subscription),
+        nodes: map[string]*models.Node{
+            "node_3": {ID: "node_3", Region: "HK"},
+            "node_1": {ID: "node_1", Region: "HK"},
+            "node_2": {ID: "node_2", Region: "JP"},
+        },
+    }
+
+    nodes, err := s.QueryNodes(models.NodeQuery{Regions: []string{"HK"}})
+    if err != nil {
+        t.Fatalf("QueryNodes() error = %v", err)
+    }
+    if len(nodes) != 2 || nodes[0].ID != "node_1" || nodes[1].ID != "node_3" {
+        t.Errorf("QueryNodes() = %v, want [node_1 node_3]", nodeIDs(nodes))
+    }
+}
+
+// nodeIDs 节点ID列表，用于输出测试结果
+func nodeIDs(nodes []*models.Node) []string {
+    ids := make([]string, 0, len(nodes))
+    for _, node := range nodes {
+        ids = append(ids, node.ID)
+    }
+    return ids
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+    "subsmanager/internal/models"
+    "subsmanager/internal/utils"
+)
+
+// compiledRenameRule 编译后的重命名规则
+type compiledRenameRule struct {
+    re      *regexp.Regexp
+    replace string
+}
+
+// 连续空白
+var spacePattern = regexp.MustCompile(`\s+`)
+
+// renameNodes 按选项重命名节点，返回节点副本，不修改已保存的节点
+//
+// 模板支持的占位符：
+//   {name}     规则替换后的原名称
+//   {flag}     地区旗帜
+//   {region}   地区名称
+//   {code}     地区二位代码
+//   {provider} 订阅名称
+//   {type}     节点类型
+//   {index}    同一地区内的序号(两位)
+//
+// 无论是否提供选项，重名节点都会追加序号后缀，保证名称唯一。
+func renameNodes(nodes []*models.Node, opts models.RenameOptions, providers map[string]string) ([]*models.Node, error) {
+    rules := make([]compiledRenameRule, 0, len(opts.Rules))
+    for _, r := range opts.Rules {
+        re, err := regexp.Compile(r.Pattern)
+        if err != nil {
+            return nil, fmt.Errorf("invalid rename pattern %q: %v", r.Pattern, err)
+        }
+        rules = append(rules, compiledRenameRule{re: re, replace: r.Replace})
+    }
+
+    renamed := make([]*models.Node, 0, len(nodes))
+    regionIndex := make(map[string]int)
+    for _, node := range nodes {
+        name := node.Alias
+        for _, r := range rules {
+            name = r.re.ReplaceAllString(name, r.replace)
+        }
+
+        code := node.Region
+        if code == "" {
+            code = utils.DetectRegion(node.Alias)
+        }
+        flag := ""
+        if code != "" {
+            flag = utils.RegionFlag(code)
+        }
+
+        if opts.Template != "" {
+            regionIndex[code]++
+            name = strings.NewReplacer(
+                "{name}", name,
+                "{flag}", flag,
+                "{region}", utils.RegionName(code),
+                "{code}", code,
+                "{provider}", providers[node.SubscriptionID],
+                "{type}", node.Type,
+                "{index}", fmt.Sprintf("%02d", regionIndex[code]),
+            ).Replace(opts.Template)
+        }
+
+        if opts.AddFlag && flag != "" && !utils.HasRegionFlag(name) {
+            name = flag + " " + name
+        }
+
+        name = strings.TrimSpace(spacePattern.ReplaceAllString(name, " "))
+        if name == "" {
+            name = fmt.Sprintf("%s %s:%d", node.Type, node.Address, node.Port)
+        }
+
+        copied := *node
+        copied.Alias = name
+        renamed = append(renamed, &copied)
+    }
+
+    uniqueNodeNames(renamed)
+    return renamed, nil
+}
+
+// uniqueNodeNames 为重名节点追加序号后缀
+func uniqueNodeNames(nodes []*models.Node) {
+    // 预先占用所有原始名称，避免后缀与其他节点撞名
+    taken := make(map[string]bool, len(nodes))
+    for _, node := range nodes {
+        taken[node.Alias] = true
+    }
+
+    seen := make(map[string]int, len(nodes))
+    for _, node := range nodes {
+        base := node.Alias
+        seen[base]++
+        if seen[base] == 1 {
+            continue
+        }
+
+        for n := seen[base]; ; n++ {
+            candidate := fmt.Sprintf("%s %d", base, n)
+            if !taken[candidate] {
+                node.Alias = candidate
+                taken[candidate] = true
+                seen[base] = n
+                break
+            }
+        }
+    }
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+    "reflect"
+    "subsmanager/internal/models"
+    "testing"
+)
+
+func TestRenameNodes(t *testing.T) {
+    providers := map[string]string{"sub_1": "Alpha", "sub_2": "Beta"}
+    nodes := []*models.Node{
+        {ID: "n1", Type: "vmess", Alias: "香港 01 [倍率 1.0]", Region: "HK", SubscriptionID: "sub_1"},
+        {ID: "n2", Type: "trojan", Alias: "HK 02 [倍率 2.0]", Region: "HK", SubscriptionID: "sub_2"},
+        {ID: "n3", Type: "ss", Alias: "东京 01", SubscriptionID: "sub_1"},
+    }
+
+    tests := []struct {
+        name string
+        opts models.RenameOptions
+        want []string
+    }{
+        {
+            name: "no options",
+            want: []string{"香港 01 [倍率 1.0]", "HK 02 [倍率 2.0]", "东京 01"},
+        },
+        {
+            name: "rules",
+            opts: models.RenameOptions{Rules: []models.RenameRule{
+                {Pattern: `\s*\[.*?\]`, Replace: ""},
+                {Pattern: `^HK`, Replace: "香港"},
+            }},
+            want: []string{"香港 01", "香港 02", "东京 01"},
+        },
+        {
+            name: "template",
+            opts: models.RenameOptions{Template: "{flag} {region} {provider} {index}"},
+            want: []string{"🇭🇰 香港 Alpha 01", "🇭🇰 香港 Beta 02", "🇯🇵 日本 Alpha 01"},
+        },
+        {
+            name: "template with name code and type",
+            opts: models.RenameOptions{
+                Rules:    []models.RenameRule{{Pattern: `\s*\[.*?\]`, Replace: ""}},
+                Template: "{code}-{type} {name}",
+            },
+            want: []string{"HK-vmess 香港 01", "HK-trojan HK 02", "JP-ss 东京 01"},
+        },
+        {
+            name: "add flag",
+            opts: models.RenameOptions{AddFlag: true},
+            want: []string{"🇭🇰 香港 01 [倍率 1.0]", "🇭🇰 HK 02 [倍率 2.0]", "🇯🇵 东京 01"},
+        },
+        {
+            name: "add flag skips names with a flag",
+            opts: models.RenameOptions{Template: "{flag} {name}", AddFlag: true},
+            want: []string{"🇭🇰 香港 01 [倍率 1.0]", "🇭🇰 HK 02 [倍率 2.0]", "🇯🇵 东京 01"},
+        },
+        {
+            name: "duplicate names get a suffix",
+            opts: models.RenameOptions{Template: "{region}"},
+            want: []string{"香港", "香港 2", "日本"},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            renamed, err := renameNodes(nodes, tt.opts, providers)
+            if err != nil {
+                t.Fatalf("renameNodes() error = %v", err)
+            }
+            if got := nodeAliases(renamed); !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("renameNodes() = %q, want %q", got, tt.want)
+            }
+        })
+    }
+
+    // 重命名不修改原节点
+    if nodes[0].Alias != "香港 01 [倍率 1.0]" {
+        t.Errorf("renameNodes() modified the input node: %q", nodes[0].Alias)
+    }
+}
+
+func TestRenameNodesDetectRegion(t *testing.T) {
+    tests := []struct {
+        alias string
+        want  string
+    }{
+        {"印度尼西亚 01", "ID 印度尼西亚 01"},
+        {"印度 01", "IN 印度 01"},
+        {"Indonesia 01", "ID Indonesia 01"},
+        {"India 01", "IN India 01"},
+        {"香港-印度 中转", "HK 香港-印度 中转"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.alias, func(t *testing.T) {
+            nodes := []*models.Node{{Type: "ss", Alias: tt.alias}}
+            renamed, err := renameNodes(nodes, models.RenameOptions{Template: "{code} {name}"}, nil)
+            if err != nil {
+                t.Fatalf("renameNodes() error = %v", err)
+            }
+            if renamed[0].Alias != tt.want {
+                t.Errorf("renameNodes() = %q, want %q", renamed[0].Alias, tt.want)
+            }
+        })
+    }
+}
+
+func TestRenameNodesEmptyName(t *testing.T) {
+    nodes := []*models.Node{{Type: "ss", Alias: "剩余流量：10GB", Address: "1.2.3.4", Port: 8388}}
+    opts := models.RenameOptions{Rules: []models.RenameRule{{Pattern: `.*`, Replace: ""}}}
+
+    renamed, err := renameNodes(nodes, opts, nil)
+    if err != nil {
+        t.Fatalf("renameNodes() error = %v", err)
+    }
+    if want := "ss 1.2.3.4:8388"; renamed[0].Alias != want {
+        t.Errorf("renameNodes() = %q, want %q", renamed[0].Alias, want)
+    }
+}
+
+func TestRenameNodesInvalidPattern(t *testing.T) {
+    opts := models.RenameOptions{Rules: []models.RenameRule{{Pattern: "(", Replace: ""}}}
+    if _, err := renameNodes(nil, opts, nil); err == nil {
+        t.Error("renameNodes() error = nil, want error")
+    }
+}
+
+func TestUniqueNodeNames(t *testing.T) {
+    tests := []struct {
+        name  string
+        input []string
+        want  []string
+    }{
+        {"unique", []string{"a", "b"}, []string{"a", "b"}},
+        {"duplicates", []string{"a", "a", "a"}, []string{"a", "a 2", "a 3"}},
+        {"suffix taken by another node", []string{"a", "a", "a 2"}, []string{"a", "a 3", "a 2"}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            nodes := make([]*models.Node, 0, len(tt.input))
+            for _, alias := range tt.input {
+                nodes = append(nodes, &models.Node{Alias: alias})
+            }
+            uniqueNodeNames(nodes)
+            if got := nodeAliases(nodes); !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("uniqueNodeNames() = %q, want %q", got, tt.want)
+            }
+        })
+    }
+}
+
+// nodeAliases 节点名称列表，用于比较测试结果
+func nodeAliases(nodes []*models.Node) []string {
+    aliases := make([]string, 0, len(nodes))
+    for _, node := range nodes {
+        aliases = append(aliases, node.Alias)
+    }
+    return aliases
+}
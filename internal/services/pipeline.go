@@ -0,0 +1,280 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "subsmanager/config"
+    "subsmanager/internal/models"
+    "subsmanager/internal/utils"
+    "time"
+)
+
+// DefaultPipelineSteps 完整流程默认步骤：订阅解析 → 节点汇总 → 节点检测 → 节点筛选 → 生成订阅
+var DefaultPipelineSteps = []models.PipelineStep{
+    models.PipelineStepUpdate,
+    models.PipelineStepMerge,
+    models.PipelineStepTest,
+    models.PipelineStepFilter,
+    models.PipelineStepGenerate,
+}
+
+// 步骤执行状态
+const (
+    stepStatusSuccess = "success"
+    stepStatusFailed  = "failed"
+    stepStatusSkipped = "skipped"
+)
+
+// validatePipeline 校验完整流程配置
+func validatePipeline(cfg *models.PipelineConfig) error {
+    if cfg == nil {
+        return nil
+    }
+
+    seen := make(map[models.PipelineStep]bool, len(cfg.Steps))
+    for _, step := range cfg.Steps {
+        if !isPipelineStep(step) {
+            return fmt.Errorf("unsupported pipeline step: %s", step)
+        }
+        if seen[step] {
+            return fmt.Errorf("duplicate pipeline step: %s", step)
+        }
+        seen[step] = true
+    }
+
+    switch cfg.OnFailure {
+    case "", models.PipelineAbortOnFailure, models.PipelineContinueOnFailure:
+    default:
+        return fmt.Errorf("unsupported failure policy: %s", cfg.OnFailure)
+    }
+
+    if cfg.DedupStrategy != "" {
+        if _, err := newNodeDeduper(cfg.DedupStrategy); err != nil {
+            return err
+        }
+    }
+    if cfg.Filter != nil {
+        if _, err := newNodeMatcher(cfg.Filter.NodeQuery); err != nil {
+            return err
+        }
+    }
+    if cfg.Output.FileName != "" {
+        if err := validateOutputFile(cfg.Output.FileName); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// isPipelineStep 判断是否为支持的步骤
+func isPipelineStep(step models.PipelineStep) bool {
+    for _, s := range DefaultPipelineSteps {
+        if s == step {
+            return true
+        }
+    }
+    return false
+}
+
+// pipelineRun 一次完整流程的执行状态
+type pipelineRun struct {
+    ctx        context.Context
+    subService *SubscriptionService
+    cfg        models.PipelineConfig
+    ids        []string       // 参与的订阅
+    nodes      []*models.Node // 汇总、检测、筛选步骤输出的节点，供后续步骤使用
+    selected   bool           // nodes 是否已由前序步骤确定
+}
+
+// runPipeline 按任务配置依次执行各步骤，返回每个步骤的执行结果
+//
+// 失败策略为abort时，失败步骤之后的步骤记为skipped；ctx取消后剩余步骤均记为skipped。
+func runPipeline(ctx context.Context, subService *SubscriptionService, task *models.Task) ([]*models.TaskResult, error) {
+    run := &pipelineRun{ctx: ctx, subService: subService}
+    if task.Pipeline != nil {
+        run.cfg = *task.Pipeline
+    }
+    if len(run.cfg.Steps) == 0 {
+        run.cfg.Steps = DefaultPipelineSteps
+    }
+    if run.cfg.DedupStrategy == "" {
+        run.cfg.DedupStrategy = config.Get().Subscription.DedupStrategy
+    }
+    if run.cfg.Output.FileName == "" {
+        run.cfg.Output.FileName = DefaultOutputFile
+    }
+
+    run.ids = run.cfg.SubscriptionIDs
+    if len(run.ids) == 0 {
+        for _, sub := range subService.GetSubscriptions() {
+            run.ids = append(run.ids, sub.ID)
+        }
+    }
+
+    results := make([]*models.TaskResult, 0, len(run.cfg.Steps))
+    var firstErr error
+    for _, step := range run.cfg.Steps {
+        result := &models.TaskResult{
+            TaskID:    task.ID,
+            Step:      step,
+            StartTime: time.Now(),
+        }
+        results = append(results, result)
+
+        if ctx.Err() != nil || (firstErr != nil && run.cfg.OnFailure != models.PipelineContinueOnFailure) {
+            result.EndTime = result.StartTime
+            result.Status = stepStatusSkipped
+            continue
+        }
+
+        counts, message, err := run.execute(step)
+        result.EndTime = time.Now()
+        result.Duration = result.EndTime.Sub(result.StartTime).Milliseconds()
+        result.Counts = counts
+        result.Message = message
+        if err != nil {
+            result.Status = stepStatusFailed
+            result.Error = err.Error()
+            if firstErr == nil {
+                firstErr = fmt.Errorf("step %s failed: %v", step, err)
+            }
+            continue
+        }
+        result.Status = stepStatusSuccess
+    }
+
+    if firstErr == nil {
+        firstErr = ctx.Err()
+    }
+    return results, firstErr
+}
+
+// execute 执行单个步骤，返回统计数据和说明
+func (r *pipelineRun) execute(step models.PipelineStep) (map[string]int, string, error) {
+    switch step {
+    case models.PipelineStepUpdate:
+        return r.update()
+    case models.PipelineStepMerge:
+        return r.merge()
+    case models.PipelineStepTest:
+        return r.test()
+    case models.PipelineStepFilter:
+        return r.filter()
+    case models.PipelineStepGenerate:
+        return r.generate()
+    default:
+        return nil, "", fmt.Errorf("unsupported pipeline step: %s", step)
+    }
+}
+
+// update 订阅解析，个别订阅失败不视为步骤失败
+func (r *pipelineRun) update() (map[string]int, string, error) {
+    updated, skipped, err := r.subService.UpdateSubscriptions(r.ctx, r.ids)
+    counts := map[string]int{
+        "subscriptions": len(r.ids),
+        "updated":       updated,
+        "skipped":       skipped,
+        "failed":        len(r.ids) - updated - skipped,
+    }
+    if err != nil && updated == 0 {
+        return counts, "", err
+    }
+    if err != nil {
+        return counts, err.Error(), nil
+    }
+    return counts, "", nil
+}
+
+// merge 节点汇总，去重结果保留在内存中供后续步骤使用，不生成中间文件
+func (r *pipelineRun) merge() (map[string]int, string, error) {
+    nodes, report, err := r.subService.mergeNodes(r.ids, nil, r.cfg.DedupStrategy)
+    if err != nil {
+        return nil, "", err
+    }
+    r.nodes = nodes
+    r.selected = true
+    return map[string]int{
+        "nodes":   len(nodes),
+        "removed": len(report.Removed),
+    }, "", nil
+}
+
+// workingNodes 获取当前步骤处理的节点，前序步骤未确定节点时使用参与订阅的全部节点
+func (r *pipelineRun) workingNodes() ([]*models.Node, error) {
+    if r.selected {
+        return r.nodes, nil
+    }
+    return r.subService.QueryNodes(models.NodeQuery{SubscriptionIDs: r.ids})
+}
+
+// test 节点检测，检测完成后以最新的测速数据替换当前节点
+func (r *pipelineRun) test() (map[string]int, string, error) {
+    nodes, err := r.workingNodes()
+    if err != nil {
+        return nil, "", err
+    }
+
+    result, err := r.subService.testNodes(r.ctx, nodes, DefaultSpeedTestConfig(), nil)
+    if err != nil {
+        return nil, "", err
+    }
+
+    ids := make([]string, 0, len(nodes))
+    for _, node := range nodes {
+        ids = append(ids, node.ID)
+    }
+    r.nodes = r.subService.findNodes(ids)
+    r.selected = true
+
+    return map[string]int{
+        "total":           result.TotalCount,
+        "latency_tested":  result.LatencyTested,
+        "latency_dropped": result.LatencyDropped,
+        "speed_tested":    result.SpeedTested,
+        "budget_skipped":  result.BudgetSkipped,
+        "bytes_consumed":  int(result.BytesConsumed),
+    }, "", nil
+}
+
+// filter 节点筛选
+func (r *pipelineRun) filter() (map[string]int, string, error) {
+    var condition models.FilterCondition
+    if r.cfg.Filter != nil {
+        condition = *r.cfg.Filter
+    } else {
+        condition.MaxLatency = config.Get().Filter.MaxLatency
+        condition.MinDownloadSpeed = config.Get().Filter.MinSpeed
+    }
+
+    nodes, err := r.workingNodes()
+    if err != nil {
+        return nil, "", err
+    }
+    nodes, err = filterNodes(nodes, condition)
+    if err != nil {
+        return nil, "", err
+    }
+    utils.LogNodeFilter(condition.MaxLatency, condition.MinDownloadSpeed, len(nodes))
+    r.nodes = nodes
+    r.selected = true
+
+    counts := map[string]int{"nodes": len(nodes)}
+    if len(nodes) == 0 {
+        return counts, "", fmt.Errorf("no nodes passed the filter")
+    }
+    return counts, "", nil
+}
+
+// generate 生成订阅，前序步骤未确定节点时使用参与订阅的全部节点
+func (r *pipelineRun) generate() (map[string]int, string, error) {
+    nodes, err := r.workingNodes()
+    if err != nil {
+        return nil, "", err
+    }
+
+    fileName, err := r.subService.GenerateSubscriptionFile(nodes, r.cfg.Output.Rename, r.cfg.Output.FileName)
+    if err != nil {
+        return nil, "", err
+    }
+    return map[string]int{"nodes": len(nodes)}, fileName, nil
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+    "context"
+    "os"
+    "reflect"
+    "strings"
+    "subsmanager/config"
+    "subsmanager/internal/models"
+    "testing"
+)
+
+func TestValidatePipeline(t *testing.T) {
+    tests := []struct {
+        name    string
+        cfg     *models.PipelineConfig
+        wantErr bool
+    }{
+        {name: "nil", cfg: nil},
+        {name: "empty", cfg: &models.PipelineConfig{}},
+        {name: "default steps", cfg: &models.PipelineConfig{Steps: DefaultPipelineSteps}},
+        {name: "unknown step", cfg: &models.PipelineConfig{Steps: []models.PipelineStep{"upload"}}, wantErr: true},
+        {name: "duplicate step", cfg: &models.PipelineConfig{Steps: []models.PipelineStep{models.PipelineStepMerge, models.PipelineStepMerge}}, wantErr: true},
+        {name: "continue policy", cfg: &models.PipelineConfig{OnFailure: models.PipelineContinueOnFailure}},
+        {name: "unknown policy", cfg: &models.PipelineConfig{OnFailure: "retry"}, wantErr: true},
+        {name: "dedup strategy", cfg: &models.PipelineConfig{DedupStrategy: DedupEndpoint}},
+        {name: "unknown dedup strategy", cfg: &models.PipelineConfig{DedupStrategy: "fuzzy"}, wantErr: true},
+        {name: "bad filter", cfg: &models.PipelineConfig{Filter: &models.FilterCondition{NodeQuery: models.NodeQuery{Ports: []string{"0"}}}}, wantErr: true},
+        {name: "output file", cfg: &models.PipelineConfig{Output: models.PipelineOutput{FileName: "hk.yaml"}}},
+        {name: "output path traversal", cfg: &models.PipelineConfig{Output: models.PipelineOutput{FileName: "../hk.yaml"}}, wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if err := validatePipeline(tt.cfg); (err != nil) != tt.wantErr {
+                t.Errorf("validatePipeline() error = %v, wantErr %v", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestRunPipeline(t *testing.T) {
+    subs := []*models.Subscription{
+        {ID: "sub1", Name: "sub1", Source: models.SubscriptionSourceLocal},
+        {ID: "sub2", Name: "sub2", Source: models.SubscriptionSourceLocal},
+    }
+    nodes := []*models.Node{
+        ssNode("n1", "sub1", "hk.example.com", 443, "p1"),
+        ssNode("n2", "sub1", "jp.example.com", 443, "p2"),
+        ssNode("n3", "sub2", "hk.example.com", 443, "p1"), // 与 n1 重复
+        ssNode("n4", "sub2", "us.example.com", 8443, "p4"),
+    }
+
+    type stepWant struct {
+        status string
+        counts map[string]int
+    }
+    tests := []struct {
+        name    string
+        cfg     models.PipelineConfig
+        steps   []stepWant
+        output  string // 期望生成的文件，为空表示不生成
+        wantErr bool
+    }{
+        {
+            name: "update skips local subscriptions",
+            cfg:  models.PipelineConfig{Steps: []models.PipelineStep{models.PipelineStepUpdate}},
+            steps: []stepWant{
+                {stepStatusSuccess, map[string]int{"subscriptions": 2, "updated": 0, "skipped": 2, "failed": 0}},
+            },
+        },
+        {
+            name: "merge and generate",
+            cfg: models.PipelineConfig{
+                Steps:         []models.PipelineStep{models.PipelineStepMerge, models.PipelineStepGenerate},
+                DedupStrategy: DedupEndpointCredential,
+            },
+            steps: []stepWant{
+                {stepStatusSuccess, map[string]int{"nodes": 3, "removed": 1}},
+                {stepStatusSuccess, map[string]int{"nodes": 3}},
+            },
+            output: DefaultOutputFile,
+        },
+        {
+            name: "filter selected subscription",
+            cfg: models.PipelineConfig{
+                Steps:           []models.PipelineStep{models.PipelineStepMerge, models.PipelineStepFilter, models.PipelineStepGenerate},
+                SubscriptionIDs: []string{"sub2"},
+                Filter:          &models.FilterCondition{NodeQuery: models.NodeQuery{Ports: []string{"8000-9000"}}},
+                Output:          models.PipelineOutput{FileName: "us.yaml"},
+            },
+            steps: []stepWant{
+                {stepStatusSuccess, map[string]int{"nodes": 2, "removed": 0}},
+                {stepStatusSuccess, map[string]int{"nodes": 1}},
+                {stepStatusSuccess, map[string]int{"nodes": 1}},
+            },
+            output: "us.yaml",
+        },
+        {
+            name: "abort after empty filter",
+            cfg: models.PipelineConfig{
+                Steps:  []models.PipelineStep{models.PipelineStepMerge, models.PipelineStepFilter, models.PipelineStepGenerate},
+                Filter: &models.FilterCondition{NodeQuery: models.NodeQuery{Types: []string{"vmess"}}},
+            },
+            steps: []stepWant{
+                {stepStatusSuccess, map[string]int{"nodes": 3, "removed": 1}},
+                {stepStatusFailed, map[string]int{"nodes": 0}},
+                {stepStatusSkipped, nil},
+            },
+            wantErr: true,
+        },
+        {
+            name: "continue after failed merge",
+            cfg: models.PipelineConfig{
+                Steps:           []models.PipelineStep{models.PipelineStepMerge, models.PipelineStepGenerate},
+                SubscriptionIDs: []string{"sub1", "missing"},
+                OnFailure:       models.PipelineContinueOnFailure,
+            },
+            steps: []stepWant{
+                {stepStatusFailed, nil},
+                {stepStatusSuccess, map[string]int{"nodes": 2}},
+            },
+            output:  DefaultOutputFile,
+            wantErr: true,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestService(t, subs, nodes)
+            cfg := tt.cfg
+            task := &models.Task{ID: "task", Type: models.TaskTypePipeline, Pipeline: &cfg}
+
+            results, err := runPipeline(context.Background(), s, task)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("runPipeline() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            if len(results) != len(tt.steps) {
+                t.Fatalf("got %d step results, want %d", len(results), len(tt.steps))
+            }
+            for i, want := range tt.steps {
+                got := results[i]
+                if got.Step != tt.cfg.Steps[i] {
+                    t.Errorf("step %d = %s, want %s", i, got.Step, tt.cfg.Steps[i])
+                }
+                if got.Status != want.status {
+                    t.Errorf("step %s status = %s, want %s (error: %s)", got.Step, got.Status, want.status, got.Error)
+                }
+                if want.counts != nil && !reflect.DeepEqual(got.Counts, want.counts) {
+                    t.Errorf("step %s counts = %v, want %v", got.Step, got.Counts, want.counts)
+                }
+            }
+
+            // 节点汇总不再生成中间文件，只有 generate 步骤写出订阅
+            var files []string
+            entries, err := os.ReadDir(config.Get().Storage.Path)
+            if err != nil {
+                t.Fatalf("read storage dir: %v", err)
+            }
+            for _, entry := range entries {
+                if strings.HasSuffix(entry.Name(), ".yaml") {
+                    files = append(files, entry.Name())
+                }
+            }
+            var want []string
+            if tt.output != "" {
+                want = []string{tt.output}
+            }
+            if !reflect.DeepEqual(files, want) {
+                t.Errorf("yaml files = %v, want %v", files, want)
+            }
+        })
+    }
+}
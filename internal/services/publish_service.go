@@ -0,0 +1,294 @@
+package services
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "subsmanager/config"
+    "subsmanager/internal/models"
+    "subsmanager/internal/utils"
+    "sync"
+    "time"
+)
+
+const (
+    // 发布链接持久化文件名
+    publishedFileName = "published.json"
+    // 保留的访问记录数
+    maxPublishAccess = 1000
+    // 下载后延迟保存访问记录，下载频繁时合并写入
+    publishSaveDelay = 10 * time.Second
+)
+
+// PublishService 管理生成的订阅文件的下载链接
+//
+// 下载链接使用随机令牌，与管理API的认证相互独立，订阅客户端无需登录即可下载。
+// 链接可以按使用方分别创建、设置过期时间和吊销，每次下载都会记录访问日志。
+type PublishService struct {
+    mu        sync.RWMutex
+    links     map[string]*models.PublishedLink // 按链接ID索引
+    access    []*models.PublishAccess          // 访问记录，按时间顺序
+    saveTimer *time.Timer                      // 待执行的延迟保存
+}
+
+// DefaultPublishService 全局发布链接服务
+var DefaultPublishService = &PublishService{
+    links: make(map[string]*models.PublishedLink),
+}
+
+// Publish 获取输出的默认下载链接
+//
+// 每个输出只保留一个默认链接：文件重新生成或文件名变化时更新链接指向的文件，令牌保持不变，
+// 避免每次生成带时间戳的文件都新增永久链接。
+func (p *PublishService) Publish(output, fileName string) (*models.PublishedLink, error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    for _, link := range p.links {
+        if link.Output != output || link.Consumer != models.DefaultConsumer || !link.Active() {
+            continue
+        }
+        if link.FileName != fileName {
+            prev := link.FileName
+            link.FileName = fileName
+            if err := p.save(); err != nil {
+                link.FileName = prev
+                return nil, err
+            }
+        }
+        return copyLink(link), nil
+    }
+    return p.create(output, fileName, models.DefaultConsumer, 0)
+}
+
+// CreateLink 为使用方创建订阅文件的下载链接，ttl为0时永不过期
+func (p *PublishService) CreateLink(fileName, consumer string, ttl time.Duration) (*models.PublishedLink, error) {
+    if err := validateOutputFile(fileName); err != nil {
+        return nil, err
+    }
+    if _, err := os.Stat(filepath.Join(config.Get().Storage.Path, fileName)); err != nil {
+        return nil, fmt.Errorf("subscription file not found: %s", fileName)
+    }
+
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return p.create("", fileName, consumer, ttl)
+}
+
+// create 创建下载链接并保存，调用方需持有锁
+func (p *PublishService) create(output, fileName, consumer string, ttl time.Duration) (*models.PublishedLink, error) {
+    link := &models.PublishedLink{
+        ID:        fmt.Sprintf("link_%d", time.Now().UnixNano()),
+        Token:     randomToken(24),
+        FileName:  fileName,
+        Output:    output,
+        Consumer:  consumer,
+        CreatedAt: time.Now(),
+    }
+    if ttl > 0 {
+        link.ExpiresAt = link.CreatedAt.Add(ttl)
+    }
+
+    p.links[link.ID] = link
+    if err := p.save(); err != nil {
+        delete(p.links, link.ID)
+        return nil, err
+    }
+    return copyLink(link), nil
+}
+
+// ListLinks 获取下载链接，fileName不为空时只返回该文件的链接，按创建时间排序
+func (p *PublishService) ListLinks(fileName string) []*models.PublishedLink {
+    p.mu.RLock()
+    defer p.mu.RUnlock()
+
+    links := make([]*models.PublishedLink, 0, len(p.links))
+    for _, link := range p.links {
+        if fileName == "" || link.FileName == fileName {
+            links = append(links, copyLink(link))
+        }
+    }
+    sort.Slice(links, func(i, j int) bool {
+        return links[i].CreatedAt.Before(links[j].CreatedAt)
+    })
+    return links
+}
+
+// RevokeLink 吊销下载链接，吊销后保留链接信息以便查看访问记录
+func (p *PublishService) RevokeLink(id string) (*models.PublishedLink, error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    link, exists := p.links[id]
+    if !exists {
+        return nil, fmt.Errorf("link not found: %s", id)
+    }
+    if !link.RevokedAt.IsZero() {
+        return nil, fmt.Errorf("link already revoked: %s", id)
+    }
+
+    link.RevokedAt = time.Now()
+    if err := p.save(); err != nil {
+        link.RevokedAt = time.Time{}
+        return nil, err
+    }
+    return copyLink(link), nil
+}
+
+// Resolve 根据令牌获取订阅文件路径并记录访问，返回的状态码用于响应
+//
+// 令牌不存在时不记录访问；链接已吊销或过期时记录访问并返回403。
+func (p *PublishService) Resolve(token, ip, userAgent string) (string, int) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    var link *models.PublishedLink
+    for _, l := range p.links {
+        if l.Token == token {
+            link = l
+            break
+        }
+    }
+    if link == nil {
+        return "", http.StatusNotFound
+    }
+
+    status := http.StatusOK
+    path := filepath.Join(config.Get().Storage.Path, link.FileName)
+    switch {
+    case !link.Active():
+        status = http.StatusForbidden
+    default:
+        if _, err := os.Stat(path); err != nil {
+            status = http.StatusNotFound
+        }
+    }
+
+    p.access = append(p.access, &models.PublishAccess{
+        LinkID:    link.ID,
+        Consumer:  link.Consumer,
+        Time:      time.Now(),
+        IP:        ip,
+        UserAgent: userAgent,
+        Status:    status,
+    })
+    if len(p.access) > maxPublishAccess {
+        p.access = p.access[len(p.access)-maxPublishAccess:]
+    }
+    if status == http.StatusOK {
+        link.LastAccessAt = time.Now()
+        link.AccessCount++
+    }
+    p.scheduleSave()
+
+    if status != http.StatusOK {
+        return "", status
+    }
+    return path, status
+}
+
+// AccessLog 获取访问记录，linkID不为空时只返回该链接的记录，按时间倒序
+func (p *PublishService) AccessLog(linkID string) []*models.PublishAccess {
+    p.mu.RLock()
+    defer p.mu.RUnlock()
+
+    records := make([]*models.PublishAccess, 0)
+    for i := len(p.access) - 1; i >= 0; i-- {
+        if linkID == "" || p.access[i].LinkID == linkID {
+            records = append(records, p.access[i])
+        }
+    }
+    return records
+}
+
+// Load 从存储目录加载发布链接和访问记录
+func (p *PublishService) Load() error {
+    data, err := os.ReadFile(p.file())
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil
+        }
+        return fmt.Errorf("failed to read published links file: %v", err)
+    }
+
+    var stored struct {
+        Links  []*models.PublishedLink  `json:"links"`
+        Access []*models.PublishAccess `json:"access"`
+    }
+    if err := json.Unmarshal(data, &stored); err != nil {
+        return fmt.Errorf("failed to parse published links file: %v", err)
+    }
+
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    for _, link := range stored.Links {
+        // 旧版本的默认链接按文件名区分输出
+        if link.Consumer == models.DefaultConsumer && link.Output == "" {
+            link.Output = link.FileName
+        }
+        p.links[link.ID] = link
+    }
+    p.access = stored.Access
+    return nil
+}
+
+// scheduleSave 在 publishSaveDelay 后保存访问记录，已有待执行的保存时不重复安排，调用方需持有锁
+func (p *PublishService) scheduleSave() {
+    if p.saveTimer != nil {
+        return
+    }
+    p.saveTimer = time.AfterFunc(publishSaveDelay, func() {
+        p.mu.Lock()
+        defer p.mu.Unlock()
+        if p.saveTimer == nil {
+            return
+        }
+        if err := p.save(); err != nil {
+            utils.LogError("保存发布链接失败: %v", err)
+        }
+    })
+}
+
+// save 保存发布链接和访问记录，调用方需持有锁
+func (p *PublishService) save() error {
+    // 立即保存时一并写入尚未保存的访问记录
+    if p.saveTimer != nil {
+        p.saveTimer.Stop()
+        p.saveTimer = nil
+    }
+
+    links := make([]*models.PublishedLink, 0, len(p.links))
+    for _, link := range p.links {
+        links = append(links, link)
+    }
+    sort.Slice(links, func(i, j int) bool {
+        return links[i].CreatedAt.Before(links[j].CreatedAt)
+    })
+
+    data, err := json.MarshalIndent(struct {
+        Links  []*models.PublishedLink  `json:"links"`
+        Access []*models.PublishAccess `json:"access"`
+    }{links, p.access}, "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to marshal published links: %v", err)
+    }
+    // 文件中包含下载令牌，仅允许当前用户读取
+    if err := os.WriteFile(p.file(), data, 0600); err != nil {
+        return fmt.Errorf("failed to write published links file: %v", err)
+    }
+    return nil
+}
+
+// file 发布链接持久化文件路径
+func (p *PublishService) file() string {
+    return filepath.Join(config.Get().Storage.Path, publishedFileName)
+}
+
+// copyLink 复制链接快照
+func copyLink(link *models.PublishedLink) *models.PublishedLink {
+    c := *link
+    return &c
+}
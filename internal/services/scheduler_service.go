@@ -1,15 +1,34 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"subsmanager/config"
+	"subsmanager/internal/models"
 	"sync"
 	"time"
-	"context"
 
 	"github.com/robfig/cron/v3"
-	"SubsManagerV1/internal/models"
 )
 
+// 默认任务ID
+const (
+	DefaultUpdateTaskID   = "default_subscription_update"
+	DefaultTestTaskID     = "default_node_test"
+	DefaultPipelineTaskID = "default_pipeline"
+)
+
+// 任务持久化文件名
+const tasksFileName = "tasks.json"
+
+// DefaultSchedulerService 全局调度器服务，由main初始化
+var DefaultSchedulerService *SchedulerService
+
 // SchedulerService 定义调度器服务
 type SchedulerService struct {
 	cron          *cron.Cron
@@ -19,7 +38,6 @@ type SchedulerService struct {
 	statusService *StatusService
 	logService    *LogService
 	mu            sync.RWMutex
-	timeout       time.Duration // 任务超时时间
 }
 
 // NewSchedulerService 创建新的调度器服务
@@ -31,7 +49,6 @@ func NewSchedulerService(subService *SubscriptionService, statusService *StatusS
 		subService:    subService,
 		statusService: statusService,
 		logService:    logService,
-		timeout:       10 * time.Minute, // 默认超时时间10分钟
 	}
 }
 
@@ -55,6 +72,19 @@ func (s *SchedulerService) AddTask(task *models.Task) error {
 		return fmt.Errorf("task with ID %s already exists", task.ID)
 	}
 
+	if err := s.addTask(task); err != nil {
+		return err
+	}
+	return s.saveTasks()
+}
+
+// addTask 注册任务，调用方需持有锁
+//
+// 保存的是任务的副本，之后只在持有锁时修改，调用方传入的任务不受执行状态更新的影响。
+func (s *SchedulerService) addTask(task *models.Task) error {
+	stored := *task
+	task = &stored
+
 	// 根据任务类型创建对应的执行函数
 	var jobFunc func()
 	switch task.Type {
@@ -62,19 +92,26 @@ func (s *SchedulerService) AddTask(task *models.Task) error {
 		jobFunc = s.createSubscriptionUpdateJob(task)
 	case models.TaskTypeNodeTest:
 		jobFunc = s.createNodeTestJob(task)
+	case models.TaskTypePipeline:
+		if err := validatePipeline(task.Pipeline); err != nil {
+			return err
+		}
+		jobFunc = s.createPipelineJob(task)
 	default:
 		return fmt.Errorf("unsupported task type: %s", task.Type)
 	}
 
-	// 添加定时任务
-	entryID, err := s.cron.AddFunc(task.Cron, jobFunc)
-	if err != nil {
-		return fmt.Errorf("failed to add cron job: %v", err)
+	// 禁用的任务只保存，不加入调度
+	if task.Status != models.TaskStatusDisabled {
+		entryID, err := s.cron.AddFunc(task.Cron, jobFunc)
+		if err != nil {
+			return fmt.Errorf("failed to add cron job: %v", err)
+		}
+		s.taskEntries[task.ID] = entryID
 	}
 
 	// 保存任务信息
 	s.tasks[task.ID] = task
-	s.taskEntries[task.ID] = entryID
 
 	return nil
 }
@@ -84,12 +121,21 @@ func (s *SchedulerService) RemoveTask(taskID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	entryID, exists := s.taskEntries[taskID]
-	if !exists {
+	if err := s.removeTask(taskID); err != nil {
+		return err
+	}
+	return s.saveTasks()
+}
+
+// removeTask 注销任务，调用方需持有锁
+func (s *SchedulerService) removeTask(taskID string) error {
+	if _, exists := s.tasks[taskID]; !exists {
 		return fmt.Errorf("task with ID %s not found", taskID)
 	}
 
-	s.cron.Remove(entryID)
+	if entryID, ok := s.taskEntries[taskID]; ok {
+		s.cron.Remove(entryID)
+	}
 	delete(s.tasks, taskID)
 	delete(s.taskEntries, taskID)
 
@@ -98,10 +144,22 @@ func (s *SchedulerService) RemoveTask(taskID string) error {
 
 // UpdateTask 更新任务
 func (s *SchedulerService) UpdateTask(task *models.Task) error {
-	if err := s.RemoveTask(task.ID); err != nil {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, exists := s.tasks[task.ID]
+	if !exists {
+		return fmt.Errorf("task with ID %s not found", task.ID)
+	}
+	if err := s.removeTask(task.ID); err != nil {
 		return err
 	}
-	return s.AddTask(task)
+	if err := s.addTask(task); err != nil {
+		// 新配置无效时恢复原任务
+		s.addTask(old)
+		return err
+	}
+	return s.saveTasks()
 }
 
 // GetTask 获取任务信息
@@ -113,7 +171,8 @@ func (s *SchedulerService) GetTask(taskID string) (*models.Task, error) {
 	if !exists {
 		return nil, fmt.Errorf("task with ID %s not found", taskID)
 	}
-	return task, nil
+	c := *task
+	return &c, nil
 }
 
 // ListTasks 列出所有任务
@@ -123,65 +182,254 @@ func (s *SchedulerService) ListTasks() []*models.Task {
 
 	tasks := make([]*models.Task, 0, len(s.tasks))
 	for _, task := range s.tasks {
-		tasks = append(tasks, task)
+		c := *task
+		tasks = append(tasks, &c)
 	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].CreateTime.Before(tasks[j].CreateTime)
+	})
 	return tasks
 }
 
-// executeWithTimeout 带超时控制的任务执行
-func (s *SchedulerService) executeWithTimeout(task *models.Task, operation func() error) *models.TaskResult {
-	result := &models.TaskResult{
-		TaskID:    task.ID,
-		StartTime: time.Now(),
-		Status:    "running",
+// TaskResults 获取指定任务的执行记录，按时间倒序
+func (s *SchedulerService) TaskResults(taskID string) []*models.TaskResult {
+	results := make([]*models.TaskResult, 0)
+	for _, result := range s.statusService.GetTaskHistory() {
+		if result.TaskID == taskID {
+			results = append(results, result)
+		}
 	}
+	return results
+}
 
-	// 创建带超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
-	defer cancel()
+// LoadTasks 从存储目录加载已保存的任务
+func (s *SchedulerService) LoadTasks() error {
+	data, err := os.ReadFile(s.tasksFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read tasks file: %v", err)
+	}
 
-	// 创建错误通道
-	done := make(chan error)
+	var tasks []*models.Task
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return fmt.Errorf("failed to parse tasks file: %v", err)
+	}
 
-	// 在goroutine中执行任务
-	go func() {
-		done <- operation()
-	}()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// 等待任务完成或超时
-	select {
-	case err := <-done:
-		result.EndTime = time.Now()
-		if err != nil {
-			result.Status = "failed"
-			result.Error = err.Error()
-			// 记录失败日志
-			s.logService.Error("Task execution failed",
+	for _, task := range tasks {
+		if err := s.addTask(task); err != nil {
+			s.logService.Warning("Skip invalid task",
 				"taskId", task.ID,
-				"taskName", task.Name,
-				"taskType", task.Type,
-				"error", err.Error(),
-				"duration", result.EndTime.Sub(result.StartTime).String())
+				"error", err.Error())
+		}
+	}
+	return nil
+}
+
+// saveTasks 保存任务到存储目录，调用方需持有锁
+func (s *SchedulerService) saveTasks() error {
+	tasks := make([]*models.Task, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].CreateTime.Before(tasks[j].CreateTime)
+	})
+
+	data, err := json.MarshalIndent(tasks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal tasks: %v", err)
+	}
+	if err := os.WriteFile(s.tasksFile(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write tasks file: %v", err)
+	}
+	return nil
+}
+
+// tasksFile 任务持久化文件路径
+func (s *SchedulerService) tasksFile() string {
+	return filepath.Join(config.Get().Storage.Path, tasksFileName)
+}
+
+// EnsureDefaultTasks 根据配置的更新/检测周期维护默认任务
+//
+// 存在用户创建的任务时不再创建新的默认任务，但已有的默认任务仍随配置变更按新周期重新注册或移除。
+func (s *SchedulerService) EnsureDefaultTasks() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := defaultTasks(*config.Get())
+	existing := make(map[string]bool)
+	custom := false
+	for id, task := range s.tasks {
+		if task.Default {
+			existing[id] = true
 		} else {
-			result.Status = "success"
-			result.Message = fmt.Sprintf("Successfully executed task: %s", task.Name)
-			// 记录成功日志
-			s.logService.Info("Task execution succeeded",
-				"taskId", task.ID,
-				"taskName", task.Name,
-				"taskType", task.Type,
-				"duration", result.EndTime.Sub(result.StartTime).String())
+			custom = true
+		}
+	}
+	if custom {
+		for id := range wanted {
+			if !existing[id] {
+				delete(wanted, id)
+			}
+		}
+	}
+	changed := false
+
+	// 移除不再需要或周期已变化的默认任务
+	for id, task := range s.tasks {
+		if !task.Default {
+			continue
+		}
+		want, ok := wanted[id]
+		if ok && want.Cron == task.Cron && want.Type == task.Type {
+			delete(wanted, id)
+			continue
 		}
-	case <-ctx.Done():
-		result.EndTime = time.Now()
+		if ok {
+			want.CreateTime = task.CreateTime
+			want.LastRunTime = task.LastRunTime
+			want.Status = task.Status
+		}
+		if err := s.removeTask(id); err != nil {
+			return err
+		}
+		changed = true
+	}
+
+	for _, task := range wanted {
+		if err := s.addTask(task); err != nil {
+			return err
+		}
+		s.logService.Info("Default task registered",
+			"taskId", task.ID,
+			"taskType", task.Type,
+			"cron", task.Cron)
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return s.saveTasks()
+}
+
+// WatchConfig 配置中的周期变化时重新注册默认任务
+func (s *SchedulerService) WatchConfig() {
+	config.OnChange(func(old, cur config.Config) {
+		if old.Subscription.UpdateInterval == cur.Subscription.UpdateInterval &&
+			old.Subscription.TestInterval == cur.Subscription.TestInterval &&
+			old.Subscription.Pipeline == cur.Subscription.Pipeline {
+			return
+		}
+		if err := s.EnsureDefaultTasks(); err != nil {
+			s.logService.Error("Failed to update default tasks", "error", err.Error())
+		}
+	})
+}
+
+// defaultTasks 根据配置生成默认任务
+func defaultTasks(cfg config.Config) map[string]*models.Task {
+	now := time.Now()
+	newTask := func(id, name string, taskType models.TaskType, interval string) *models.Task {
+		return &models.Task{
+			ID:         id,
+			Type:       taskType,
+			Name:       name,
+			Status:     models.TaskStatusEnabled,
+			Cron:       "@every " + interval,
+			Default:    true,
+			CreateTime: now,
+			UpdateTime: now,
+		}
+	}
+
+	if cfg.Subscription.Pipeline {
+		return map[string]*models.Task{
+			DefaultPipelineTaskID: newTask(DefaultPipelineTaskID, "默认完整流程", models.TaskTypePipeline, cfg.Subscription.TestInterval),
+		}
+	}
+	return map[string]*models.Task{
+		DefaultUpdateTaskID: newTask(DefaultUpdateTaskID, "默认订阅更新", models.TaskTypeSubscriptionUpdate, cfg.Subscription.UpdateInterval),
+		DefaultTestTaskID:   newTask(DefaultTestTaskID, "默认节点检测", models.TaskTypeNodeTest, cfg.Subscription.TestInterval),
+	}
+}
+
+// errTaskTimeout 定时任务执行超时
+var errTaskTimeout = errors.New("task execution timed out")
+
+// executeWithTimeout 通过后台任务管理器执行任务，并进行超时控制
+//
+// 超时从后台任务开始执行时计算，排队时间不计入，超时后通过ctx通知任务退出。
+// key 相同的后台任务未结束时不会重复执行，而是等待已有任务的结果，已有任务不受本次超时控制。
+func (s *SchedulerService) executeWithTimeout(task *models.Task, key string, operation func(ctx context.Context) error) *models.TaskResult {
+	result := &models.TaskResult{
+		TaskID:    task.ID,
+		StartTime: time.Now(),
+		Status:    "running",
+	}
+
+	// 提交后台任务，超时上下文在任务开始执行时创建
+	timeout := taskTimeout(task.Type)
+	job, created := DefaultJobManager.start(models.JobTypeTask, key, func(jobCtx context.Context, h *JobHandle) (interface{}, error) {
+		ctx, cancel := context.WithTimeout(jobCtx, timeout)
+		defer cancel()
+
+		err := operation(ctx)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && jobCtx.Err() == nil {
+			return nil, errTaskTimeout
+		}
+		return nil, err
+	})
+	if !created {
+		s.logService.Info("Task joined an unfinished job",
+			"taskId", task.ID,
+			"taskName", task.Name,
+			"jobId", job.ID)
+	}
+
+	// 等待后台任务结束
+	finished, err := DefaultJobManager.Wait(job.ID)
+	if err == nil && finished.Status != models.JobStatusSucceeded {
+		err = fmt.Errorf("%s", finished.Error)
+	}
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime).Milliseconds()
+
+	switch {
+	case err == nil:
+		result.Status = "success"
+		result.Message = fmt.Sprintf("Successfully executed task: %s", task.Name)
+		// 记录成功日志
+		s.logService.Info("Task execution succeeded",
+			"taskId", task.ID,
+			"taskName", task.Name,
+			"taskType", task.Type,
+			"duration", result.EndTime.Sub(result.StartTime).String())
+	case err.Error() == errTaskTimeout.Error():
 		result.Status = "timeout"
-		result.Error = "task execution timed out"
+		result.Error = err.Error()
 		// 记录超时日志
 		s.logService.Warning("Task execution timed out",
 			"taskId", task.ID,
 			"taskName", task.Name,
 			"taskType", task.Type,
-			"timeout", s.timeout.String(),
+			"timeout", timeout.String(),
+			"duration", result.EndTime.Sub(result.StartTime).String())
+	default:
+		result.Status = "failed"
+		result.Error = err.Error()
+		// 记录失败日志
+		s.logService.Error("Task execution failed",
+			"taskId", task.ID,
+			"taskName", task.Name,
+			"taskType", task.Type,
+			"error", err.Error(),
 			"duration", result.EndTime.Sub(result.StartTime).String())
 	}
 
@@ -197,14 +445,12 @@ func (s *SchedulerService) createSubscriptionUpdateJob(task *models.Task) func()
 			"taskName", task.Name)
 
 		// 执行任务并记录结果
-		result := s.executeWithTimeout(task, s.subService.UpdateAllSubscriptions)
+		result := s.executeWithTimeout(task, string(models.TaskTypeSubscriptionUpdate), func(ctx context.Context) error {
+			return s.subService.UpdateAllSubscriptions(ctx)
+		})
 
-		// 更新任务状态
-		task.LastRunTime = result.StartTime
-		task.UpdateTime = result.EndTime
-
-		// 记录执行结果
-		s.statusService.AddTaskHistory(result)
+		// 更新任务状态并记录执行结果
+		s.recordRun(task.ID, result)
 	}
 }
 
@@ -217,20 +463,59 @@ func (s *SchedulerService) createNodeTestJob(task *models.Task) func() {
 			"taskName", task.Name)
 
 		// 执行任务并记录结果
-		result := s.executeWithTimeout(task, s.subService.TestAllNodes)
+		result := s.executeWithTimeout(task, SpeedTestJobKey, s.subService.TestAllNodes)
 
-		// 更新任务状态
-		task.LastRunTime = result.StartTime
-		task.UpdateTime = result.EndTime
+		// 更新任务状态并记录执行结果
+		s.recordRun(task.ID, result)
+	}
+}
 
-		// 记录执行结果
-		s.statusService.AddTaskHistory(result)
+// createPipelineJob 创建完整流程任务
+func (s *SchedulerService) createPipelineJob(task *models.Task) func() {
+	return func() {
+		// 记录任务开始日志
+		s.logService.Info("Starting pipeline task",
+			"taskId", task.ID,
+			"taskName", task.Name)
+
+		// 执行任务并记录结果，超时的流程同样记录已执行的步骤
+		var steps []*models.TaskResult
+		result := s.executeWithTimeout(task, string(models.TaskTypePipeline), func(ctx context.Context) error {
+			var err error
+			steps, err = runPipeline(ctx, s.subService, task)
+			return err
+		})
+		result.Steps = steps
+
+		// 更新任务状态并记录执行结果
+		s.recordRun(task.ID, result)
 	}
 }
 
-// SetTimeout 设置任务超时时间
-func (s *SchedulerService) SetTimeout(timeout time.Duration) {
+// recordRun 更新任务的运行时间并记录执行结果，任务已删除时只记录结果
+func (s *SchedulerService) recordRun(taskID string, result *models.TaskResult) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.timeout = timeout
-} 
\ No newline at end of file
+	if task, exists := s.tasks[taskID]; exists {
+		task.LastRunTime = result.StartTime
+		task.UpdateTime = result.EndTime
+		if err := s.saveTasks(); err != nil {
+			s.logService.Error("Failed to save tasks", "error", err.Error())
+		}
+	}
+	s.mu.Unlock()
+
+	s.statusService.AddTaskHistory(result)
+}
+
+// taskTimeout 按任务类型获取超时时间，测速和完整流程耗时较长，使用单独的超时配置
+func taskTimeout(taskType models.TaskType) time.Duration {
+	jobs := config.Get().Jobs
+	switch taskType {
+	case models.TaskTypeNodeTest:
+		return config.Duration(jobs.TestTimeout, 2*time.Hour)
+	case models.TaskTypePipeline:
+		return config.Duration(jobs.PipelineTimeout, 3*time.Hour)
+	default:
+		return config.Duration(jobs.UpdateTimeout, 10*time.Minute)
+	}
+}
\ No newline at end of file
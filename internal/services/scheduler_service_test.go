@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"subsmanager/config"
+	"subsmanager/internal/models"
+	"testing"
+	"time"
+)
+
+func TestExecuteWithTimeout(t *testing.T) {
+	logService, err := NewLogService(filepath.Join(t.TempDir(), "app.log"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &SchedulerService{logService: logService}
+	task := &models.Task{ID: "task", Name: "task", Type: models.TaskTypeSubscriptionUpdate}
+	t.Cleanup(config.Override(func(c *config.Config) { c.Jobs.UpdateTimeout = "100ms" }))
+
+	tests := []struct {
+		name      string
+		blocker   time.Duration // 先提交的占用并发的任务耗时，为0表示不提交
+		shared    bool          // 先提交的任务是否与定时任务使用相同的key
+		operation func(ctx context.Context) error
+		status    string
+	}{
+		{
+			name:      "success",
+			operation: func(ctx context.Context) error { return nil },
+			status:    "success",
+		},
+		{
+			name:      "timeout",
+			operation: func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() },
+			status:    "timeout",
+		},
+		{
+			name:      "queued time not counted",
+			blocker:   200 * time.Millisecond,
+			operation: func(ctx context.Context) error { time.Sleep(20 * time.Millisecond); return ctx.Err() },
+			status:    "success",
+		},
+		{
+			name:      "shared job not cancelled",
+			blocker:   200 * time.Millisecond,
+			shared:    true,
+			operation: func(ctx context.Context) error { t.Error("operation ran for a shared key"); return nil },
+			status:    "success",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := "scheduler-test-" + tt.name
+			var blocker *models.Job
+			if tt.blocker > 0 {
+				blockerKey := ""
+				if tt.shared {
+					blockerKey = key
+				}
+				blocker = DefaultJobManager.Start("test", blockerKey, func(ctx context.Context, h *JobHandle) (interface{}, error) {
+					select {
+					case <-time.After(tt.blocker):
+						return nil, nil
+					case <-ctx.Done():
+						return nil, ctx.Err()
+					}
+				})
+			}
+
+			result := s.executeWithTimeout(task, key, tt.operation)
+			if result.Status != tt.status {
+				t.Errorf("status = %s, want %s (error: %s)", result.Status, tt.status, result.Error)
+			}
+			if blocker != nil {
+				finished, err := DefaultJobManager.Wait(blocker.ID)
+				if err != nil || finished.Status != models.JobStatusSucceeded {
+					t.Errorf("blocking job = %v, %v, want succeeded", finished, err)
+				}
+			}
+		})
+	}
+}
+
+func TestEnsureDefaultTasks(t *testing.T) {
+	logService, err := NewLogService(filepath.Join(t.TempDir(), "app.log"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wanted := defaultTasks(*config.Get())
+	updateCron := wanted[DefaultUpdateTaskID].Cron
+	testCron := wanted[DefaultTestTaskID].Cron
+
+	task := func(id string, taskType models.TaskType, cron string, isDefault bool) *models.Task {
+		return &models.Task{ID: id, Name: id, Type: taskType, Status: models.TaskStatusEnabled, Cron: cron, Default: isDefault}
+	}
+	tests := []struct {
+		name     string
+		existing []*models.Task
+		want     map[string]string // 任务ID → cron
+	}{
+		{
+			name: "create defaults",
+			want: map[string]string{DefaultUpdateTaskID: updateCron, DefaultTestTaskID: testCron},
+		},
+		{
+			name:     "reconcile existing default alongside custom task",
+			existing: []*models.Task{task("custom", models.TaskTypeNodeTest, "@every 1h", false), task(DefaultUpdateTaskID, models.TaskTypeSubscriptionUpdate, "@every 48h", true)},
+			want:     map[string]string{"custom": "@every 1h", DefaultUpdateTaskID: updateCron},
+		},
+		{
+			name:     "remove stale default alongside custom task",
+			existing: []*models.Task{task("custom", models.TaskTypeNodeTest, "@every 1h", false), task(DefaultPipelineTaskID, models.TaskTypePipeline, "@every 4h", true)},
+			want:     map[string]string{"custom": "@every 1h"},
+		},
+		{
+			name:     "keep up-to-date defaults",
+			existing: []*models.Task{task(DefaultUpdateTaskID, models.TaskTypeSubscriptionUpdate, updateCron, true), task(DefaultTestTaskID, models.TaskTypeNodeTest, testCron, true)},
+			want:     map[string]string{DefaultUpdateTaskID: updateCron, DefaultTestTaskID: testCron},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempStorage(t)
+			s := NewSchedulerService(nil, nil, logService)
+			for _, task := range tt.existing {
+				if err := s.addTask(task); err != nil {
+					t.Fatalf("addTask(%s) error = %v", task.ID, err)
+				}
+			}
+
+			if err := s.EnsureDefaultTasks(); err != nil {
+				t.Fatalf("EnsureDefaultTasks() error = %v", err)
+			}
+			got := make(map[string]string)
+			for _, task := range s.ListTasks() {
+				got[task.ID] = task.Cron
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tasks = %v, want %v", got, tt.want)
+			}
+			if len(s.taskEntries) != len(tt.want) {
+				t.Errorf("scheduled %d tasks, want %d", len(s.taskEntries), len(tt.want))
+			}
+		})
+	}
+}
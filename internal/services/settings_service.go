@@ -0,0 +1,48 @@
+package services
+
+import (
+    "subsmanager/config"
+    "subsmanager/internal/models"
+    "subsmanager/internal/utils"
+)
+
+// GetSettings 获取当前系统设置
+func GetSettings() *models.Settings {
+    cfg := config.Get()
+    return &models.Settings{
+        MaxLatency:      cfg.Filter.MaxLatency,
+        MinSpeed:        cfg.Filter.MinSpeed,
+        Concurrent:      cfg.Test.Concurrent,
+        SpeedConcurrent: cfg.Test.SpeedConcurrent,
+        LatencyCutoff:   cfg.Test.LatencyCutoff,
+        TestURL:         cfg.Test.TestURL,
+        UpdateInterval:  cfg.Subscription.UpdateInterval,
+        TestInterval:    cfg.Subscription.TestInterval,
+        UserAgent:       cfg.Fetch.UserAgent,
+    }
+}
+
+// UpdateSettings 校验并保存系统设置，保存成功后立即生效
+func UpdateSettings(settings *models.Settings) (*models.Settings, error) {
+    err := config.Update(func(cfg *config.Config) error {
+        cfg.Filter.MaxLatency = settings.MaxLatency
+        cfg.Filter.MinSpeed = settings.MinSpeed
+        cfg.Test.Concurrent = settings.Concurrent
+        cfg.Test.SpeedConcurrent = settings.SpeedConcurrent
+        cfg.Test.LatencyCutoff = settings.LatencyCutoff
+        cfg.Test.TestURL = settings.TestURL
+        cfg.Subscription.UpdateInterval = settings.UpdateInterval
+        cfg.Subscription.TestInterval = settings.TestInterval
+        cfg.Fetch.UserAgent = settings.UserAgent
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    utils.LogInfo("Settings updated: MaxLatency=%d, MinSpeed=%.1f, Concurrent=%d, SpeedConcurrent=%d, LatencyCutoff=%d, UpdateInterval=%s, TestInterval=%s",
+        settings.MaxLatency, settings.MinSpeed, settings.Concurrent, settings.SpeedConcurrent, settings.LatencyCutoff,
+        settings.UpdateInterval, settings.TestInterval)
+
+    return GetSettings(), nil
+}
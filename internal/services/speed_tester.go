@@ -0,0 +1,479 @@
+package services
+
+import (
+    "context"
+    "crypto/tls"
+    "crypto/x509"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "strings"
+    "syscall"
+    "subsmanager/internal/models"
+    "subsmanager/internal/utils"
+    "sync"
+    "time"
+)
+
+// 测试相关配置常量
+const (
+    // 延迟测试配置
+    DefaultLatencyTimeout = 5 * time.Second    // 默认延迟测试超时时间
+    DefaultMaxConcurrent = 10                  // 默认最大并发数
+    DefaultMaxLatency    = 400                 // 默认进入下载测试的延迟阈值(ms)
+    
+    // 下载测试配置
+    DefaultSpeedTimeout        = 30 * time.Second  // 默认下载测试超时时间
+    DefaultSpeedConcurrent    = 3                 // 默认下载测试并发数
+    DefaultDialTimeout        = 30 * time.Second  // 默认连接超时时间
+    DefaultKeepAlive         = 30 * time.Second  // 默认连接保持时间
+    DefaultTLSTimeout        = 10 * time.Second  // 默认TLS握手超时时间
+    DefaultIdleTimeout       = 90 * time.Second  // 默认空闲连接超时时间
+    DefaultExpectTimeout     = 1 * time.Second   // 默认 Expect: 100-continue 超时时间
+    DefaultBufferSize        = 8192              // 默认缓冲区大小
+    DefaultMaxBytes          = 10 << 20          // 默认单次下载字节上限
+    DefaultMaxDuration       = 10 * time.Second  // 默认单次下载时长上限
+    DefaultSpeedWindow       = 2 * time.Second   // 默认测速滑动窗口
+    
+    // 其他配置
+    DefaultMaxIdleConns      = 100               // 默认最大空闲连接数
+)
+
+// TestConfig 测试配置
+type TestConfig struct {
+    LatencyTimeout  time.Duration // 延迟测试超时时间
+    SpeedTimeout    time.Duration // 下载测试超时时间
+    MaxConcurrent   int          // 延迟测试并发数
+    SpeedConcurrent int          // 下载测试并发数
+    MaxLatency      int          // 进入下载测试的延迟阈值(ms)
+    BufferSize      int          // 缓冲区大小
+    TestURL         string       // 下载测试URL，为空时使用Targets中的测速目标
+    Targets         *TestTargets // 测速目标，为空时使用内置测速服务器
+    MaxBytes        int64         // 单次下载的字节上限
+    MaxDuration     time.Duration // 单次下载的时长上限
+    SpeedWindow     time.Duration // 计算下载速度的滑动窗口
+    Budget          *DataBudget   // 本机下载流量预算，为空时不限制
+}
+
+// NewDefaultTestConfig 创建默认测试配置
+func NewDefaultTestConfig() *TestConfig {
+    return &TestConfig{
+        LatencyTimeout:  DefaultLatencyTimeout,
+        SpeedTimeout:    DefaultSpeedTimeout,
+        MaxConcurrent:   DefaultMaxConcurrent,
+        SpeedConcurrent: DefaultSpeedConcurrent,
+        MaxLatency:      DefaultMaxLatency,
+        BufferSize:      DefaultBufferSize,
+        MaxBytes:        DefaultMaxBytes,
+        MaxDuration:     DefaultMaxDuration,
+        SpeedWindow:     DefaultSpeedWindow,
+    }
+}
+
+// LatencyTestResult 存储延迟测试结果
+type LatencyTestResult struct {
+    NodeID      string
+    Latency     int       // 延迟(ms)
+    TestTime    time.Time
+    Error       string
+}
+
+// SpeedTestResult 存储速度测试结果
+type SpeedTestResult struct {
+    NodeID      string
+    Speed       float64   // 下载速度(MB/s)
+    Target      string    // 实际使用的测速目标
+    Bytes       int64     // 下载消耗的字节数
+    TestTime    time.Time
+    Error       string
+}
+
+// TestRecord 测试记录
+type TestRecord struct {
+    ID          string    `json:"id"`
+    NodeID      string    `json:"node_id"`
+    Latency     int       `json:"latency"`      // ms
+    Speed       float64   `json:"speed"`        // MB/s
+    Bytes       int64     `json:"bytes"`        // 下载消耗的字节数
+    Status      string    `json:"status"`       // 测试状态
+    TestTime    time.Time `json:"test_time"`
+    Error       string    `json:"error"`
+}
+
+// TestProgress 测试进度
+type TestProgress struct {
+    TotalNodes      int     `json:"total_nodes"`
+    CompletedNodes  int     `json:"completed_nodes"`
+    CurrentProgress float64 `json:"current_progress"` // 0-100
+    Stage          string  `json:"stage"`            // "latency" or "speed"
+    Done           bool    `json:"done"`             // 节点是否已完成全部测试
+    LatencyTested  int     `json:"latency_tested"`   // 延迟测试完成数
+    LatencyDropped int     `json:"latency_dropped"`  // 延迟超限丢弃数
+    SpeedTested    int     `json:"speed_tested"`     // 下载测试完成数
+    Failed         int     `json:"failed"`           // 测试失败数
+    BudgetSkipped  int     `json:"budget_skipped"`   // 流量预算不足跳过数
+    BytesConsumed  int64   `json:"bytes_consumed"`   // 已消耗流量(字节)
+    Node           *models.Node `json:"node,omitempty"`  // 本次完成测试的节点
+    Error          string  `json:"error,omitempty"`  // 本次节点的错误信息
+}
+
+// 测试阶段
+const (
+    TestStageLatency = "latency"
+    TestStageSpeed   = "speed"
+)
+
+// 内置测速服务器，未配置下载测试目标时作为备用
+var speedTestServers = []string{
+    "http://cachefly.cachefly.net/100mb.test",
+    "http://speedtest.tele2.net/100MB.zip",
+    // 可以添加更多备用测速服务器
+}
+
+// 每个节点在内存中保留的测试记录数
+const maxTestRecords = 20
+
+// 节点测试历史记录，按节点ID保存
+var (
+    testHistoryMu sync.RWMutex
+    testHistory   = make(map[string][]*TestRecord)
+)
+
+// TestManager 管理测试过程
+type TestManager struct {
+    config     *TestConfig
+    onProgress func(*TestProgress)
+}
+
+// NewTestManager 创建测试管理器
+func NewTestManager(config *TestConfig) *TestManager {
+    if config == nil {
+        config = NewDefaultTestConfig()
+    }
+
+    return &TestManager{
+        config: config,
+    }
+}
+
+// OnProgress 设置进度回调，每个节点测试完成后调用
+func (tm *TestManager) OnProgress(fn func(*TestProgress)) {
+    tm.onProgress = fn
+}
+
+// testNodeLatency 测试节点延迟
+func (tm *TestManager) testNodeLatency(ctx context.Context, node *models.Node) (*LatencyTestResult, error) {
+    result := &LatencyTestResult{
+        NodeID:   node.ID,
+        TestTime: time.Now(),
+    }
+
+    ctx, cancel := context.WithTimeout(ctx, tm.config.LatencyTimeout)
+    defer cancel()
+
+    start := time.Now()
+    var d net.Dialer
+    conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(node.Address, fmt.Sprint(node.Port)))
+    if err != nil {
+        result.Error = fmt.Sprintf("连接失败: %v", err)
+        return result, err
+    }
+    defer conn.Close()
+
+    result.Latency = int(time.Since(start).Milliseconds())
+    return result, nil
+}
+
+// testNodeSpeed 测试节点下载速度
+//
+// 未指定TestURL时按权重选择测速目标，目标不可达（连接失败或返回错误状态）时依次切换到下一个目标。
+// 每次下载按字节上限从流量预算中预留，预算不足时返回ErrBudgetExhausted。
+func (tm *TestManager) testNodeSpeed(ctx context.Context, node *models.Node) (*SpeedTestResult, error) {
+    result := &SpeedTestResult{
+        NodeID:   node.ID,
+        TestTime: time.Now(),
+    }
+
+    managed := tm.config.TestURL == "" && tm.config.Targets != nil
+    targets := []string{tm.config.TestURL}
+    if managed {
+        targets = tm.config.Targets.downloadCandidates()
+    }
+    if len(targets) == 0 || targets[0] == "" {
+        targets = speedTestServers
+    }
+
+    var err error
+    for _, target := range targets {
+        limit := tm.config.MaxBytes
+        if tm.config.Budget != nil {
+            limit = tm.config.Budget.Reserve(limit)
+            if limit == 0 {
+                if err == nil {
+                    err = ErrBudgetExhausted
+                }
+                break
+            }
+        }
+
+        var n int64
+        var unreachable bool
+        result.Speed, n, unreachable, err = tm.download(ctx, ResolveTargetURL(target), limit)
+        result.Bytes += n
+        if tm.config.Budget != nil {
+            tm.config.Budget.Release(limit, n)
+        }
+
+        if managed && (err == nil || unreachable) && ctx.Err() == nil {
+            tm.config.Targets.markResult(target, err)
+        }
+        if err == nil {
+            result.Target = target
+            return result, nil
+        }
+        if !unreachable || ctx.Err() != nil {
+            break
+        }
+    }
+
+    result.Error = err.Error()
+    return result, err
+}
+
+// speedSample 下载过程中的累计字节数采样
+type speedSample struct {
+    at    time.Time
+    bytes int64
+}
+
+// download 从目标下载不超过maxBytes字节，返回速度(MB/s)和实际下载的字节数，目标不可达时unreachable为true
+//
+// 达到字节上限或时长上限时停止下载；速度取下载过程中滑动窗口内的最高吞吐量，
+// 下载时间不足一个窗口时按整体平均速度计算，避免连接建立和慢启动拉低结果。
+func (tm *TestManager) download(ctx context.Context, targetURL string, maxBytes int64) (float64, int64, bool, error) {
+    client := &http.Client{
+        Timeout: tm.config.SpeedTimeout,
+        Transport: &http.Transport{
+            Proxy: http.ProxyFromEnvironment,
+            DialContext: (&net.Dialer{
+                Timeout:   DefaultDialTimeout,
+                KeepAlive: DefaultKeepAlive,
+            }).DialContext,
+            MaxIdleConns:          DefaultMaxIdleConns,
+            IdleConnTimeout:       DefaultIdleTimeout,
+            TLSHandshakeTimeout:   DefaultTLSTimeout,
+            ExpectContinueTimeout: DefaultExpectTimeout,
+        },
+    }
+
+    dctx, cancel := context.WithTimeout(ctx, tm.config.MaxDuration)
+    defer cancel()
+    req, err := http.NewRequestWithContext(dctx, http.MethodGet, targetURL, nil)
+    if err != nil {
+        return 0, 0, true, fmt.Errorf("创建请求失败: %w", err)
+    }
+
+    resp, err := client.Do(req)
+    if err != nil {
+        return 0, 0, true, fmt.Errorf("请求失败: %w", err)
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode >= http.StatusBadRequest {
+        return 0, 0, true, fmt.Errorf("测速目标返回错误状态: %s", resp.Status)
+    }
+
+    start := time.Now()
+    window := []speedSample{{at: start}}
+    buf := make([]byte, tm.config.BufferSize)
+    var totalBytes int64
+    var best float64
+    for totalBytes < maxBytes {
+        chunk := buf
+        if remaining := maxBytes - totalBytes; remaining < int64(len(chunk)) {
+            chunk = chunk[:remaining]
+        }
+        n, err := resp.Body.Read(chunk)
+        if n > 0 {
+            now := time.Now()
+            totalBytes += int64(n)
+            window = append(window, speedSample{at: now, bytes: totalBytes})
+            // 保留一个位于窗口起点之前的采样，窗口外的其余采样丢弃
+            for len(window) > 2 && now.Sub(window[1].at) >= tm.config.SpeedWindow {
+                window = window[1:]
+            }
+            if span := now.Sub(window[0].at); span >= tm.config.SpeedWindow {
+                if speed := float64(totalBytes-window[0].bytes) / span.Seconds(); speed > best {
+                    best = speed
+                }
+            }
+        }
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            // 达到时长上限时正常结束
+            if dctx.Err() == context.DeadlineExceeded && ctx.Err() == nil && totalBytes > 0 {
+                break
+            }
+            return 0, totalBytes, false, fmt.Errorf("读取失败: %w", err)
+        }
+    }
+
+    if best == 0 {
+        if elapsed := time.Since(start).Seconds(); elapsed > 0 {
+            best = float64(totalBytes) / elapsed
+        }
+    }
+    return best / 1024 / 1024, totalBytes, false, nil
+}
+
+// StartLatencyTest 开始批量延迟测试，每个节点完成后串行调用onResult，全部完成后返回
+//
+// ctx 取消后未开始测试的节点不再测试。
+func (tm *TestManager) StartLatencyTest(ctx context.Context, nodes []*models.Node, onResult func(*models.Node, *LatencyTestResult, error)) {
+    sem := make(chan struct{}, tm.config.MaxConcurrent)
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+
+    for _, node := range nodes {
+        sem <- struct{}{} // 获取信号量
+        if ctx.Err() != nil {
+            <-sem
+            break
+        }
+        wg.Add(1)
+
+        go func(n *models.Node) {
+            defer wg.Done()
+            defer func() { <-sem }() // 释放信号量
+
+            result, err := tm.testNodeLatency(ctx, n)
+            if err != nil {
+                utils.LogError("节点延迟测试失败: nodeID=%s, error=%v", n.ID, err)
+            }
+
+            mu.Lock()
+            onResult(n, result, err)
+            mu.Unlock()
+        }(node)
+    }
+
+    wg.Wait()
+}
+
+// StartSpeedTest 开始批量速度测试，只测试延迟不超过阈值的节点，每个节点完成后串行调用onResult
+//
+// 下载测试使用单独的并发数，通常小于延迟测试并发数。ctx 取消后未开始测试的节点不再测试。
+func (tm *TestManager) StartSpeedTest(ctx context.Context, nodes []*models.Node, onResult func(*models.Node, *SpeedTestResult, error)) {
+    sem := make(chan struct{}, tm.config.SpeedConcurrent)
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+
+    for _, node := range nodes {
+        // 只测试延迟不超过阈值的节点
+        if !tm.PassesLatency(node) {
+            continue
+        }
+
+        sem <- struct{}{} // 获取信号量
+        if ctx.Err() != nil {
+            <-sem
+            break
+        }
+        wg.Add(1)
+
+        go func(n *models.Node) {
+            defer wg.Done()
+            defer func() { <-sem }() // 释放信号量
+
+            result, err := tm.testNodeSpeed(ctx, n)
+            if err != nil && !errors.Is(err, ErrBudgetExhausted) {
+                utils.LogError("节点速度测试失败: nodeID=%s, error=%v", n.ID, err)
+            }
+
+            mu.Lock()
+            onResult(n, result, err)
+            mu.Unlock()
+        }(node)
+    }
+
+    wg.Wait()
+}
+
+// classifyTestError 根据错误类型判断节点测试状态
+func classifyTestError(err error) string {
+    var netErr net.Error
+    switch {
+    case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+        return models.TestStatusTimeout
+    case errors.Is(err, syscall.ECONNREFUSED):
+        return models.TestStatusConnectionRefused
+    }
+
+    var recordErr tls.RecordHeaderError
+    var unknownAuthority x509.UnknownAuthorityError
+    var certInvalid x509.CertificateInvalidError
+    var hostname x509.HostnameError
+    if errors.As(err, &recordErr) || errors.As(err, &unknownAuthority) ||
+        errors.As(err, &certInvalid) || errors.As(err, &hostname) ||
+        strings.Contains(err.Error(), "tls:") || strings.Contains(err.Error(), "handshake") {
+        return models.TestStatusHandshakeFailed
+    }
+    return models.TestStatusFailed
+}
+
+// PassesLatency 节点延迟是否在下载测试阈值内
+func (tm *TestManager) PassesLatency(node *models.Node) bool {
+    return node.Latency <= tm.config.MaxLatency
+}
+
+// SaveTestResult 保存测试结果，每个节点只保留最近的记录
+func (tm *TestManager) SaveTestResult(record *TestRecord) error {
+    testHistoryMu.Lock()
+    defer testHistoryMu.Unlock()
+
+    records := append(testHistory[record.NodeID], record)
+    if len(records) > maxTestRecords {
+        records = records[len(records)-maxTestRecords:]
+    }
+    testHistory[record.NodeID] = records
+    return nil
+}
+
+// ClearTestHistory 清除节点的测试历史记录，返回清除的记录数
+func ClearTestHistory(nodeIDs []string) int {
+    testHistoryMu.Lock()
+    defer testHistoryMu.Unlock()
+
+    cleared := 0
+    for _, id := range nodeIDs {
+        cleared += len(testHistory[id])
+        delete(testHistory, id)
+    }
+    return cleared
+}
+
+// GetTestHistory 获取测试历史记录，按测试时间倒序
+func (tm *TestManager) GetTestHistory(nodeID string, limit int) ([]*TestRecord, error) {
+    testHistoryMu.RLock()
+    defer testHistoryMu.RUnlock()
+
+    records := testHistory[nodeID]
+    result := make([]*TestRecord, 0, len(records))
+    for i := len(records) - 1; i >= 0; i-- {
+        if limit > 0 && len(result) >= limit {
+            break
+        }
+        result = append(result, records[i])
+    }
+    return result, nil
+}
+
+// UpdateTestProgress 更新测试进度，推送给进度回调
+func (tm *TestManager) UpdateTestProgress(progress *TestProgress) {
+    if tm.onProgress != nil {
+        tm.onProgress(progress)
+    }
+}
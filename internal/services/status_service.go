@@ -13,49 +13,53 @@ import (
 // StatusService 状态监控服务
 type StatusService struct {
     subscriptionService *SubscriptionService
-    nodeService        *NodeService
-    config            *config.Config
     status            *models.SystemStatus
     historyMutex      sync.RWMutex
-    history           []models.SubscriptionHistory
+    history           []models.SubFileHistory
+    taskHistory       []*models.TaskResult
 }
 
+// 保留的任务执行记录数
+const maxTaskHistory = 100
+
 // NewStatusService 创建状态监控服务
-func NewStatusService(subService *SubscriptionService, nodeService *NodeService, config *config.Config) *StatusService {
+func NewStatusService(subService *SubscriptionService) *StatusService {
     return &StatusService{
         subscriptionService: subService,
-        nodeService:        nodeService,
-        config:            config,
         status:            &models.SystemStatus{},
-        history:           make([]models.SubscriptionHistory, 0),
+        history:           make([]models.SubFileHistory, 0),
     }
 }
 
 // UpdateNodeStatus 更新节点状态
 func (s *StatusService) UpdateNodeStatus() {
-    nodes := s.nodeService.GetAllNodes()
-    testedNodes := s.nodeService.GetAllTestedNodes()
+    nodes, _ := s.subscriptionService.QueryNodes(models.NodeQuery{})
+    tested := true
+    testedNodes, _ := s.subscriptionService.QueryNodes(models.NodeQuery{Tested: &tested})
     
     status := models.NodeStatus{
         TotalNodes:   len(nodes),
         CurrentNodes: 0,
         SlowNodes:    0,
         FaultNodes:   0,
+        RegionNodes:  s.subscriptionService.GetRegionStats(),
     }
 
-    // 统计节点状态
+    // 统计节点状态：测试失败或延迟超过下载测试阈值的节点视为故障节点，只统计测得速度的慢速节点
     for _, node := range testedNodes {
-        if node.Latency > 400 { // 延迟大于400ms的节点视为故障节点
+        if node.TestFailed() || node.Latency > config.Get().Test.LatencyCutoff {
             status.FaultNodes++
+            continue
         }
-        if node.DownloadSpeed < s.config.Filter.MinSpeed {
+        if node.SpeedMeasured() && node.DownloadSpeed < config.Get().Filter.MinSpeed {
             status.SlowNodes++
         }
     }
 
-    // 获取当前筛选后的节点数
-    filteredNodes, _ := s.nodeService.GetFilteredNodes()
-    status.CurrentNodes = len(filteredNodes)
+    // 获取最近一次生成订阅的节点数
+    if latest := s.subscriptionService.LatestHistory(models.ActionGenerate); latest != nil {
+        status.CurrentNodes = latest.NodeCount
+    }
 
     s.status.NodeStatus = status
     s.status.LastUpdateTime = time.Now()
@@ -67,9 +71,9 @@ func (s *StatusService) AddSubscriptionHistory(fileName string, nodeCount int) {
     defer s.historyMutex.Unlock()
 
     // 创建新的历史记录
-    history := models.SubscriptionHistory{
+    history := models.SubFileHistory{
         FileName:     fileName,
-        LocalURL:     fmt.Sprintf("http://localhost:%d/subscriptions/%s", s.config.Server.Port, fileName),
+        LocalURL:     fmt.Sprintf("http://localhost:%d/subscriptions/%s", config.Get().Server.Port, fileName),
         GenerateTime: time.Now(),
         NodeCount:    nodeCount,
     }
@@ -91,10 +95,33 @@ func (s *StatusService) AddSubscriptionHistory(fileName string, nodeCount int) {
     s.status.SubHistory = s.history
 }
 
+// AddTaskHistory 添加任务执行记录
+func (s *StatusService) AddTaskHistory(result *models.TaskResult) {
+    s.historyMutex.Lock()
+    defer s.historyMutex.Unlock()
+
+    s.taskHistory = append(s.taskHistory, result)
+    if len(s.taskHistory) > maxTaskHistory {
+        s.taskHistory = s.taskHistory[len(s.taskHistory)-maxTaskHistory:]
+    }
+}
+
+// GetTaskHistory 获取任务执行记录，按时间倒序
+func (s *StatusService) GetTaskHistory() []*models.TaskResult {
+    s.historyMutex.RLock()
+    defer s.historyMutex.RUnlock()
+
+    results := make([]*models.TaskResult, 0, len(s.taskHistory))
+    for i := len(s.taskHistory) - 1; i >= 0; i-- {
+        results = append(results, s.taskHistory[i])
+    }
+    return results
+}
+
 // UpdateLatestFiles 更新最新文件信息
 func (s *StatusService) UpdateLatestFiles() {
-    s.status.LatestSubFile = filepath.Join(s.config.Storage.Path, "sub.yaml")
-    s.status.LatestInputFile = filepath.Join(s.config.Storage.Path, s.getLatestInputFile())
+    s.status.LatestSubFile = filepath.Join(config.Get().Storage.Path, "sub.yaml")
+    s.status.LatestInputFile = filepath.Join(config.Get().Storage.Path, s.getLatestInputFile())
 }
 
 // getLatestInputFile 获取最新的Sub-Input文件
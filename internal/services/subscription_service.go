@@ -1,19 +1,34 @@
 package services
 
 import (
+    "context"
     "encoding/json"
+    "errors"
     "fmt"
     "os"
     "path/filepath"
+    "sort"
+    "strings"
     "subsmanager/config"
     "subsmanager/internal/models"
     "subsmanager/internal/utils"
+    "sync"
     "time"
-    "gopkg.in/yaml.v3"
-    "math/rand"
 )
 
+// 默认生成的订阅文件名
+const DefaultOutputFile = "sub.yaml"
+
+// ErrFetchSubscription 获取或解析订阅链接失败，属于上游错误
+var ErrFetchSubscription = errors.New("fetch subscription failed")
+
+// SubscriptionService 订阅和节点管理
+//
+// 所有数据由 mu 保护，后台任务和HTTP请求可并发访问。节点和历史记录写入后不再原地修改，
+// 更新时替换为新的副本，因此返回的节点指针可在锁外只读访问；订阅会被原地修改，对外只返回副本。
+// 小写方法中注明"调用方需持有锁"的，由调用方加锁。
 type SubscriptionService struct {
+    mu            sync.RWMutex
     subscriptions map[string]*models.Subscription
     nodes        map[string]*models.Node
     history      map[string]*models.SubscriptionHistory // 订阅历史记录
@@ -26,20 +41,42 @@ var DefaultSubscriptionService = &SubscriptionService{
 }
 
 // ImportSubscription 导入订阅
-func (s *SubscriptionService) ImportSubscription(name, url string) (*models.Subscription, error) {
+func (s *SubscriptionService) ImportSubscription(name, url string, tags []string) (*models.Subscription, error) {
     // 解析订阅
-    result, err := utils.ParseSubscription(url)
+    result, err := utils.ParseSubscription(url, utils.FetchOptions{})
+    if err != nil {
+        return nil, fmt.Errorf("parse subscription failed: %v", err)
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.addSubscription(name, url, models.SubscriptionSourceURL, tags, result)
+}
+
+// ImportSubscriptionContent 从粘贴的内容或上传的文件导入本地订阅，本地订阅不可更新
+func (s *SubscriptionService) ImportSubscriptionContent(name string, content []byte, tags []string) (*models.Subscription, error) {
+    result, err := utils.ParseSubscriptionContent(string(content))
     if err != nil {
         return nil, fmt.Errorf("parse subscription failed: %v", err)
     }
 
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.addSubscription(name, "", models.SubscriptionSourceLocal, tags, result)
+}
+
+// addSubscription 保存解析后的订阅及其节点，返回订阅副本，调用方需持有锁
+func (s *SubscriptionService) addSubscription(name, url, source string, tags []string, result *utils.SubscriptionParseResult) (*models.Subscription, error) {
     // 创建订阅记录
     sub := &models.Subscription{
-        ID:        fmt.Sprintf("sub_%d", time.Now().Unix()),
+        ID:        s.newSubscriptionID(),
         Name:      name,
         Type:      string(result.Type),
+        Source:    source,
         URL:       url,
+        Tags:      normalizeTags(tags),
         NodeCount: result.NodeCount,
+        Info:      result.Info,
         CreatedAt: time.Now(),
         UpdatedAt: time.Now(),
     }
@@ -49,8 +86,9 @@ func (s *SubscriptionService) ImportSubscription(name, url string) (*models.Subs
 
     // 保存节点
     for _, node := range result.Nodes {
-        node.ID = fmt.Sprintf("node_%d", time.Now().UnixNano())
+        node.ID = s.newNodeID()
         node.SubscriptionID = sub.ID
+        node.Tags = sub.Tags
         s.nodes[node.ID] = node
     }
 
@@ -59,112 +97,944 @@ func (s *SubscriptionService) ImportSubscription(name, url string) (*models.Subs
         name, sub.ID, result.Stats.Total, result.Stats.Success, result.Stats.Failed)
 
     // 保存到文件
-    if err := s.SaveToFile(); err != nil {
+    if err := s.save(); err != nil {
         return nil, fmt.Errorf("save to file failed: %v", err)
     }
 
-    return sub, nil
+    c := *sub
+    return &c, nil
+}
+
+// newSubscriptionID 生成不与已有订阅重复的订阅ID，调用方需持有锁
+//
+// 同一秒内连续粘贴或上传多个本地订阅时，按秒生成的ID会覆盖先导入的订阅。
+func (s *SubscriptionService) newSubscriptionID() string {
+    for n := time.Now().UnixNano(); ; n++ {
+        id := fmt.Sprintf("sub_%d", n)
+        if _, exists := s.subscriptions[id]; !exists {
+            return id
+        }
+    }
+}
+
+// newNodeID 生成不与已有节点重复的节点ID，调用方需持有锁
+func (s *SubscriptionService) newNodeID() string {
+    for n := time.Now().UnixNano(); ; n++ {
+        id := fmt.Sprintf("node_%d", n)
+        if _, exists := s.nodes[id]; !exists {
+            return id
+        }
+    }
+}
+
+// RefreshSubscription 重新获取订阅并更新其节点
+func (s *SubscriptionService) RefreshSubscription(id string) error {
+    s.mu.RLock()
+    sub, exists := s.subscriptions[id]
+    var current models.Subscription
+    if exists {
+        current = *sub
+    }
+    s.mu.RUnlock()
+    if !exists {
+        return fmt.Errorf("subscription not found: %s", id)
+    }
+    if !current.Refreshable() {
+        return fmt.Errorf("local subscription cannot be refreshed: %s", id)
+    }
+
+    // 获取订阅时不持有锁，避免阻塞其他请求
+    result, err := utils.ParseSubscription(current.URL, fetchOptions(&current))
+    if err != nil {
+        return fmt.Errorf("parse subscription failed: %v", err)
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if sub, exists = s.subscriptions[id]; !exists {
+        return fmt.Errorf("subscription not found: %s", id)
+    }
+    s.replaceSubscriptionNodes(sub, result)
+    s.recordHistory(sub.ID, models.ActionUpdate, sub.NodeCount,
+        fmt.Sprintf("更新订阅：%s，节点数：%d个", sub.Name, sub.NodeCount))
+    if err := s.save(); err != nil {
+        return fmt.Errorf("save to file failed: %v", err)
+    }
+    return nil
+}
+
+// UpdateSubscription 编辑订阅
+//
+// 修改订阅链接时重新获取订阅，节点按端点和凭据与旧节点匹配，沿用旧节点ID和测速数据；
+// 获取失败时不修改订阅。
+func (s *SubscriptionService) UpdateSubscription(id string, update models.SubscriptionUpdate) (*models.Subscription, error) {
+    s.mu.RLock()
+    sub, exists := s.subscriptions[id]
+    var current models.Subscription
+    if exists {
+        current = *sub
+    }
+    s.mu.RUnlock()
+    if !exists {
+        return nil, fmt.Errorf("subscription not found: %s", id)
+    }
+
+    // 先在副本上校验修改内容，修改订阅链接时在锁外获取新订阅
+    updated := current
+    if err := applySubscriptionUpdate(&updated, update); err != nil {
+        return nil, err
+    }
+    var result *utils.SubscriptionParseResult
+    if updated.URL != current.URL {
+        var err error
+        result, err = utils.ParseSubscription(updated.URL, fetchOptions(&updated))
+        if err != nil {
+            return nil, fmt.Errorf("%w: %v", ErrFetchSubscription, err)
+        }
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if sub, exists = s.subscriptions[id]; !exists {
+        return nil, fmt.Errorf("subscription not found: %s", id)
+    }
+    snapshot := s.snapshot()
+    oldURL := sub.URL
+    if err := applySubscriptionUpdate(sub, update); err != nil {
+        return nil, err
+    }
+    if result != nil {
+        s.replaceSubscriptionNodes(sub, result)
+        s.recordHistory(sub.ID, models.ActionUpdate, sub.NodeCount,
+            fmt.Sprintf("修改订阅链接：%s，%s -> %s，节点数：%d个", sub.Name, oldURL, sub.URL, sub.NodeCount))
+    } else {
+        sub.UpdatedAt = time.Now()
+        s.syncNodeTags(sub)
+    }
+
+    if err := s.save(); err != nil {
+        s.restore(snapshot)
+        return nil, fmt.Errorf("save to file failed: %v", err)
+    }
+    c := *sub
+    return &c, nil
+}
+
+// applySubscriptionUpdate 将编辑内容应用到订阅
+func applySubscriptionUpdate(sub *models.Subscription, update models.SubscriptionUpdate) error {
+    if update.Name != nil {
+        name := strings.TrimSpace(*update.Name)
+        if name == "" {
+            return fmt.Errorf("name must not be empty")
+        }
+        sub.Name = name
+    }
+    if update.URL != nil && *update.URL != sub.URL {
+        if !sub.Refreshable() {
+            return fmt.Errorf("local subscription has no url: %s", sub.ID)
+        }
+        sub.URL = *update.URL
+    }
+    if update.UserAgent != nil {
+        sub.UserAgent = strings.TrimSpace(*update.UserAgent)
+    }
+    if update.Headers != nil {
+        sub.Headers = nil
+        if len(*update.Headers) > 0 {
+            sub.Headers = *update.Headers
+        }
+    }
+    if update.Tags != nil {
+        sub.Tags = normalizeTags(*update.Tags)
+    }
+    if update.Enabled != nil {
+        sub.Disabled = !*update.Enabled
+    }
+    return nil
+}
+
+// normalizeTags 去除标签首尾空白，剔除空标签和重复标签
+func normalizeTags(tags []string) []string {
+    result := make([]string, 0, len(tags))
+    seen := make(map[string]bool, len(tags))
+    for _, tag := range tags {
+        tag = strings.TrimSpace(tag)
+        if tag == "" || seen[tag] {
+            continue
+        }
+        seen[tag] = true
+        result = append(result, tag)
+    }
+    if len(result) == 0 {
+        return nil
+    }
+    return result
+}
+
+// syncNodeTags 将订阅标签同步到其节点，调用方需持有锁
+//
+// 节点替换为更新标签后的副本而不是原地修改，以便通过快照撤销。
+func (s *SubscriptionService) syncNodeTags(sub *models.Subscription) {
+    for id, node := range s.nodes {
+        if node.SubscriptionID != sub.ID {
+            continue
+        }
+        updated := *node
+        updated.Tags = sub.Tags
+        s.nodes[id] = &updated
+    }
+}
+
+// fetchOptions 获取订阅时使用的请求选项
+func fetchOptions(sub *models.Subscription) utils.FetchOptions {
+    return utils.FetchOptions{
+        UserAgent: sub.UserAgent,
+        Headers:   sub.Headers,
+    }
+}
+
+// UpdateAllSubscriptions 更新所有订阅
+func (s *SubscriptionService) UpdateAllSubscriptions(ctx context.Context) error {
+    s.mu.RLock()
+    ids := make([]string, 0, len(s.subscriptions))
+    for id := range s.subscriptions {
+        ids = append(ids, id)
+    }
+    s.mu.RUnlock()
+    _, _, err := s.UpdateSubscriptions(ctx, ids)
+    return err
+}
+
+// UpdateSubscriptions 更新指定订阅，返回成功更新和跳过的订阅数
+//
+// 部分订阅更新失败时仍会保存其余订阅的结果，并返回汇总错误。
+// 本地订阅和已停用的订阅跳过，不计为成功或失败。
+func (s *SubscriptionService) UpdateSubscriptions(ctx context.Context, ids []string) (int, int, error) {
+    s.mu.RLock()
+    subs := make([]models.Subscription, 0, len(ids))
+    updated, failed, skipped := 0, 0, 0
+    for _, id := range ids {
+        sub, exists := s.subscriptions[id]
+        if !exists {
+            failed++
+            utils.LogError("Update subscription failed: subscription not found: %s", id)
+            continue
+        }
+        if !sub.Refreshable() || sub.Disabled {
+            skipped++
+            continue
+        }
+        subs = append(subs, *sub)
+    }
+    s.mu.RUnlock()
+    results := fetchSubscriptions(ctx, subs)
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    // 依次更新节点，获取期间被删除的订阅视为失败
+    for id, r := range results {
+        sub, exists := s.subscriptions[id]
+        if !exists {
+            failed++
+            utils.LogError("Update subscription failed: subscription not found: %s", id)
+            continue
+        }
+        if r.err != nil {
+            failed++
+            utils.LogError("Update subscription %s failed: %v", sub.Name, r.err)
+            continue
+        }
+        s.replaceSubscriptionNodes(sub, r.result)
+        updated++
+        s.recordHistory(sub.ID, models.ActionUpdate, sub.NodeCount,
+            fmt.Sprintf("更新订阅：%s，节点数：%d个", sub.Name, sub.NodeCount))
+    }
+
+    if err := s.save(); err != nil {
+        return 0, skipped, fmt.Errorf("save to file failed: %v", err)
+    }
+
+    utils.LogInfo("Subscriptions updated: Total=%d, Updated=%d, Failed=%d, Skipped=%d", len(ids), updated, failed, skipped)
+    if failed > 0 {
+        return updated, skipped, fmt.Errorf("%d of %d subscriptions failed to update", failed, len(ids))
+    }
+    return updated, skipped, nil
+}
+
+// fetchResult 订阅获取结果
+type fetchResult struct {
+    result *utils.SubscriptionParseResult
+    err    error
+}
+
+// fetchSubscriptions 按 subscription.max_concurrent 并发获取订阅内容，ctx 取消后未开始的订阅不再获取
+func fetchSubscriptions(ctx context.Context, subs []models.Subscription) map[string]*fetchResult {
+    concurrent := config.Get().Subscription.MaxConcurrent
+    if concurrent <= 0 {
+        concurrent = 1
+    }
+    sem := make(chan struct{}, concurrent)
+    results := make(map[string]*fetchResult, len(subs))
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    for _, sub := range subs {
+        wg.Add(1)
+        go func(id, url string, opts utils.FetchOptions) {
+            defer wg.Done()
+            sem <- struct{}{}
+            defer func() { <-sem }()
+
+            var result *utils.SubscriptionParseResult
+            err := ctx.Err()
+            if err == nil {
+                result, err = utils.ParseSubscriptionContext(ctx, url, opts)
+            }
+            mu.Lock()
+            results[id] = &fetchResult{result: result, err: err}
+            mu.Unlock()
+        }(sub.ID, sub.URL, fetchOptions(&sub))
+    }
+    wg.Wait()
+    return results
+}
+
+// BulkUpdateSubscriptions 批量删除、更新、启用、停用订阅或修改订阅标签
+//
+// 默认全部成功才保存，任一订阅失败时撤销所有修改；允许部分成功时保存成功的修改。
+// 保存失败时同样撤销所有修改。
+func (s *SubscriptionService) BulkUpdateSubscriptions(req models.SubscriptionBulkRequest) (*models.SubscriptionBulkReport, error) {
+    // 去除重复ID
+    ids := make([]string, 0, len(req.IDs))
+    seen := make(map[string]bool, len(req.IDs))
+    for _, id := range req.IDs {
+        if !seen[id] {
+            seen[id] = true
+            ids = append(ids, id)
+        }
+    }
+
+    // 更新操作先在锁外并发获取所有订阅，再依次替换节点
+    var fetched map[string]*fetchResult
+    if req.Action == models.BulkActionRefresh {
+        s.mu.RLock()
+        subs := make([]models.Subscription, 0, len(ids))
+        for _, id := range ids {
+            if sub, exists := s.subscriptions[id]; exists && sub.Refreshable() {
+                subs = append(subs, *sub)
+            }
+        }
+        s.mu.RUnlock()
+        fetched = fetchSubscriptions(context.Background(), subs)
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    snapshot := s.snapshot()
+    report := &models.SubscriptionBulkReport{
+        Action:  req.Action,
+        Results: make([]*models.SubscriptionBulkResult, 0, len(ids)),
+    }
+    failed := 0
+    for _, id := range ids {
+        result := &models.SubscriptionBulkResult{ID: id, Success: true}
+        if err := s.applyBulkAction(id, req, fetched, result); err != nil {
+            result.Success = false
+            result.Error = err.Error()
+            failed++
+        }
+        report.Results = append(report.Results, result)
+    }
+
+    if failed > 0 && !req.AllowPartial {
+        s.restore(snapshot)
+        for _, result := range report.Results {
+            if result.Success {
+                result.Success = false
+                result.Error = "rolled back"
+                result.Deleted = nil
+            }
+        }
+        return report, nil
+    }
+    if failed == len(ids) {
+        return report, nil
+    }
+
+    if err := s.save(); err != nil {
+        s.restore(snapshot)
+        return nil, fmt.Errorf("save to file failed: %v", err)
+    }
+    report.Applied = true
+    for _, result := range report.Results {
+        if result.Deleted != nil {
+            result.Deleted.ClearedTestRecords = ClearTestHistory(result.Deleted.RemovedNodeIDs)
+            utils.LogSubscriptionDelete(result.Deleted.Name)
+        }
+    }
+    utils.LogInfo("Bulk subscription %s: Total=%d, Failed=%d", req.Action, len(ids), failed)
+    return report, nil
 }
 
-// DeleteSubscription 删除订阅
-func (s *SubscriptionService) DeleteSubscription(id string) error {
-    if _, exists := s.subscriptions[id]; !exists {
+// applyBulkAction 对单个订阅执行批量操作（不保存到文件），删除结果写入 result，调用方需持有锁
+func (s *SubscriptionService) applyBulkAction(id string, req models.SubscriptionBulkRequest, fetched map[string]*fetchResult, result *models.SubscriptionBulkResult) error {
+    sub, exists := s.subscriptions[id]
+    if !exists {
         return fmt.Errorf("subscription not found: %s", id)
     }
-    delete(s.subscriptions, id)
+
+    switch req.Action {
+    case models.BulkActionDelete:
+        result.Deleted = s.removeSubscription(sub, req.KeepNodes)
+    case models.BulkActionRefresh:
+        if !sub.Refreshable() {
+            return fmt.Errorf("local subscription cannot be refreshed: %s", id)
+        }
+        r, ok := fetched[id]
+        if !ok {
+            return fmt.Errorf("subscription changed during refresh: %s", id)
+        }
+        if r.err != nil {
+            return fmt.Errorf("parse subscription failed: %v", r.err)
+        }
+        s.replaceSubscriptionNodes(sub, r.result)
+        s.recordHistory(sub.ID, models.ActionUpdate, sub.NodeCount,
+            fmt.Sprintf("更新订阅：%s，节点数：%d个", sub.Name, sub.NodeCount))
+    case models.BulkActionEnable, models.BulkActionDisable:
+        sub.Disabled = req.Action == models.BulkActionDisable
+        sub.UpdatedAt = time.Now()
+    case models.BulkActionTag:
+        remove := make(map[string]bool, len(req.RemoveTags))
+        for _, tag := range req.RemoveTags {
+            remove[strings.TrimSpace(tag)] = true
+        }
+        tags := make([]string, 0, len(sub.Tags)+len(req.AddTags))
+        for _, tag := range append(append([]string{}, sub.Tags...), req.AddTags...) {
+            if !remove[strings.TrimSpace(tag)] {
+                tags = append(tags, tag)
+            }
+        }
+        sub.Tags = normalizeTags(tags)
+        sub.UpdatedAt = time.Now()
+        s.syncNodeTags(sub)
+    default:
+        return fmt.Errorf("unsupported action: %s", req.Action)
+    }
     return nil
 }
 
-// GetSubscriptions 获取所有订阅
+// serviceSnapshot 订阅、节点和历史记录的快照，用于撤销批量修改
+type serviceSnapshot struct {
+    subscriptions map[string]models.Subscription
+    nodes         map[string]*models.Node
+    history       map[string]*models.SubscriptionHistory
+}
+
+// snapshot 保存当前数据的快照，调用方需持有锁
+//
+// 订阅会被原地修改，因此复制订阅内容；节点和历史记录只会被替换，复制引用即可。
+func (s *SubscriptionService) snapshot() *serviceSnapshot {
+    snap := &serviceSnapshot{
+        subscriptions: make(map[string]models.Subscription, len(s.subscriptions)),
+        nodes:         make(map[string]*models.Node, len(s.nodes)),
+        history:       make(map[string]*models.SubscriptionHistory, len(s.history)),
+    }
+    for id, sub := range s.subscriptions {
+        snap.subscriptions[id] = *sub
+    }
+    for id, node := range s.nodes {
+        snap.nodes[id] = node
+    }
+    for id, h := range s.history {
+        snap.history[id] = h
+    }
+    return snap
+}
+
+// restore 恢复到快照时的数据，保留订阅对象的引用，调用方需持有锁
+func (s *SubscriptionService) restore(snap *serviceSnapshot) {
+    subs := make(map[string]*models.Subscription, len(snap.subscriptions))
+    for id, saved := range snap.subscriptions {
+        sub, exists := s.subscriptions[id]
+        if !exists {
+            sub = &models.Subscription{}
+        }
+        *sub = saved
+        subs[id] = sub
+    }
+    s.subscriptions = subs
+    s.nodes = snap.nodes
+    s.history = snap.history
+}
+
+// replaceSubscriptionNodes 用新的解析结果替换订阅节点，调用方需持有锁
+//
+// 与旧节点端点及凭据相同的新节点沿用旧节点ID和测速数据。
+func (s *SubscriptionService) replaceSubscriptionNodes(sub *models.Subscription, result *utils.SubscriptionParseResult) {
+    deduper, _ := newNodeDeduper(DedupEndpointCredential)
+
+    // 收集旧节点
+    existing := make(map[string]*models.Node)
+    for id, node := range s.nodes {
+        if node.SubscriptionID == sub.ID {
+            existing[deduper.key(node)] = node
+            delete(s.nodes, id)
+        }
+    }
+
+    // 写入新节点
+    for _, node := range result.Nodes {
+        node.SubscriptionID = sub.ID
+        node.Tags = sub.Tags
+        if old, ok := existing[deduper.key(node)]; ok {
+            node.ID = old.ID
+            node.Latency = old.Latency
+            node.DownloadSpeed = old.DownloadSpeed
+            node.TestStatus = old.TestStatus
+            node.TestError = old.TestError
+            node.LatencyTestedAt = old.LatencyTestedAt
+            node.SpeedTestedAt = old.SpeedTestedAt
+            node.LastTestedAt = old.LastTestedAt
+        } else {
+            node.ID = s.newNodeID()
+        }
+        s.nodes[node.ID] = node
+    }
+
+    sub.Type = string(result.Type)
+    sub.NodeCount = result.NodeCount
+    sub.Info = result.Info
+    sub.UpdatedAt = time.Now()
+}
+
+// DeleteSubscription 删除订阅及其节点，keepNodes 为true时保留节点并解除与订阅的关联
+func (s *SubscriptionService) DeleteSubscription(id string, keepNodes bool) (*models.SubscriptionDeleteReport, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    sub, exists := s.subscriptions[id]
+    if !exists {
+        return nil, fmt.Errorf("subscription not found: %s", id)
+    }
+
+    snapshot := s.snapshot()
+    report := s.removeSubscription(sub, keepNodes)
+    if err := s.save(); err != nil {
+        s.restore(snapshot)
+        return nil, fmt.Errorf("save to file failed: %v", err)
+    }
+
+    // 保存成功后再清除测试历史，保存失败时不丢失数据
+    report.ClearedTestRecords = ClearTestHistory(report.RemovedNodeIDs)
+    utils.LogSubscriptionDelete(report.Name)
+    return report, nil
+}
+
+// removeSubscription 删除订阅及其节点并记录历史（不保存到文件，不清除测试历史），调用方需持有锁
+//
+// 保留的节点替换为解除关联后的副本而不是原地修改，以便通过快照撤销。
+func (s *SubscriptionService) removeSubscription(sub *models.Subscription, keepNodes bool) *models.SubscriptionDeleteReport {
+    report := &models.SubscriptionDeleteReport{
+        SubscriptionID: sub.ID,
+        Name:           sub.Name,
+        RemovedNodeIDs: make([]string, 0),
+    }
+    for id, node := range s.nodes {
+        if node.SubscriptionID != sub.ID {
+            continue
+        }
+        if keepNodes {
+            orphan := *node
+            orphan.SubscriptionID = ""
+            s.nodes[id] = &orphan
+            report.OrphanedNodes++
+            continue
+        }
+        delete(s.nodes, id)
+        report.RemovedNodeIDs = append(report.RemovedNodeIDs, id)
+    }
+    sort.Strings(report.RemovedNodeIDs)
+    report.RemovedNodes = len(report.RemovedNodeIDs)
+
+    delete(s.subscriptions, sub.ID)
+    s.recordHistory(sub.ID, models.ActionDelete, report.RemovedNodes+report.OrphanedNodes,
+        fmt.Sprintf("删除订阅：%s，删除节点：%d个，保留节点：%d个", sub.Name, report.RemovedNodes, report.OrphanedNodes))
+    return report
+}
+
+// GetSubscription 获取订阅副本
+func (s *SubscriptionService) GetSubscription(id string) (*models.Subscription, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    sub, exists := s.subscriptions[id]
+    if !exists {
+        return nil, fmt.Errorf("subscription not found: %s", id)
+    }
+    c := *sub
+    return &c, nil
+}
+
+// GetSubscriptions 获取所有订阅的副本
 func (s *SubscriptionService) GetSubscriptions() []*models.Subscription {
+    return s.GetSubscriptionsByTags(nil)
+}
+
+// GetSubscriptionsByTags 获取带有任一指定标签的订阅的副本，tags为空时返回全部订阅
+func (s *SubscriptionService) GetSubscriptionsByTags(tags []string) []*models.Subscription {
+    wanted := toSet(tags)
+
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
     subs := make([]*models.Subscription, 0, len(s.subscriptions))
     for _, sub := range s.subscriptions {
-        subs = append(subs, sub)
+        if len(wanted) > 0 && !hasAnyTag(sub.Tags, wanted) {
+            continue
+        }
+        c := *sub
+        subs = append(subs, &c)
     }
     return subs
 }
 
-// MergeSubscriptions 合并订阅
-func (s *SubscriptionService) MergeSubscriptions(ids []string) error {
-    // TODO: 实现订阅合并逻辑
-    return nil
+// hasAnyTag 判断标签列表中是否包含任一指定标签
+func hasAnyTag(tags []string, wanted map[string]bool) bool {
+    for _, tag := range tags {
+        if wanted[tag] {
+            return true
+        }
+    }
+    return false
 }
 
-// TestNodes 测试节点
+// MergeSubscriptions 合并订阅，同时指定订阅ID和标签时只合并指定订阅中带有任一标签的节点
+func (s *SubscriptionService) MergeSubscriptions(ids, tags []string, dedupStrategy string, opts models.RenameOptions) (*models.MergeResult, error) {
+    merged, report, err := s.mergeNodes(ids, tags, dedupStrategy)
+    if err != nil {
+        return nil, err
+    }
+    subIDs := make(map[string]bool)
+    for _, node := range merged {
+        subIDs[node.SubscriptionID] = true
+    }
+
+    s.mu.RLock()
+    providers := s.providerNames()
+    s.mu.RUnlock()
+
+    renamed, err := renameNodes(merged, opts, providers)
+    if err != nil {
+        return nil, err
+    }
+
+    content, err := renderClashConfig(renamed)
+    if err != nil {
+        return nil, fmt.Errorf("render subscription failed: %v", err)
+    }
+
+    now := time.Now()
+    fileName := fmt.Sprintf("Sub-Input-%s.yaml", now.Format("01-02-15-04"))
+    filePath := filepath.Join(config.Get().Storage.Path, fileName)
+    if err := os.WriteFile(filePath, content, 0644); err != nil {
+        return nil, fmt.Errorf("write merged file failed: %v", err)
+    }
+
+    utils.LogSubscriptionMerge(len(subIDs))
+
+    return &models.MergeResult{
+        Timestamp: now,
+        FileName:  fileName,
+        NodeCount: len(renamed),
+        Dedup:     report,
+    }, nil
+}
+
+// mergeNodes 获取指定订阅中的节点并去重，不生成文件
+func (s *SubscriptionService) mergeNodes(ids, tags []string, dedupStrategy string) ([]*models.Node, *models.DedupReport, error) {
+    s.mu.RLock()
+    for _, id := range ids {
+        if _, exists := s.subscriptions[id]; !exists {
+            s.mu.RUnlock()
+            return nil, nil, fmt.Errorf("subscription not found: %s", id)
+        }
+    }
+    nodes, err := s.queryNodes(models.NodeQuery{SubscriptionIDs: ids, Tags: tags})
+    s.mu.RUnlock()
+    if err != nil {
+        return nil, nil, err
+    }
+
+    // 节点去重
+    merged, report, err := dedupNodes(nodes, dedupStrategy)
+    if err != nil {
+        return nil, nil, err
+    }
+    if len(merged) == 0 {
+        return nil, nil, fmt.Errorf("no nodes available")
+    }
+    return merged, report, nil
+}
+
+// providerNames 获取订阅ID到订阅名称的映射，调用方需持有锁
+func (s *SubscriptionService) providerNames() map[string]string {
+    names := make(map[string]string, len(s.subscriptions))
+    for id, sub := range s.subscriptions {
+        names[id] = sub.Name
+    }
+    return names
+}
+
+// DefaultSpeedTestConfig 根据配置文件生成默认测速配置
+func DefaultSpeedTestConfig() models.SpeedTestConfig {
+    cfg := config.Get()
+    return models.SpeedTestConfig{
+        MaxLatency:      cfg.Test.LatencyCutoff,
+        Timeout:         int(config.Duration(cfg.Test.SpeedTimeout, DefaultSpeedTimeout).Seconds()),
+        Concurrent:      cfg.Test.Concurrent,
+        SpeedConcurrent: cfg.Test.SpeedConcurrent,
+        MaxBytes:        config.ByteSize(cfg.Test.MaxBytes, DefaultMaxBytes),
+        RunBudget:       config.ByteSize(cfg.Test.RunBudget, 0),
+    }
+}
+
+// SpeedTestJobKey 全量测速任务的去重键，手动测速与定时测速共用，避免同时重复测速
+const SpeedTestJobKey = "speed_test:all"
+
+// TestAllNodes 使用默认配置测试所有节点
+func (s *SubscriptionService) TestAllNodes(ctx context.Context) error {
+    _, err := s.testNodes(ctx, s.allNodes(), DefaultSpeedTestConfig(), nil)
+    return err
+}
+
+// TestNodes 测试所有节点
 func (s *SubscriptionService) TestNodes(config models.SpeedTestConfig) (*models.SpeedTestResult, error) {
+    return s.testNodes(context.Background(), s.allNodes(), config, nil)
+}
+
+// StartTestJob 在后台测试所有节点，返回测速任务；已有全量测速任务未结束时返回该任务
+//
+// 每个节点测试完成后推送node事件，任务的部分结果随进度更新。
+func (s *SubscriptionService) StartTestJob(config models.SpeedTestConfig) *models.Job {
+    return DefaultJobManager.Start(models.JobTypeSpeedTest, SpeedTestJobKey, func(ctx context.Context, h *JobHandle) (interface{}, error) {
+        nodes := s.allNodes()
+        partial := &models.SpeedTestResult{
+            TotalCount:  len(nodes),
+            BudgetScope: models.BudgetScopeHost,
+            TestedNodes: make([]*models.Node, 0),
+        }
+        return s.testNodes(ctx, nodes, config, func(p *TestProgress) {
+            partial.LatencyTested = p.LatencyTested
+            partial.LatencyDropped = p.LatencyDropped
+            partial.SpeedTested = p.SpeedTested
+            partial.BudgetSkipped = p.BudgetSkipped
+            partial.BytesConsumed = p.BytesConsumed
+            partial.Progress = p.CurrentProgress
+            if p.Done {
+                partial.TestedNodes = append(partial.TestedNodes, p.Node)
+            }
+
+            snapshot := *partial
+            snapshot.TestedNodes = append([]*models.Node(nil), partial.TestedNodes...)
+            h.SetResult(&snapshot)
+            h.Progress(p.CurrentProgress, p.Stage, map[string]int{
+                "total":           p.TotalNodes,
+                "completed":       p.CompletedNodes,
+                "latency_tested":  p.LatencyTested,
+                "latency_dropped": p.LatencyDropped,
+                "speed_tested":    p.SpeedTested,
+                "failed":          p.Failed,
+                "budget_skipped":  p.BudgetSkipped,
+            })
+            h.Emit(JobEventNode, p)
+        })
+    })
+}
+
+// allNodes 获取所有节点
+func (s *SubscriptionService) allNodes() []*models.Node {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    nodes := make([]*models.Node, 0, len(s.nodes))
+    for _, node := range s.nodes {
+        nodes = append(nodes, node)
+    }
+    return nodes
+}
+
+// testNodes 分两个阶段测试指定节点，onProgress 不为空时每个节点完成一个阶段后回调
+//
+// 先以延迟测试并发数测试全部节点的延迟，再以下载测试并发数测试延迟不超过阈值的节点；
+// 延迟超过阈值的节点标记为skipped_latency，与下载速度为0的节点区分开。
+// ctx 取消后未开始测试的节点不再测试，已完成的结果仍会保存。
+// 测试在节点副本上进行，每个节点测试完成后在持有锁时写回测试结果。
+func (s *SubscriptionService) testNodes(ctx context.Context, nodes []*models.Node, testConfig models.SpeedTestConfig, onProgress func(*TestProgress)) (*models.SpeedTestResult, error) {
+    copied := make([]*models.Node, 0, len(nodes))
+    for _, node := range nodes {
+        c := *node
+        copied = append(copied, &c)
+    }
+    nodes = copied
+
     // 初始化测试结果
     result := &models.SpeedTestResult{
-        TotalCount:  len(s.nodes),
-        TestedNodes: make([]*models.Node, 0),
-    }
-
-    // 创建工作池
-    type workItem struct {
-        node *models.Node
-        err  error
-    }
-    jobs := make(chan *models.Node, result.TotalCount)
-    results := make(chan workItem, result.TotalCount)
-
-    // 启动工作协程
-    for i := 0; i < config.Concurrent; i++ {
-        go func() {
-            for node := range jobs {
-                // 测试延迟
-                latency, err := s.testNodeLatency(node, config.Timeout)
-                if err != nil {
-                    results <- workItem{node: node, err: err}
-                    continue
-                }
-
-                // 更新节点延迟
-                node.Latency = latency
-                result.LatencyTested++
-
-                // 如果延迟超过阈值，跳过下载测速
-                if latency > config.MaxLatency {
-                    result.LatencyDropped++
-                    results <- workItem{node: node, err: nil}
-                    continue
-                }
-
-                // 测试下载速度
-                speed, err := s.testNodeSpeed(node, config.TestURL, config.Timeout)
-                if err != nil {
-                    results <- workItem{node: node, err: err}
-                    continue
-                }
-
-                // 更新节点下载速度
-                node.DownloadSpeed = speed
-                result.SpeedTested++
-                
-                // 更新最后测试时间
-                node.LastTestedAt = time.Now()
-
-                results <- workItem{node: node, err: nil}
+        TotalCount:   len(nodes),
+        BudgetScope:  models.BudgetScopeHost,
+        TestedNodes:  make([]*models.Node, 0),
+        StatusCounts: make(map[string]int),
+        Stats: &models.SpeedTestStats{
+            StartTime:  time.Now(),
+            TotalNodes: len(nodes),
+        },
+    }
+    if testConfig.SpeedConcurrent <= 0 {
+        testConfig.SpeedConcurrent = testConfig.Concurrent
+    }
+    if testConfig.MaxBytes <= 0 {
+        testConfig.MaxBytes = DefaultMaxBytes
+    }
+
+    // 本机网络不可用时测速结果没有意义，直接失败
+    if err := DefaultTestTargets.EnsureChecked(ctx); err != nil {
+        return nil, err
+    }
+
+    tm := NewTestManager(&TestConfig{
+        LatencyTimeout:  config.Duration(config.Get().Test.LatencyTimeout, DefaultLatencyTimeout),
+        SpeedTimeout:    time.Duration(testConfig.Timeout) * time.Second,
+        MaxConcurrent:   testConfig.Concurrent,
+        SpeedConcurrent: testConfig.SpeedConcurrent,
+        MaxLatency:      testConfig.MaxLatency,
+        BufferSize:      DefaultBufferSize,
+        TestURL:         testConfig.TestURL,
+        Targets:         DefaultTestTargets,
+        MaxBytes:        testConfig.MaxBytes,
+        MaxDuration:     config.Duration(config.Get().Test.MaxDuration, DefaultMaxDuration),
+        SpeedWindow:     config.Duration(config.Get().Test.SpeedWindow, DefaultSpeedWindow),
+        Budget:          NewDataBudget(testConfig.RunBudget),
+    })
+    tm.OnProgress(onProgress)
+
+    completed, failed := 0, 0
+    // report 记录测试结果并推送进度，节点完成全部测试时final为true
+    report := func(stage string, node *models.Node, err error, final bool) {
+        if final && err != nil && ctx.Err() != nil {
+            // 测试被取消，节点并未完成测试，保留上次保存的测试结果
+            return
+        }
+        if final {
+            completed++
+            node.LastTestedAt = time.Now()
+            node.TestError = ""
+            if err != nil {
+                // 测试失败时清除上次的测试数据，避免残留
+                failed++
+                node.Latency = 0
+                node.DownloadSpeed = 0
+                node.TestStatus = classifyTestError(err)
+                node.TestError = err.Error()
+                result.Stats.FailedNodes++
+            } else {
+                result.Stats.SuccessNodes++
             }
-        }()
+            result.Stats.TestedNodes++
+            result.StatusCounts[node.TestStatus]++
+            result.TestedNodes = append(result.TestedNodes, node)
+            s.storeTestResult(node)
+
+            tm.SaveTestResult(&TestRecord{
+                ID:       fmt.Sprintf("test_%d", time.Now().UnixNano()),
+                NodeID:   node.ID,
+                Latency:  node.Latency,
+                Speed:    node.DownloadSpeed,
+                Status:   node.TestStatus,
+                TestTime: node.LastTestedAt,
+                Error:    node.TestError,
+            })
+        }
+
+        progress := &TestProgress{
+            TotalNodes:      result.TotalCount,
+            CompletedNodes:  completed,
+            CurrentProgress: result.Progress,
+            Stage:           stage,
+            Done:            final,
+            LatencyTested:   result.LatencyTested,
+            LatencyDropped:  result.LatencyDropped,
+            SpeedTested:     result.SpeedTested,
+            Failed:          failed,
+            BudgetSkipped:   result.BudgetSkipped,
+            BytesConsumed:   result.BytesConsumed,
+        }
+        n := *node
+        progress.Node = &n
+        if err != nil {
+            progress.Error = err.Error()
+        }
+        tm.UpdateTestProgress(progress)
     }
 
-    // 发送任务
-    go func() {
-        for _, node := range s.nodes {
-            jobs <- node
+    // 阶段一：延迟测试，占总进度的一半
+    candidates := make([]*models.Node, 0, len(nodes))
+    latencyDone := 0
+    tm.StartLatencyTest(ctx, nodes, func(node *models.Node, r *LatencyTestResult, err error) {
+        latencyDone++
+        result.Progress = float64(latencyDone) / float64(result.TotalCount) * 50
+        node.LatencyTestedAt = r.TestTime
+
+        if err != nil {
+            report(TestStageLatency, node, err, true)
+            return
         }
-        close(jobs)
-    }()
 
-    // 收集结果
-    for i := 0; i < result.TotalCount; i++ {
-        work := <-results
-        if work.err != nil {
-            utils.LogError("Test node failed: %v", work.err)
-            continue
+        result.LatencyTested++
+        node.Latency = r.Latency
+        if !tm.PassesLatency(node) {
+            // 延迟超过阈值，跳过下载测速
+            result.LatencyDropped++
+            node.DownloadSpeed = 0
+            node.TestStatus = models.TestStatusSkippedLatency
+            report(TestStageLatency, node, nil, true)
+            return
+        }
+
+        candidates = append(candidates, node)
+        report(TestStageLatency, node, nil, false)
+    })
+
+    // 阶段二：下载测试
+    speedDone := 0
+    tm.StartSpeedTest(ctx, candidates, func(node *models.Node, r *SpeedTestResult, err error) {
+        speedDone++
+        result.Progress = 50 + float64(speedDone)/float64(len(candidates))*50
+        node.SpeedTestedAt = r.TestTime
+        result.BytesConsumed += r.Bytes
+
+        if errors.Is(err, ErrBudgetExhausted) {
+            // 流量预算用完，跳过下载测速
+            result.BudgetSkipped++
+            node.DownloadSpeed = 0
+            node.TestStatus = models.TestStatusSkippedBudget
+            report(TestStageSpeed, node, nil, true)
+            return
         }
-        result.TestedNodes = append(result.TestedNodes, work.node)
-        result.Progress = float64(i+1) / float64(result.TotalCount) * 100
+        if err != nil {
+            report(TestStageSpeed, node, err, true)
+            return
+        }
+
+        result.SpeedTested++
+        node.DownloadSpeed = r.Speed
+        node.TestStatus = models.TestStatusOK
+        report(TestStageSpeed, node, nil, true)
+    })
+    if ctx.Err() == nil {
+        result.Progress = 100
     }
+    result.Stats.EndTime = time.Now()
+    result.BytesConsumed = tm.config.Budget.Consumed()
 
     // 记录测速日志
     utils.LogSpeedTest(
@@ -172,6 +1042,9 @@ func (s *SubscriptionService) TestNodes(config models.SpeedTestConfig) (*models.
         result.LatencyTested,
         result.LatencyDropped,
         result.SpeedTested,
+        failed,
+        result.BudgetSkipped,
+        result.BytesConsumed,
     )
 
     // 保存更新后的节点信息
@@ -179,39 +1052,188 @@ func (s *SubscriptionService) TestNodes(config models.SpeedTestConfig) (*models.
         return nil, fmt.Errorf("save test results failed: %v", err)
     }
 
-    return result, nil
+    return result, ctx.Err()
 }
 
-// testNodeLatency 测试节点延迟
-func (s *SubscriptionService) testNodeLatency(node *models.Node, timeout int) (int, error) {
-    // TODO: 实现实际的延迟测试逻辑
-    // 这里先使用模拟数据
-    time.Sleep(time.Duration(rand.Intn(100)) * time.Millisecond)
-    return rand.Intn(1000), nil
-}
+// storeTestResult 将节点副本的测试结果写回，测试期间节点被删除时忽略
+//
+// 只替换测试相关字段，保留测试期间对节点其他字段的修改（如标签）。
+func (s *SubscriptionService) storeTestResult(tested *models.Node) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
 
-// testNodeSpeed 测试节点下载速度
-func (s *SubscriptionService) testNodeSpeed(node *models.Node, testURL string, timeout int) (float64, error) {
-    // TODO: 实现实际的下载速度测试逻辑
-    // 这里先使用模拟数据
-    time.Sleep(time.Duration(rand.Intn(500)) * time.Millisecond)
-    return rand.Float64() * 10, nil
+    node, exists := s.nodes[tested.ID]
+    if !exists {
+        return
+    }
+    updated := *node
+    updated.Latency = tested.Latency
+    updated.DownloadSpeed = tested.DownloadSpeed
+    updated.TestStatus = tested.TestStatus
+    updated.TestError = tested.TestError
+    updated.LatencyTestedAt = tested.LatencyTestedAt
+    updated.SpeedTestedAt = tested.SpeedTestedAt
+    updated.LastTestedAt = tested.LastTestedAt
+    s.nodes[tested.ID] = &updated
 }
 
 // FilterNodes 筛选节点
 func (s *SubscriptionService) FilterNodes(condition models.FilterCondition) ([]*models.Node, error) {
-    // TODO: 实现节点筛选逻辑
-    return nil, nil
+    nodes, err := s.QueryNodes(models.NodeQuery{})
+    if err != nil {
+        return nil, err
+    }
+
+    filtered, err := filterNodes(nodes, condition)
+    if err != nil {
+        return nil, err
+    }
+
+    utils.LogNodeFilter(condition.MaxLatency, condition.MinDownloadSpeed, len(filtered))
+
+    return filtered, nil
+}
+
+// filterNodes 按查询条件和测速结果筛选给定节点
+func filterNodes(nodes []*models.Node, condition models.FilterCondition) ([]*models.Node, error) {
+    matcher, err := newNodeMatcher(condition.NodeQuery)
+    if err != nil {
+        return nil, err
+    }
+
+    filtered := make([]*models.Node, 0, len(nodes))
+    for _, node := range nodes {
+        if !matcher.Match(node) {
+            continue
+        }
+        // 按测速结果筛选
+        if condition.MaxLatency > 0 || condition.MinDownloadSpeed > 0 {
+            if node.LastTestedAt.IsZero() || node.TestFailed() {
+                continue
+            }
+        }
+        // 未进行下载测试的节点不参与速度筛选
+        if condition.MinDownloadSpeed > 0 && !node.SpeedMeasured() {
+            continue
+        }
+        if condition.MaxLatency > 0 && node.Latency > condition.MaxLatency {
+            continue
+        }
+        if condition.MinDownloadSpeed > 0 && node.DownloadSpeed < condition.MinDownloadSpeed {
+            continue
+        }
+        filtered = append(filtered, node)
+    }
+    return filtered, nil
+}
+
+// findNodes 按ID获取节点的当前数据，已删除的节点跳过
+func (s *SubscriptionService) findNodes(ids []string) []*models.Node {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    nodes := make([]*models.Node, 0, len(ids))
+    for _, id := range ids {
+        if node, exists := s.nodes[id]; exists {
+            nodes = append(nodes, node)
+        }
+    }
+    return nodes
+}
+
+// QueryNodes 按查询条件获取节点，结果按节点ID排序
+func (s *SubscriptionService) QueryNodes(query models.NodeQuery) ([]*models.Node, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return s.queryNodes(query)
+}
+
+// queryNodes 按查询条件获取节点，调用方需持有锁
+func (s *SubscriptionService) queryNodes(query models.NodeQuery) ([]*models.Node, error) {
+    matcher, err := newNodeMatcher(query)
+    if err != nil {
+        return nil, err
+    }
+
+    nodes := make([]*models.Node, 0, len(s.nodes))
+    for _, node := range s.nodes {
+        if matcher.Match(node) {
+            nodes = append(nodes, node)
+        }
+    }
+
+    sort.Slice(nodes, func(i, j int) bool {
+        return nodes[i].ID < nodes[j].ID
+    })
+
+    return nodes, nil
 }
 
 // GenerateSubscription 生成订阅文件
-func (s *SubscriptionService) GenerateSubscription(nodes []*models.Node) (string, error) {
-    // TODO: 实现生成订阅文件逻辑
-    return "", nil
+func (s *SubscriptionService) GenerateSubscription(nodes []*models.Node, opts models.RenameOptions) (string, error) {
+    return s.GenerateSubscriptionFile(nodes, opts, DefaultOutputFile)
+}
+
+// GenerateSubscriptionFile 生成订阅文件到存储目录下的指定文件
+func (s *SubscriptionService) GenerateSubscriptionFile(nodes []*models.Node, opts models.RenameOptions, fileName string) (string, error) {
+    if err := validateOutputFile(fileName); err != nil {
+        return "", err
+    }
+    if len(nodes) == 0 {
+        return "", fmt.Errorf("no nodes available")
+    }
+
+    s.mu.RLock()
+    providers := s.providerNames()
+    s.mu.RUnlock()
+
+    renamed, err := renameNodes(nodes, opts, providers)
+    if err != nil {
+        return "", err
+    }
+
+    content, err := renderClashConfig(renamed)
+    if err != nil {
+        return "", fmt.Errorf("render subscription failed: %v", err)
+    }
+
+    filePath := filepath.Join(config.Get().Storage.Path, fileName)
+    if err := os.WriteFile(filePath, content, 0644); err != nil {
+        return "", fmt.Errorf("write subscription file failed: %v", err)
+    }
+
+    if err := s.AddSubscriptionHistory("", models.ActionGenerate, len(nodes),
+        fmt.Sprintf("生成优选节点订阅，共%d个节点", len(nodes))); err != nil {
+        utils.LogError("Add subscription history failed: %v", err)
+    }
+
+    return fileName, nil
+}
+
+// validateOutputFile 校验输出文件名，只允许存储目录下的yaml文件
+func validateOutputFile(fileName string) error {
+    if fileName == "" || fileName != filepath.Base(fileName) || strings.HasPrefix(fileName, ".") {
+        return fmt.Errorf("invalid output file name: %s", fileName)
+    }
+    if ext := strings.ToLower(filepath.Ext(fileName)); ext != ".yaml" && ext != ".yml" {
+        return fmt.Errorf("output file must be a yaml file: %s", fileName)
+    }
+    return nil
 }
 
 // AddSubscriptionHistory 添加订阅历史记录
 func (s *SubscriptionService) AddSubscriptionHistory(subscriptionID string, action string, nodeCount int, details string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.recordHistory(subscriptionID, action, nodeCount, details)
+
+    // 保存到文件
+    return s.save()
+}
+
+// recordHistory 记录订阅历史（不保存到文件），调用方需持有锁
+func (s *SubscriptionService) recordHistory(subscriptionID string, action string, nodeCount int, details string) {
     history := &models.SubscriptionHistory{
         ID:             fmt.Sprintf("hist_%d", time.Now().UnixNano()),
         SubscriptionID: subscriptionID,
@@ -226,13 +1248,31 @@ func (s *SubscriptionService) AddSubscriptionHistory(subscriptionID string, acti
     // 记录日志
     utils.LogInfo("Added subscription history: Action=%s, SubscriptionID=%s, NodeCount=%d",
         action, subscriptionID, nodeCount)
+}
 
-    // 保存到文件
-    return s.SaveToFile()
+// LatestHistory 获取指定操作类型的最近一条历史记录
+func (s *SubscriptionService) LatestHistory(action string) *models.SubscriptionHistory {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    var latest *models.SubscriptionHistory
+    for _, h := range s.history {
+        if h.Action == action && (latest == nil || h.CreatedAt.After(latest.CreatedAt)) {
+            latest = h
+        }
+    }
+    return latest
 }
 
 // SaveToFile 保存数据到文件
 func (s *SubscriptionService) SaveToFile() error {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return s.save()
+}
+
+// save 保存数据到文件，调用方需持有锁
+func (s *SubscriptionService) save() error {
     data := struct {
         Subscriptions map[string]*models.Subscription        `json:"subscriptions"`
         Nodes        map[string]*models.Node                `json:"nodes"`
@@ -248,13 +1288,13 @@ func (s *SubscriptionService) SaveToFile() error {
         return err
     }
     
-    dataPath := filepath.Join(config.GlobalConfig.Storage.Path, "data.json")
+    dataPath := filepath.Join(config.Get().Storage.Path, "data.json")
     return os.WriteFile(dataPath, jsonData, 0644)
 }
 
 // LoadFromFile 从文件加载数据
 func (s *SubscriptionService) LoadFromFile() error {
-    dataPath := filepath.Join(config.GlobalConfig.Storage.Path, "data.json")
+    dataPath := filepath.Join(config.Get().Storage.Path, "data.json")
     data, err := os.ReadFile(dataPath)
     if err != nil {
         if os.IsNotExist(err) {
@@ -272,72 +1312,100 @@ func (s *SubscriptionService) LoadFromFile() error {
     if err := json.Unmarshal(data, &stored); err != nil {
         return err
     }
-    
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
     s.subscriptions = stored.Subscriptions
     s.nodes = stored.Nodes
     s.history = stored.History
+
+    // 补全旧数据中缺失的地区信息
+    for _, node := range s.nodes {
+        if node.Region == "" {
+            node.Region = utils.DetectNodeRegion(node.Alias, node.Address)
+        }
+    }
     return nil
 }
 
-// ImportNodesFromFile 从YAML文件导入节点
-func (s *SubscriptionService) ImportNodesFromFile(filePath string) (*models.ImportResult, error) {
-    // 读取YAML文件
-    data, err := os.ReadFile(filePath)
+// GetRegionStats 统计各地区节点数
+func (s *SubscriptionService) GetRegionStats() map[string]int {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    stats := make(map[string]int)
+    for _, node := range s.nodes {
+        region := node.Region
+        if region == "" {
+            region = "unknown"
+        }
+        stats[region]++
+    }
+    return stats
+}
+
+// ImportNodesFromFile 从导入目录中的文件导入节点，路径相对于导入目录，不允许访问目录之外的文件
+func (s *SubscriptionService) ImportNodesFromFile(filePath string, dedupStrategy string) (*models.ImportResult, error) {
+    path, err := utils.ResolveImportPath(filePath)
+    if err != nil {
+        return nil, err
+    }
+
+    info, err := os.Stat(path)
     if err != nil {
         return nil, fmt.Errorf("read file failed: %v", err)
     }
+    if max := config.ByteSize(config.Get().Storage.MaxUploadSize, 0); max > 0 && info.Size() > max {
+        return nil, fmt.Errorf("file too large: %d bytes (max %d)", info.Size(), max)
+    }
 
-    // 解析YAML
-    var config struct {
-        Proxies []map[string]interface{} `yaml:"proxies"`
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("read file failed: %v", err)
     }
-    if err := yaml.Unmarshal(data, &config); err != nil {
-        return nil, fmt.Errorf("parse yaml failed: %v", err)
+    return s.ImportNodesFromContent(data, dedupStrategy)
+}
+
+// ImportNodesFromContent 导入上传的节点文件，支持YAML、JSON、Base64和分享链接列表
+func (s *SubscriptionService) ImportNodesFromContent(data []byte, dedupStrategy string) (*models.ImportResult, error) {
+    parsed, err := utils.ParseSubscriptionContent(string(data))
+    if err != nil {
+        return nil, err
     }
 
     result := &models.ImportResult{
-        TotalCount: len(config.Proxies),
+        TotalCount: parsed.NodeCount,
         Nodes:      make([]*models.Node, 0),
     }
 
-    // 节点去重map
-    nodeMap := make(map[string]bool)
-
-    // 解析节点
-    for _, proxy := range config.Proxies {
-        nodeType, _ := proxy["type"].(string)
-        server, _ := proxy["server"].(string)
-        port, _ := proxy["port"].(int)
-        name, _ := proxy["name"].(string)
-
-        // 生成唯一标识
-        key := fmt.Sprintf("%s-%s-%d", nodeType, server, port)
-        if nodeMap[key] {
-            result.DuplicateCount++
-            continue
+    for _, node := range parsed.Nodes {
+        node.ID = fmt.Sprintf("node_%d", time.Now().UnixNano())
+        // 保留 proxy-providers 中节点的集合名称，其余节点归入 imported 分组
+        if node.Group == "" {
+            node.Group = "imported"
         }
+    }
 
-        // 创建节点
-        node := &models.Node{
-            ID:        fmt.Sprintf("node_%d", time.Now().UnixNano()),
-            Type:      nodeType,
-            Alias:     name,
-            Address:   server,
-            Port:      port,
-            Protocol:  s.getNodeProtocol(proxy),
-            Group:     "imported",
-        }
+    // 节点去重
+    nodes, report, err := dedupNodes(parsed.Nodes, dedupStrategy)
+    if err != nil {
+        return nil, err
+    }
+    result.Dedup = report
+    result.DuplicateCount = len(report.Removed)
 
-        nodeMap[key] = true
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    // 保存到内存
+    for _, node := range nodes {
         result.Nodes = append(result.Nodes, node)
         result.ImportedCount++
-
-        // 保存到内存
         s.nodes[node.ID] = node
     }
 
     // 保存到文件
-    if err := s.SaveToFile(); err != nil {
+    if err := s.save(); err != nil {
         return nil, fmt.Errorf("save to file failed: %v", err)
     }
 
@@ -346,19 +1414,24 @@ func (s *SubscriptionService) ImportNodesFromFile(filePath string) (*models.Impo
 
 // GetNodeList 获取节点列表
 func (s *SubscriptionService) GetNodeList(query models.NodeListQuery) (*models.NodeList, error) {
+    // 兼容单一类型筛选参数
+    if query.Type != "" {
+        query.Types = append(query.Types, query.Type)
+    }
+
     // 过滤节点
-    var filteredNodes []*models.Node
-    for _, node := range s.nodes {
-        if query.Type != "" && node.Type != query.Type {
-            continue
-        }
-        filteredNodes = append(filteredNodes, node)
+    filteredNodes, err := s.QueryNodes(query.NodeQuery)
+    if err != nil {
+        return nil, err
     }
 
     // 计算分页
     total := len(filteredNodes)
     start := (query.Page - 1) * query.PageSize
     end := start + query.PageSize
+    if start > total {
+        start = total
+    }
     if end > total {
         end = total
     }
@@ -370,24 +1443,4 @@ func (s *SubscriptionService) GetNodeList(query models.NodeListQuery) (*models.N
         PageSize: query.PageSize,
         Nodes:    filteredNodes[start:end],
     }, nil
-}
-
-// getNodeProtocol 获取节点传输协议
-func (s *SubscriptionService) getNodeProtocol(proxy map[string]interface{}) string {
-    nodeType, _ := proxy["type"].(string)
-    switch nodeType {
-    case "vmess":
-        if network, ok := proxy["network"].(string); ok {
-            return network
-        }
-        return "tcp"
-    case "ss":
-        return "shadowsocks"
-    case "hysteria2":
-        return "hysteria2"
-    case "trojan":
-        return "trojan"
-    default:
-        return "unknown"
-    }
-} 
\ No newline at end of file
+}
\ No newline at end of file
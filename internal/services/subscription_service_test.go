@@ -0,0 +1,400 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "reflect"
+    "sort"
+    "subsmanager/config"
+    "subsmanager/internal/models"
+    "testing"
+)
+
+// newTestService 创建使用临时存储目录的订阅服务，不读取也不改动真实数据
+func newTestService(t *testing.T, subs []*models.Subscription, nodes []*models.Node) *SubscriptionService {
+    t.Helper()
+    useTempStorage(t)
+
+    s := &SubscriptionService{
+        subscriptions: make(map[string]*models.Subscription),
+        nodes:         make(map[string]*models.Node),
+        history:       make(map[string]*models.SubscriptionHistory),
+    }
+    for _, sub := range subs {
+        s.subscriptions[sub.ID] = sub
+    }
+    for _, node := range nodes {
+        s.nodes[node.ID] = node
+    }
+    return s
+}
+
+// useTempStorage 测试期间将存储目录指向临时目录，结束后恢复
+func useTempStorage(t *testing.T) string {
+    t.Helper()
+    dir := t.TempDir()
+    t.Cleanup(config.Override(func(c *config.Config) { c.Storage.Path = dir }))
+    return dir
+}
+
+// ssNode 创建测试用的 shadowsocks 节点
+func ssNode(id, subID, address string, port int, password string) *models.Node {
+    return &models.Node{
+        ID:             id,
+        Type:           "ss",
+        Alias:          id,
+        Address:        address,
+        Port:           port,
+        SubscriptionID: subID,
+        Params:         map[string]interface{}{"cipher": "aes-128-gcm", "password": password},
+    }
+}
+
+func TestUpdateSubscriptionsCounts(t *testing.T) {
+    subs := []*models.Subscription{
+        {ID: "local", Name: "local", Source: models.SubscriptionSourceLocal},
+        {ID: "disabled", Name: "disabled", URL: "http://127.0.0.1:1/sub", Disabled: true},
+    }
+
+    tests := []struct {
+        name    string
+        ids     []string
+        skipped int
+        wantErr bool
+    }{
+        {name: "local", ids: []string{"local"}, skipped: 1},
+        {name: "local and disabled", ids: []string{"local", "disabled"}, skipped: 2},
+        {name: "missing", ids: []string{"local", "missing"}, skipped: 1, wantErr: true},
+        {name: "empty", ids: nil},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestService(t, subs, nil)
+            updated, skipped, err := s.UpdateSubscriptions(context.Background(), tt.ids)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("UpdateSubscriptions() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            if updated != 0 {
+                t.Errorf("updated = %d, want 0", updated)
+            }
+            if skipped != tt.skipped {
+                t.Errorf("skipped = %d, want %d", skipped, tt.skipped)
+            }
+        })
+    }
+}
+
+// bulkFixture 创建批量操作测试用的订阅和节点，每次调用返回新的对象
+func bulkFixture() ([]*models.Subscription, []*models.Node) {
+    subs := []*models.Subscription{
+        {ID: "sub1", Name: "sub1", Source: models.SubscriptionSourceLocal, Tags: []string{"hk"}, NodeCount: 2},
+        {ID: "sub2", Name: "sub2", Source: models.SubscriptionSourceLocal, Tags: []string{"jp"}, NodeCount: 1},
+    }
+    nodes := []*models.Node{
+        ssNode("n1", "sub1", "hk1.example.com", 443, "p1"),
+        ssNode("n2", "sub1", "hk2.example.com", 443, "p2"),
+        ssNode("n3", "sub2", "jp.example.com", 443, "p3"),
+    }
+    for _, node := range nodes {
+        if node.SubscriptionID == "sub1" {
+            node.Tags = []string{"hk"}
+        } else {
+            node.Tags = []string{"jp"}
+        }
+    }
+    return subs, nodes
+}
+
+func TestBulkUpdateSubscriptions(t *testing.T) {
+    tests := []struct {
+        name    string
+        req     models.SubscriptionBulkRequest
+        applied bool
+        errors  map[string]string // 订阅ID → 期望的错误信息，未列出的订阅应成功
+        check   func(t *testing.T, s *SubscriptionService)
+    }{
+        {
+            name:    "tag",
+            req:     models.SubscriptionBulkRequest{Action: models.BulkActionTag, IDs: []string{"sub1", "sub2", "sub1"}, AddTags: []string{"asia", " hk "}, RemoveTags: []string{"jp"}},
+            applied: true,
+            check: func(t *testing.T, s *SubscriptionService) {
+                wantTags(t, s, "sub1", []string{"hk", "asia"})
+                wantTags(t, s, "sub2", []string{"asia", "hk"})
+                if got := s.nodes["n3"].Tags; !reflect.DeepEqual(got, []string{"asia", "hk"}) {
+                    t.Errorf("node n3 tags = %v, want [asia hk]", got)
+                }
+            },
+        },
+        {
+            name:    "disable",
+            req:     models.SubscriptionBulkRequest{Action: models.BulkActionDisable, IDs: []string{"sub2"}},
+            applied: true,
+            check: func(t *testing.T, s *SubscriptionService) {
+                if s.subscriptions["sub1"].Disabled || !s.subscriptions["sub2"].Disabled {
+                    t.Errorf("disabled = %v/%v, want false/true", s.subscriptions["sub1"].Disabled, s.subscriptions["sub2"].Disabled)
+                }
+            },
+        },
+        {
+            name:    "delete",
+            req:     models.SubscriptionBulkRequest{Action: models.BulkActionDelete, IDs: []string{"sub1"}},
+            applied: true,
+            check: func(t *testing.T, s *SubscriptionService) {
+                wantState(t, s, []string{"sub2"}, []string{"n3"})
+            },
+        },
+        {
+            name:    "delete keep nodes",
+            req:     models.SubscriptionBulkRequest{Action: models.BulkActionDelete, IDs: []string{"sub1"}, KeepNodes: true},
+            applied: true,
+            check: func(t *testing.T, s *SubscriptionService) {
+                wantState(t, s, []string{"sub2"}, []string{"n1", "n2", "n3"})
+                if s.nodes["n1"].SubscriptionID != "" {
+                    t.Errorf("node n1 subscription = %q, want orphaned", s.nodes["n1"].SubscriptionID)
+                }
+            },
+        },
+        {
+            name:   "refresh local rolls back",
+            req:    models.SubscriptionBulkRequest{Action: models.BulkActionRefresh, IDs: []string{"sub1"}},
+            errors: map[string]string{"sub1": "local subscription cannot be refreshed: sub1"},
+            check: func(t *testing.T, s *SubscriptionService) {
+                wantState(t, s, []string{"sub1", "sub2"}, []string{"n1", "n2", "n3"})
+            },
+        },
+        {
+            name:   "delete with missing rolls back",
+            req:    models.SubscriptionBulkRequest{Action: models.BulkActionDelete, IDs: []string{"sub1", "missing"}},
+            errors: map[string]string{"sub1": "rolled back", "missing": "subscription not found: missing"},
+            check: func(t *testing.T, s *SubscriptionService) {
+                wantState(t, s, []string{"sub1", "sub2"}, []string{"n1", "n2", "n3"})
+                if len(s.history) != 0 {
+                    t.Errorf("history has %d entries, want rolled back", len(s.history))
+                }
+            },
+        },
+        {
+            name:    "delete with missing allow partial",
+            req:     models.SubscriptionBulkRequest{Action: models.BulkActionDelete, IDs: []string{"sub1", "missing"}, AllowPartial: true},
+            applied: true,
+            errors:  map[string]string{"missing": "subscription not found: missing"},
+            check: func(t *testing.T, s *SubscriptionService) {
+                wantState(t, s, []string{"sub2"}, []string{"n3"})
+            },
+        },
+        {
+            name:   "tag with missing rolls back",
+            req:    models.SubscriptionBulkRequest{Action: models.BulkActionTag, IDs: []string{"sub1", "missing"}, AddTags: []string{"asia"}},
+            errors: map[string]string{"sub1": "rolled back", "missing": "subscription not found: missing"},
+            check: func(t *testing.T, s *SubscriptionService) {
+                wantTags(t, s, "sub1", []string{"hk"})
+                if got := s.nodes["n1"].Tags; !reflect.DeepEqual(got, []string{"hk"}) {
+                    t.Errorf("node n1 tags = %v, want [hk]", got)
+                }
+            },
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            subs, nodes := bulkFixture()
+            s := newTestService(t, subs, nodes)
+
+            report, err := s.BulkUpdateSubscriptions(tt.req)
+            if err != nil {
+                t.Fatalf("BulkUpdateSubscriptions() error = %v", err)
+            }
+            if report.Applied != tt.applied {
+                t.Errorf("applied = %v, want %v", report.Applied, tt.applied)
+            }
+            for _, result := range report.Results {
+                want, failed := tt.errors[result.ID]
+                if result.Success == failed || result.Error != want {
+                    t.Errorf("result %s = %v %q, want error %q", result.ID, result.Success, result.Error, want)
+                }
+            }
+
+            // 只有保存成功的修改写入数据文件
+            _, statErr := os.Stat(filepath.Join(config.Get().Storage.Path, "data.json"))
+            if saved := statErr == nil; saved != tt.applied {
+                t.Errorf("data.json saved = %v, want %v", saved, tt.applied)
+            }
+            tt.check(t, s)
+        })
+    }
+}
+
+func TestSnapshotRestore(t *testing.T) {
+    subs, nodes := bulkFixture()
+    s := newTestService(t, subs, nodes)
+    sub1 := s.subscriptions["sub1"]
+
+    snap := s.snapshot()
+    sub1.Tags = append(sub1.Tags, "asia")
+    sub1.Disabled = true
+    s.syncNodeTags(sub1)
+    s.removeSubscription(s.subscriptions["sub2"], false)
+    s.nodes["n4"] = ssNode("n4", "sub1", "hk4.example.com", 443, "p4")
+    s.restore(snap)
+
+    wantState(t, s, []string{"sub1", "sub2"}, []string{"n1", "n2", "n3"})
+    if s.subscriptions["sub1"] != sub1 {
+        t.Error("restore replaced the subscription object")
+    }
+    if sub1.Disabled || !reflect.DeepEqual(sub1.Tags, []string{"hk"}) {
+        t.Errorf("sub1 = disabled %v tags %v, want enabled [hk]", sub1.Disabled, sub1.Tags)
+    }
+    if got := s.nodes["n1"].Tags; !reflect.DeepEqual(got, []string{"hk"}) {
+        t.Errorf("node n1 tags = %v, want [hk]", got)
+    }
+    if len(s.history) != 0 {
+        t.Errorf("history has %d entries, want 0", len(s.history))
+    }
+}
+
+// wantTags 校验订阅标签
+func wantTags(t *testing.T, s *SubscriptionService, id string, want []string) {
+    t.Helper()
+    if got := s.subscriptions[id].Tags; !reflect.DeepEqual(got, want) {
+        t.Errorf("subscription %s tags = %v, want %v", id, got, want)
+    }
+}
+
+// wantState 校验剩余的订阅和节点
+func wantState(t *testing.T, s *SubscriptionService, wantSubs, wantNodes []string) {
+    t.Helper()
+    var gotSubs []string
+    for _, sub := range s.GetSubscriptions() {
+        gotSubs = append(gotSubs, sub.ID)
+    }
+    sort.Strings(gotSubs)
+    if !reflect.DeepEqual(gotSubs, wantSubs) {
+        t.Errorf("subscriptions = %v, want %v", gotSubs, wantSubs)
+    }
+    nodes, err := s.QueryNodes(models.NodeQuery{})
+    if err != nil {
+        t.Fatalf("QueryNodes() error = %v", err)
+    }
+    if got := nodeIDs(nodes); !reflect.DeepEqual(got, wantNodes) {
+        t.Errorf("nodes = %v, want %v", got, wantNodes)
+    }
+}
+
+func TestUpdateSubscriptionFailure(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path != "/sub" {
+            http.NotFound(w, r)
+            return
+        }
+        fmt.Fprintln(w, "trojan://p@new.example.com:443#new")
+    }))
+    defer srv.Close()
+
+    newURL, missingURL := srv.URL+"/sub", srv.URL+"/missing"
+    tags := []string{"asia"}
+    tests := []struct {
+        name         string
+        update       models.SubscriptionUpdate
+        failSave     bool
+        wantFetchErr bool
+    }{
+        {name: "fetch failed", update: models.SubscriptionUpdate{URL: &missingURL}, wantFetchErr: true},
+        {name: "save failed", update: models.SubscriptionUpdate{Tags: &tags}, failSave: true},
+        {name: "save failed after url change", update: models.SubscriptionUpdate{URL: &newURL, Tags: &tags}, failSave: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            subs, nodes := bulkFixture()
+            subs[0].Source = models.SubscriptionSourceURL
+            subs[0].URL = "http://old.example.com/sub"
+            s := newTestService(t, subs, nodes)
+            if tt.failSave {
+                // data.json 为目录时写入失败
+                if err := os.Mkdir(filepath.Join(config.Get().Storage.Path, "data.json"), 0755); err != nil {
+                    t.Fatal(err)
+                }
+            }
+
+            _, err := s.UpdateSubscription("sub1", tt.update)
+            if err == nil {
+                t.Fatal("UpdateSubscription() error = nil, want error")
+            }
+            if got := errors.Is(err, ErrFetchSubscription); got != tt.wantFetchErr {
+                t.Errorf("errors.Is(%v, ErrFetchSubscription) = %v, want %v", err, got, tt.wantFetchErr)
+            }
+
+            wantState(t, s, []string{"sub1", "sub2"}, []string{"n1", "n2", "n3"})
+            wantTags(t, s, "sub1", []string{"hk"})
+            if got := s.subscriptions["sub1"].URL; got != "http://old.example.com/sub" {
+                t.Errorf("sub1 url = %q, want unchanged", got)
+            }
+            if got := s.nodes["n1"].Tags; !reflect.DeepEqual(got, []string{"hk"}) {
+                t.Errorf("node n1 tags = %v, want [hk]", got)
+            }
+            if len(s.history) != 0 {
+                t.Errorf("history has %d entries, want 0", len(s.history))
+            }
+        })
+    }
+}
+
+func TestImportSubscriptionContentUniqueIDs(t *testing.T) {
+    s := newTestService(t, nil, nil)
+
+    contents := []string{
+        "trojan://p@a.example.com:443#a\n",
+        "trojan://p@b.example.com:443#b\n",
+        "trojan://p@c.example.com:443#c\n",
+    }
+    ids := make(map[string]bool)
+    for i, content := range contents {
+        sub, err := s.ImportSubscriptionContent(fmt.Sprintf("local %d", i), []byte(content), nil)
+        if err != nil {
+            t.Fatalf("ImportSubscriptionContent() error = %v", err)
+        }
+        ids[sub.ID] = true
+    }
+
+    if len(ids) != len(contents) || len(s.subscriptions) != len(contents) {
+        t.Fatalf("got %d IDs and %d subscriptions, want %d", len(ids), len(s.subscriptions), len(contents))
+    }
+    for _, node := range s.nodes {
+        if !ids[node.SubscriptionID] {
+            t.Errorf("node %s belongs to unknown subscription %s", node.Alias, node.SubscriptionID)
+        }
+    }
+    if len(s.nodes) != len(contents) {
+        t.Errorf("got %d nodes, want %d", len(s.nodes), len(contents))
+    }
+}
+
+func TestImportNodesFromContentGroups(t *testing.T) {
+    s := newTestService(t, nil, nil)
+    content := `proxies:
+  - {name: direct, type: ss, server: direct.example.com, port: 443, cipher: aes-128-gcm, password: p}
+proxy-providers:
+  hk:
+    type: inline
+    payload:
+      - {name: hk, type: ss, server: hk.example.com, port: 443, cipher: aes-128-gcm, password: p}
+`
+
+    result, err := s.ImportNodesFromContent([]byte(content), "")
+    if err != nil {
+        t.Fatalf("ImportNodesFromContent() error = %v", err)
+    }
+    groups := make(map[string]string)
+    for _, node := range result.Nodes {
+        groups[node.Alias] = node.Group
+    }
+    if want := map[string]string{"direct": "imported", "hk": "hk"}; !reflect.DeepEqual(groups, want) {
+        t.Errorf("groups = %v, want %v", groups, want)
+    }
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "math/rand"
+    "net"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "subsmanager/config"
+    "subsmanager/internal/models"
+    "sync"
+    "time"
+)
+
+// 单个测速目标健康检查超时时间
+const healthCheckTimeout = 5 * time.Second
+
+// TestTargets 管理网络探测URL和下载测试目标
+//
+// 下载测试按权重选择健康的目标，目标不可达时依次切换到其他目标；
+// 探测URL用于在测速前确认本机网络可用，全部不可达时不进行测速。
+type TestTargets struct {
+    mu        sync.RWMutex
+    probes    []*models.TestTargetStatus
+    downloads []*models.TestTargetStatus
+    interval  time.Duration
+    checkedAt time.Time
+}
+
+// DefaultTestTargets 全局测速目标
+var DefaultTestTargets = &TestTargets{}
+
+// InitTestTargets 按配置加载测速目标，配置变更后重新加载
+func InitTestTargets() {
+    DefaultTestTargets.Load(*config.Get())
+    config.OnChange(func(old, cur config.Config) {
+        DefaultTestTargets.Load(cur)
+    })
+}
+
+// Load 按配置加载测速目标，保留URL未变化的目标的健康状态
+func (t *TestTargets) Load(cfg config.Config) {
+    downloads := cfg.Test.DownloadTargets
+    if len(downloads) == 0 {
+        // 兼容旧配置：test_url 作为首选目标，内置测速服务器作为备用
+        if cfg.Test.TestURL != "" {
+            downloads = append(downloads, config.TestTarget{URL: cfg.Test.TestURL, Weight: 1})
+        }
+        for _, u := range speedTestServers {
+            if u != cfg.Test.TestURL {
+                downloads = append(downloads, config.TestTarget{URL: u, Weight: 0})
+            }
+        }
+    }
+
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    t.probes = buildTargets(models.TestTargetLatency, cfg.Test.LatencyProbes, t.probes)
+    t.downloads = buildTargets(models.TestTargetDownload, downloads, t.downloads)
+    t.interval = config.Duration(cfg.Test.HealthCheckInterval, 10*time.Minute)
+    t.checkedAt = time.Time{}
+}
+
+// buildTargets 根据配置生成目标列表，未检查过的目标默认视为健康
+func buildTargets(kind string, targets []config.TestTarget, old []*models.TestTargetStatus) []*models.TestTargetStatus {
+    prev := make(map[string]*models.TestTargetStatus, len(old))
+    for _, s := range old {
+        prev[s.URL] = s
+    }
+
+    result := make([]*models.TestTargetStatus, 0, len(targets))
+    for _, target := range targets {
+        status := &models.TestTargetStatus{
+            Kind:    kind,
+            URL:     target.URL,
+            Weight:  target.Weight,
+            Healthy: true,
+        }
+        if p, ok := prev[target.URL]; ok {
+            status.Healthy = p.Healthy
+            status.Latency = p.Latency
+            status.LastCheck = p.LastCheck
+            status.LastError = p.LastError
+        }
+        result = append(result, status)
+    }
+    return result
+}
+
+// List 获取所有目标及其健康状态
+func (t *TestTargets) List() []*models.TestTargetStatus {
+    t.mu.RLock()
+    defer t.mu.RUnlock()
+
+    result := make([]*models.TestTargetStatus, 0, len(t.probes)+len(t.downloads))
+    for _, s := range append(append([]*models.TestTargetStatus{}, t.probes...), t.downloads...) {
+        c := *s
+        result = append(result, &c)
+    }
+    return result
+}
+
+// CheckHealth 并发检查所有目标的健康状态
+func (t *TestTargets) CheckHealth(ctx context.Context) []*models.TestTargetStatus {
+    t.mu.RLock()
+    targets := append(append([]*models.TestTargetStatus{}, t.probes...), t.downloads...)
+    t.mu.RUnlock()
+
+    type checkResult struct {
+        latency int
+        err     error
+    }
+    results := make([]checkResult, len(targets))
+    var wg sync.WaitGroup
+    for i, target := range targets {
+        wg.Add(1)
+        go func(i int, target *models.TestTargetStatus) {
+            defer wg.Done()
+            latency, err := checkTarget(ctx, target)
+            results[i] = checkResult{latency: latency, err: err}
+        }(i, target)
+    }
+    wg.Wait()
+
+    t.mu.Lock()
+    now := time.Now()
+    for i, target := range targets {
+        target.LastCheck = now
+        target.Latency = results[i].latency
+        target.Healthy = results[i].err == nil
+        target.LastError = ""
+        if results[i].err != nil {
+            target.LastError = results[i].err.Error()
+        }
+    }
+    t.checkedAt = now
+    t.mu.Unlock()
+
+    return t.List()
+}
+
+// checkTarget 检查单个目标：探测URL使用GET，下载目标使用HEAD避免下载文件内容
+func checkTarget(ctx context.Context, target *models.TestTargetStatus) (int, error) {
+    ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+    defer cancel()
+
+    method := http.MethodGet
+    if target.Kind == models.TestTargetDownload {
+        method = http.MethodHead
+    }
+    req, err := http.NewRequestWithContext(ctx, method, ResolveTargetURL(target.URL), nil)
+    if err != nil {
+        return 0, err
+    }
+
+    start := time.Now()
+    resp, err := http.DefaultClient.Do(req)
+    if err != nil {
+        return 0, err
+    }
+    resp.Body.Close()
+    if resp.StatusCode >= http.StatusBadRequest {
+        return 0, fmt.Errorf("unexpected status: %s", resp.Status)
+    }
+    return int(time.Since(start).Milliseconds()), nil
+}
+
+// EnsureChecked 距上次检查超过检查间隔时重新检查；配置了探测URL但全部不可达时返回错误
+func (t *TestTargets) EnsureChecked(ctx context.Context) error {
+    t.mu.RLock()
+    due := time.Since(t.checkedAt) > t.interval
+    t.mu.RUnlock()
+    if due {
+        t.CheckHealth(ctx)
+    }
+
+    t.mu.RLock()
+    defer t.mu.RUnlock()
+
+    if len(t.probes) == 0 {
+        return nil
+    }
+    for _, p := range t.probes {
+        if p.Healthy {
+            return nil
+        }
+    }
+    return fmt.Errorf("network unreachable: all latency probes failed")
+}
+
+// downloadCandidates 按尝试顺序返回下载目标
+//
+// 首个目标从健康且权重大于0的目标中按权重随机选择，其余健康目标按权重降序排列，不健康的目标排在最后。
+func (t *TestTargets) downloadCandidates() []string {
+    t.mu.RLock()
+    defer t.mu.RUnlock()
+
+    healthy := make([]*models.TestTargetStatus, 0, len(t.downloads))
+    unhealthy := make([]*models.TestTargetStatus, 0)
+    total := 0
+    for _, s := range t.downloads {
+        if s.Healthy {
+            healthy = append(healthy, s)
+            total += s.Weight
+        } else {
+            unhealthy = append(unhealthy, s)
+        }
+    }
+    sort.SliceStable(healthy, func(i, j int) bool {
+        return healthy[i].Weight > healthy[j].Weight
+    })
+
+    // 按权重随机选出首个目标
+    if total > 0 {
+        n := rand.Intn(total)
+        for i, s := range healthy {
+            if n < s.Weight {
+                healthy[0], healthy[i] = healthy[i], healthy[0]
+                break
+            }
+            n -= s.Weight
+        }
+    }
+
+    urls := make([]string, 0, len(t.downloads))
+    for _, s := range append(healthy, unhealthy...) {
+        urls = append(urls, s.URL)
+    }
+    return urls
+}
+
+// markResult 根据下载结果更新目标健康状态
+func (t *TestTargets) markResult(targetURL string, err error) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    for _, s := range t.downloads {
+        if s.URL != targetURL {
+            continue
+        }
+        s.Healthy = err == nil
+        s.LastCheck = time.Now()
+        s.LastError = ""
+        if err != nil {
+            s.LastError = err.Error()
+        }
+    }
+}
+
+// ResolveTargetURL 将 builtin:<size> 转换为内置测速端点地址，其他URL原样返回
+func ResolveTargetURL(target string) string {
+    if !strings.HasPrefix(target, config.BuiltinTargetPrefix) {
+        return target
+    }
+
+    host := config.Get().Server.Host
+    if host == "" || host == "0.0.0.0" || host == "::" {
+        host = "127.0.0.1"
+    }
+    addr := net.JoinHostPort(host, strconv.Itoa(config.Get().Server.Port))
+    return fmt.Sprintf("http://%s/speedtest/%s", addr, strings.TrimPrefix(target, config.BuiltinTargetPrefix))
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+    "fmt"
+    "net"
+    "sync"
+
+    "github.com/oschwald/maxminddb-golang"
+)
+
+// geoIPRecord mmdb国家记录
+type geoIPRecord struct {
+    Country struct {
+        ISOCode string `maxminddb:"iso_code"`
+    } `maxminddb:"country"`
+}
+
+var (
+    geoIPReader    *maxminddb.Reader
+    geoIPResolve   bool
+    geoIPReaderMux sync.RWMutex
+)
+
+// InitGeoIP 加载本地MaxMind格式的mmdb文件，resolve为true时会解析域名地址
+func InitGeoIP(path string, resolve bool) error {
+    reader, err := maxminddb.Open(path)
+    if err != nil {
+        return fmt.Errorf("open mmdb file failed: %v", err)
+    }
+
+    geoIPReaderMux.Lock()
+    defer geoIPReaderMux.Unlock()
+    if geoIPReader != nil {
+        geoIPReader.Close()
+    }
+    geoIPReader = reader
+    geoIPResolve = resolve
+    return nil
+}
+
+// LookupRegion 通过mmdb查询地址所在地区，未加载mmdb或查询失败时返回空字符串
+func LookupRegion(address string) string {
+    geoIPReaderMux.RLock()
+    defer geoIPReaderMux.RUnlock()
+    if geoIPReader == nil {
+        return ""
+    }
+
+    ip := net.ParseIP(address)
+    if ip == nil {
+        if !geoIPResolve {
+            return ""
+        }
+        ips, err := net.LookupIP(address)
+        if err != nil || len(ips) == 0 {
+            return ""
+        }
+        ip = ips[0]
+    }
+
+    var record geoIPRecord
+    if err := geoIPReader.Lookup(ip, &record); err != nil {
+        return ""
+    }
+    return record.Country.ISOCode
+}
+
+// DetectNodeRegion 识别节点地区，优先使用别名关键字，其次使用mmdb
+func DetectNodeRegion(alias, address string) string {
+    if code := DetectRegion(alias); code != "" {
+        return code
+    }
+    return LookupRegion(address)
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+    "fmt"
+    "path/filepath"
+    "strings"
+    "subsmanager/config"
+)
+
+// ImportDir 按路径导入节点、读取文件类型proxy-provider时允许访问的目录
+func ImportDir() string {
+    cfg := config.Get()
+    return filepath.Join(cfg.Storage.Path, cfg.Storage.ImportDir)
+}
+
+// ResolveImportPath 将相对路径解析为导入目录中的文件路径，符号链接指向目录之外时同样拒绝
+func ResolveImportPath(filePath string) (string, error) {
+    clean := filepath.Clean(filePath)
+    if filePath == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+        return "", fmt.Errorf("file path must be relative to the import directory: %s", filePath)
+    }
+
+    root, err := filepath.EvalSymlinks(ImportDir())
+    if err != nil {
+        return "", fmt.Errorf("import directory unavailable: %v", err)
+    }
+    path, err := filepath.EvalSymlinks(filepath.Join(root, clean))
+    if err != nil {
+        return "", fmt.Errorf("read file failed: %v", err)
+    }
+    if rel, err := filepath.Rel(root, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+        return "", fmt.Errorf("file path must be relative to the import directory: %s", filePath)
+    }
+    return path, nil
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+    "subsmanager/internal/models"
+    "sync"
+)
+
+// 默认信息节点关键字
+var DefaultInfoNodeKeywords = []string{
+    "剩余流量", "已用流量", "总流量", "套餐到期", "到期时间", "过期时间",
+    "流量重置", "距离下次重置", "官网", "官方网站", "网址", "客服", "群组",
+}
+
+// 默认信息节点正则
+var DefaultInfoNodePatterns = []string{
+    `(?i)^\s*(remaining|traffic|expire|expiry|reset)\b`,
+    `(?i)^\s*(官网|网址)?\s*https?://`,
+}
+
+// infoNodeRules 信息节点过滤规则
+type infoNodeRules struct {
+    keywords []string
+    patterns []*regexp.Regexp
+}
+
+var (
+    infoRules    *infoNodeRules
+    infoRulesMux sync.RWMutex
+
+    // 信息提取规则，流量信息要求以关键字开头（允许前置emoji等符号），避免匹配 "流量重置" 等说明
+    infoTrafficPattern = regexp.MustCompile(`^[^\p{L}\p{N}]*(剩余流量|已用流量|总流量)\s*[:：]?\s*(.+)`)
+    infoExpirePattern  = regexp.MustCompile(`(套餐到期|到期时间|过期时间|到期)\s*[:：]?\s*(.+)`)
+    infoWebsitePattern = regexp.MustCompile(`(官网|官方网站|网址)\s*[:：]?\s*(.+)`)
+)
+
+func init() {
+    if err := SetInfoNodeRules(DefaultInfoNodeKeywords, DefaultInfoNodePatterns); err != nil {
+        panic(err)
+    }
+}
+
+// SetInfoNodeRules 设置信息节点过滤规则
+func SetInfoNodeRules(keywords, patterns []string) error {
+    rules := &infoNodeRules{}
+    for _, k := range keywords {
+        if k = strings.TrimSpace(k); k != "" {
+            rules.keywords = append(rules.keywords, strings.ToLower(k))
+        }
+    }
+    for _, p := range patterns {
+        re, err := regexp.Compile(p)
+        if err != nil {
+            return fmt.Errorf("invalid info node pattern %q: %v", p, err)
+        }
+        rules.patterns = append(rules.patterns, re)
+    }
+
+    infoRulesMux.Lock()
+    infoRules = rules
+    infoRulesMux.Unlock()
+    return nil
+}
+
+// IsInfoNode 判断节点名称是否为订阅信息伪节点
+func IsInfoNode(alias string) bool {
+    infoRulesMux.RLock()
+    rules := infoRules
+    infoRulesMux.RUnlock()
+
+    lower := strings.ToLower(alias)
+    for _, k := range rules.keywords {
+        if strings.Contains(lower, k) {
+            return true
+        }
+    }
+    for _, re := range rules.patterns {
+        if re.MatchString(alias) {
+            return true
+        }
+    }
+    return false
+}
+
+// ExtractInfoNodes 剔除信息伪节点，并提取其中的流量、到期等订阅信息
+func ExtractInfoNodes(nodes []*models.Node) ([]*models.Node, *models.SubscriptionInfo) {
+    kept := make([]*models.Node, 0, len(nodes))
+    var info *models.SubscriptionInfo
+
+    for _, node := range nodes {
+        if !IsInfoNode(node.Alias) {
+            kept = append(kept, node)
+            continue
+        }
+
+        if info == nil {
+            info = &models.SubscriptionInfo{}
+        }
+        alias := strings.TrimSpace(node.Alias)
+        switch {
+        case infoTrafficPattern.MatchString(alias):
+            m := infoTrafficPattern.FindStringSubmatch(alias)
+            setInfoField(info, m[1], strings.TrimSpace(m[2]))
+        case infoExpirePattern.MatchString(alias):
+            info.ExpireAt = strings.TrimSpace(infoExpirePattern.FindStringSubmatch(alias)[2])
+        case infoWebsitePattern.MatchString(alias):
+            info.Website = strings.TrimSpace(infoWebsitePattern.FindStringSubmatch(alias)[2])
+        default:
+            info.Notes = append(info.Notes, alias)
+        }
+    }
+
+    if info != nil {
+        LogInfo("Removed %d info nodes from subscription", len(nodes)-len(kept))
+    }
+
+    return kept, info
+}
+
+// setInfoField 按关键字记录流量信息，同一项出现多次时保留最先出现的值
+func setInfoField(info *models.SubscriptionInfo, keyword, value string) {
+    var field *string
+    switch keyword {
+    case "已用流量":
+        field = &info.TrafficUsed
+    case "总流量":
+        field = &info.TrafficTotal
+    default:
+        field = &info.Traffic
+    }
+    if *field == "" {
+        *field = value
+    }
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+    "reflect"
+    "subsmanager/internal/models"
+    "testing"
+)
+
+func TestExtractInfoNodes(t *testing.T) {
+    tests := []struct {
+        name    string
+        aliases []string
+        kept    []string
+        info    *models.SubscriptionInfo
+    }{
+        {
+            name:    "no info nodes",
+            aliases: []string{"香港 01", "日本 02"},
+            kept:    []string{"香港 01", "日本 02"},
+        },
+        {
+            name:    "traffic kinds stored separately",
+            aliases: []string{"剩余流量：10GB", "已用流量：90GB", "总流量：100GB", "香港 01"},
+            kept:    []string{"香港 01"},
+            info:    &models.SubscriptionInfo{Traffic: "10GB", TrafficUsed: "90GB", TrafficTotal: "100GB"},
+        },
+        {
+            name:    "reset date goes to notes",
+            aliases: []string{"流量重置：每月1日", "🚀 剩余流量：10GB", "距离下次重置剩余：12 天"},
+            kept:    []string{},
+            info:    &models.SubscriptionInfo{Traffic: "10GB", Notes: []string{"流量重置：每月1日", "距离下次重置剩余：12 天"}},
+        },
+        {
+            name:    "first value wins",
+            aliases: []string{"剩余流量：10GB", "剩余流量：20GB"},
+            kept:    []string{},
+            info:    &models.SubscriptionInfo{Traffic: "10GB"},
+        },
+        {
+            name:    "expire and website",
+            aliases: []string{"套餐到期：2026-12-31", "官网：https://example.com"},
+            kept:    []string{},
+            info:    &models.SubscriptionInfo{ExpireAt: "2026-12-31", Website: "https://example.com"},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            nodes := make([]*models.Node, 0, len(tt.aliases))
+            for _, alias := range tt.aliases {
+                nodes = append(nodes, &models.Node{Alias: alias})
+            }
+
+            kept, info := ExtractInfoNodes(nodes)
+            got := make([]string, 0, len(kept))
+            for _, node := range kept {
+                got = append(got, node.Alias)
+            }
+            if !reflect.DeepEqual(got, tt.kept) {
+                t.Errorf("kept = %q, want %q", got, tt.kept)
+            }
+            if !reflect.DeepEqual(info, tt.info) {
+                t.Errorf("info = %+v, want %+v", info, tt.info)
+            }
+        })
+    }
+}
@@ -0,0 +1,465 @@
+package utils
+
+import (
+    "encoding/json"
+    "fmt"
+    "net"
+    "strconv"
+    "strings"
+    "subsmanager/internal/models"
+)
+
+// jsonConfig sing-box、Xray/V2Ray 或 OpenClash 格式的JSON配置
+type jsonConfig struct {
+    Outbounds      []map[string]interface{} `json:"outbounds"`
+    Proxies        []map[string]interface{} `json:"proxies"`
+    ProxyProviders map[string]interface{}   `json:"proxy-providers"`
+}
+
+// parseJSONSubscription 解析JSON格式的订阅
+//
+// 支持 sing-box 配置（outbounds[].type）、Xray/V2Ray 配置（outbounds[].protocol）
+// 和 OpenClash 格式的JSON；部分订阅以数组形式提供多个完整配置，逐个解析后合并。
+func parseJSONSubscription(content string) ([]*models.Node, error) {
+    content = strings.TrimSpace(content)
+    if !strings.HasPrefix(content, "[") {
+        return parseJSONConfig(content)
+    }
+
+    var configs []json.RawMessage
+    if err := json.Unmarshal([]byte(content), &configs); err != nil {
+        return nil, fmt.Errorf("json unmarshal failed: %v", err)
+    }
+    nodes := make([]*models.Node, 0)
+    for i, raw := range configs {
+        configNodes, err := parseJSONConfig(string(raw))
+        if err != nil {
+            LogParseError("json", fmt.Sprintf("config[%d]", i), err)
+            continue
+        }
+        nodes = append(nodes, configNodes...)
+    }
+    return nodes, nil
+}
+
+// parseJSONConfig 解析单个JSON配置
+func parseJSONConfig(content string) ([]*models.Node, error) {
+    var config jsonConfig
+    if err := json.Unmarshal([]byte(content), &config); err != nil {
+        return nil, fmt.Errorf("json unmarshal failed: %v", err)
+    }
+
+    if len(config.Outbounds) > 0 {
+        // 出站转换为Clash格式的代理配置，复用Clash节点的解析逻辑
+        proxies := make([]map[string]interface{}, 0, len(config.Outbounds))
+        for _, outbound := range config.Outbounds {
+            var proxy map[string]interface{}
+            var err error
+            if _, ok := outbound["protocol"]; ok {
+                proxy, err = xrayOutboundToProxy(outbound)
+            } else {
+                proxy, err = singBoxOutboundToProxy(outbound)
+            }
+            if err != nil {
+                LogParseError("json", jsonString(outbound, "tag"), err)
+                continue
+            }
+            if proxy != nil {
+                proxies = append(proxies, proxy)
+            }
+        }
+        return parseYAMLProxies(proxies), nil
+    }
+
+    if config.Proxies != nil || config.ProxyProviders != nil {
+        return parseYAMLSubscription(content)
+    }
+
+    return nil, fmt.Errorf("unsupported json format")
+}
+
+// singBoxOutboundToProxy 将 sing-box 出站转换为Clash格式的代理配置，非代理出站返回nil
+func singBoxOutboundToProxy(outbound map[string]interface{}) (map[string]interface{}, error) {
+    outboundType := jsonString(outbound, "type")
+    server := jsonString(outbound, "server")
+    port := jsonInt(outbound, "server_port")
+
+    name := jsonString(outbound, "tag")
+    if name == "" {
+        // 未设置tag时与Xray出站一样使用地址作为名称
+        name = net.JoinHostPort(server, strconv.Itoa(port))
+    }
+    proxy := map[string]interface{}{
+        "name":   name,
+        "server": server,
+        "port":   port,
+    }
+
+    switch outboundType {
+    case "shadowsocks":
+        proxy["type"] = NodeTypeSS
+        proxy["cipher"] = jsonString(outbound, "method")
+        proxy["password"] = jsonString(outbound, "password")
+        if plugin := jsonString(outbound, "plugin"); plugin != "" {
+            setSSPlugin(proxy, plugin, jsonString(outbound, "plugin_opts"))
+        }
+        // udp_over_tcp 可以是布尔值，也可以是 {"enabled": true, "version": 2} 形式的对象
+        if uot := jsonObject(outbound, "udp_over_tcp"); uot != nil {
+            if jsonBool(uot, "enabled") {
+                proxy["udp-over-tcp"] = true
+                if version := jsonInt(uot, "version"); version > 0 {
+                    proxy["udp-over-tcp-version"] = version
+                }
+            }
+        } else if jsonBool(outbound, "udp_over_tcp") {
+            proxy["udp-over-tcp"] = true
+        }
+    case "vmess":
+        proxy["type"] = NodeTypeVmess
+        proxy["uuid"] = jsonString(outbound, "uuid")
+        proxy["alterId"] = jsonInt(outbound, "alter_id")
+        proxy["cipher"] = jsonString(outbound, "security")
+        if proxy["cipher"] == "" {
+            proxy["cipher"] = "auto"
+        }
+    case "vless":
+        proxy["type"] = NodeTypeVless
+        proxy["uuid"] = jsonString(outbound, "uuid")
+        if flow := jsonString(outbound, "flow"); flow != "" {
+            proxy["flow"] = flow
+        }
+    case "trojan":
+        proxy["type"] = NodeTypeTrojan
+        proxy["password"] = jsonString(outbound, "password")
+    case "hysteria2":
+        proxy["type"] = NodeTypeHysteria2
+        proxy["password"] = jsonString(outbound, "password")
+        if up := jsonInt(outbound, "up_mbps"); up > 0 {
+            proxy["up"] = up
+        }
+        if down := jsonInt(outbound, "down_mbps"); down > 0 {
+            proxy["down"] = down
+        }
+        if obfs := jsonObject(outbound, "obfs"); obfs != nil {
+            proxy["obfs"] = jsonString(obfs, "type")
+            proxy["obfs-password"] = jsonString(obfs, "password")
+        }
+    case "tuic":
+        proxy["type"] = NodeTypeTUIC
+        proxy["uuid"] = jsonString(outbound, "uuid")
+        proxy["password"] = jsonString(outbound, "password")
+        if cc := jsonString(outbound, "congestion_control"); cc != "" {
+            proxy["congestion-controller"] = cc
+        }
+        if mode := jsonString(outbound, "udp_relay_mode"); mode != "" {
+            proxy["udp-relay-mode"] = mode
+        }
+        if jsonBool(outbound, "zero_rtt_handshake") {
+            proxy["reduce-rtt"] = true
+        }
+    default:
+        // direct、block、dns、selector、urltest 等非代理出站
+        return nil, nil
+    }
+
+    if server == "" || port == 0 {
+        return nil, fmt.Errorf("missing server or server_port")
+    }
+
+    if tls := jsonObject(outbound, "tls"); tls != nil && jsonBool(tls, "enabled") {
+        setTLS(proxy, tlsOptions{
+            serverName:  jsonString(tls, "server_name"),
+            insecure:    jsonBool(tls, "insecure"),
+            alpn:        jsonStrings(tls, "alpn"),
+            fingerprint: jsonString(jsonObject(tls, "utls"), "fingerprint"),
+        })
+        if reality := jsonObject(tls, "reality"); reality != nil && jsonBool(reality, "enabled") {
+            proxy["reality-opts"] = map[string]interface{}{
+                "public-key": jsonString(reality, "public_key"),
+                "short-id":   jsonString(reality, "short_id"),
+            }
+        }
+    }
+
+    if transport := jsonObject(outbound, "transport"); transport != nil {
+        switch jsonString(transport, "type") {
+        case "ws":
+            setWebSocket(proxy, jsonString(transport, "path"), jsonString(jsonObject(transport, "headers"), "Host"), false)
+        case "httpupgrade":
+            setWebSocket(proxy, jsonString(transport, "path"), jsonString(transport, "host"), true)
+        case "grpc":
+            setGRPC(proxy, jsonString(transport, "service_name"))
+        case "http":
+            setHTTP2(proxy, jsonStrings(transport, "host"), jsonString(transport, "path"))
+        }
+    }
+
+    return proxy, nil
+}
+
+// xrayOutboundToProxy 将 Xray/V2Ray 出站转换为Clash格式的代理配置，非代理出站返回nil
+func xrayOutboundToProxy(outbound map[string]interface{}) (map[string]interface{}, error) {
+    protocol := jsonString(outbound, "protocol")
+    settings := jsonObject(outbound, "settings")
+
+    // vmess/vless 的服务器信息位于 settings.vnext，trojan/shadowsocks 位于 settings.servers
+    var server, user map[string]interface{}
+    switch protocol {
+    case "vmess", "vless":
+        server = jsonFirst(settings, "vnext")
+        user = jsonFirst(server, "users")
+    case "trojan", "shadowsocks":
+        server = jsonFirst(settings, "servers")
+        user = server
+    default:
+        // freedom、blackhole、dns 等非代理出站
+        return nil, nil
+    }
+    if server == nil || user == nil {
+        return nil, fmt.Errorf("missing server settings")
+    }
+
+    address := jsonString(server, "address")
+    port := jsonInt(server, "port")
+    if address == "" || port == 0 {
+        return nil, fmt.Errorf("missing address or port")
+    }
+
+    name := jsonString(outbound, "tag")
+    if name == "" || name == "proxy" {
+        // 单节点配置的出站通常统一命名为proxy，改用地址作为名称
+        name = net.JoinHostPort(address, strconv.Itoa(port))
+    }
+    proxy := map[string]interface{}{
+        "name":   name,
+        "server": address,
+        "port":   port,
+    }
+
+    switch protocol {
+    case "vmess":
+        proxy["type"] = NodeTypeVmess
+        proxy["uuid"] = jsonString(user, "id")
+        proxy["alterId"] = jsonInt(user, "alterId")
+        proxy["cipher"] = jsonString(user, "security")
+        if proxy["cipher"] == "" {
+            proxy["cipher"] = "auto"
+        }
+    case "vless":
+        proxy["type"] = NodeTypeVless
+        proxy["uuid"] = jsonString(user, "id")
+        if flow := jsonString(user, "flow"); flow != "" {
+            proxy["flow"] = flow
+        }
+    case "trojan":
+        proxy["type"] = NodeTypeTrojan
+        proxy["password"] = jsonString(user, "password")
+    case "shadowsocks":
+        proxy["type"] = NodeTypeSS
+        proxy["cipher"] = jsonString(user, "method")
+        proxy["password"] = jsonString(user, "password")
+    }
+
+    stream := jsonObject(outbound, "streamSettings")
+    if stream == nil {
+        return proxy, nil
+    }
+
+    switch jsonString(stream, "security") {
+    case "tls":
+        tls := jsonObject(stream, "tlsSettings")
+        setTLS(proxy, tlsOptions{
+            serverName:  jsonString(tls, "serverName"),
+            insecure:    jsonBool(tls, "allowInsecure"),
+            alpn:        jsonStrings(tls, "alpn"),
+            fingerprint: jsonString(tls, "fingerprint"),
+        })
+    case "reality":
+        reality := jsonObject(stream, "realitySettings")
+        setTLS(proxy, tlsOptions{
+            serverName:  jsonString(reality, "serverName"),
+            fingerprint: jsonString(reality, "fingerprint"),
+        })
+        proxy["reality-opts"] = map[string]interface{}{
+            "public-key": jsonString(reality, "publicKey"),
+            "short-id":   jsonString(reality, "shortId"),
+        }
+    }
+
+    switch jsonString(stream, "network") {
+    case "ws":
+        ws := jsonObject(stream, "wsSettings")
+        host := jsonString(ws, "host")
+        if host == "" {
+            host = jsonString(jsonObject(ws, "headers"), "Host")
+        }
+        setWebSocket(proxy, jsonString(ws, "path"), host, false)
+    case "httpupgrade":
+        upgrade := jsonObject(stream, "httpupgradeSettings")
+        setWebSocket(proxy, jsonString(upgrade, "path"), jsonString(upgrade, "host"), true)
+    case "grpc":
+        setGRPC(proxy, jsonString(jsonObject(stream, "grpcSettings"), "serviceName"))
+    case "h2", "http":
+        h2 := jsonObject(stream, "httpSettings")
+        setHTTP2(proxy, jsonStrings(h2, "host"), jsonString(h2, "path"))
+    }
+
+    return proxy, nil
+}
+
+// tlsOptions 出站的TLS参数
+type tlsOptions struct {
+    serverName  string
+    insecure    bool
+    alpn        []string
+    fingerprint string
+}
+
+// setTLS 按Clash字段名设置TLS参数，trojan/hysteria2/tuic 使用 sni，其他类型使用 servername
+func setTLS(proxy map[string]interface{}, opts tlsOptions) {
+    sniKey := "servername"
+    switch proxy["type"] {
+    case NodeTypeTrojan, NodeTypeHysteria2, NodeTypeTUIC:
+        sniKey = "sni"
+    default:
+        proxy["tls"] = true
+    }
+    if opts.serverName != "" {
+        proxy[sniKey] = opts.serverName
+    }
+    if opts.insecure {
+        proxy["skip-cert-verify"] = true
+    }
+    if len(opts.alpn) > 0 {
+        proxy["alpn"] = opts.alpn
+    }
+    if opts.fingerprint != "" {
+        proxy["client-fingerprint"] = opts.fingerprint
+    }
+}
+
+// setWebSocket 设置WebSocket传输参数，httpUpgrade 表示使用HTTPUpgrade
+func setWebSocket(proxy map[string]interface{}, path, host string, httpUpgrade bool) {
+    opts := map[string]interface{}{}
+    if path != "" {
+        opts["path"] = path
+    }
+    if host != "" {
+        opts["headers"] = map[string]interface{}{"Host": host}
+    }
+    if httpUpgrade {
+        opts["v2ray-http-upgrade"] = true
+    }
+    proxy["network"] = "ws"
+    proxy["ws-opts"] = opts
+}
+
+// setGRPC 设置gRPC传输参数
+func setGRPC(proxy map[string]interface{}, serviceName string) {
+    proxy["network"] = "grpc"
+    proxy["grpc-opts"] = map[string]interface{}{"grpc-service-name": serviceName}
+}
+
+// setHTTP2 设置HTTP/2传输参数
+func setHTTP2(proxy map[string]interface{}, hosts []string, path string) {
+    opts := map[string]interface{}{}
+    if len(hosts) > 0 {
+        opts["host"] = hosts
+    }
+    if path != "" {
+        opts["path"] = path
+    }
+    proxy["network"] = "h2"
+    proxy["h2-opts"] = opts
+}
+
+// setSSPlugin 将 SIP003 插件参数（如 obfs=http;obfs-host=example.com）转换为Clash的 plugin-opts
+func setSSPlugin(proxy map[string]interface{}, plugin, pluginOpts string) {
+    opts := map[string]interface{}{}
+    for _, item := range strings.Split(pluginOpts, ";") {
+        key, value, found := strings.Cut(item, "=")
+        key = strings.TrimSpace(key)
+        if key == "" {
+            continue
+        }
+        if !found {
+            // 无值的参数为开关，如 v2ray-plugin 的 tls
+            opts[key] = true
+            continue
+        }
+        opts[key] = value
+    }
+
+    if plugin == "obfs-local" || plugin == "simple-obfs" {
+        plugin = "obfs"
+        if mode, ok := opts["obfs"]; ok {
+            opts["mode"] = mode
+            delete(opts, "obfs")
+        }
+        if host, ok := opts["obfs-host"]; ok {
+            opts["host"] = host
+            delete(opts, "obfs-host")
+        }
+    }
+    proxy["plugin"] = plugin
+    proxy["plugin-opts"] = opts
+}
+
+// jsonString 读取字符串字段，m 为nil或字段类型不符时返回空字符串
+func jsonString(m map[string]interface{}, key string) string {
+    s, _ := m[key].(string)
+    return s
+}
+
+// jsonInt 读取数值字段，兼容字符串形式的数字
+func jsonInt(m map[string]interface{}, key string) int {
+    switch v := m[key].(type) {
+    case float64:
+        return int(v)
+    case string:
+        n, _ := strconv.Atoi(v)
+        return n
+    }
+    return 0
+}
+
+// jsonBool 读取布尔字段
+func jsonBool(m map[string]interface{}, key string) bool {
+    b, _ := m[key].(bool)
+    return b
+}
+
+// jsonObject 读取对象字段
+func jsonObject(m map[string]interface{}, key string) map[string]interface{} {
+    obj, _ := m[key].(map[string]interface{})
+    return obj
+}
+
+// jsonFirst 读取对象数组字段的第一个元素
+func jsonFirst(m map[string]interface{}, key string) map[string]interface{} {
+    list, _ := m[key].([]interface{})
+    if len(list) == 0 {
+        return nil
+    }
+    obj, _ := list[0].(map[string]interface{})
+    return obj
+}
+
+// jsonStrings 读取字符串数组字段，单个字符串视为只有一个元素的数组
+func jsonStrings(m map[string]interface{}, key string) []string {
+    switch v := m[key].(type) {
+    case string:
+        if v != "" {
+            return []string{v}
+        }
+    case []interface{}:
+        result := make([]string, 0, len(v))
+        for _, item := range v {
+            if s, ok := item.(string); ok && s != "" {
+                result = append(result, s)
+            }
+        }
+        return result
+    }
+    return nil
+}
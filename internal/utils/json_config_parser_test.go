@@ -0,0 +1,234 @@
+package utils
+
+import (
+    "encoding/json"
+    "fmt"
+    "reflect"
+    "testing"
+)
+
+// decodeOutbound 将JSON出站解析为 map，与 parseJSONConfig 的输入一致
+func decodeOutbound(t *testing.T, content string) map[string]interface{} {
+    t.Helper()
+    var outbound map[string]interface{}
+    if err := json.Unmarshal([]byte(content), &outbound); err != nil {
+        t.Fatalf("invalid test outbound: %v", err)
+    }
+    return outbound
+}
+
+func TestSingBoxOutboundToProxy(t *testing.T) {
+    tests := []struct {
+        name     string
+        outbound string
+        want     map[string]interface{}
+        wantErr  bool
+    }{
+        {
+            name:     "shadowsocks",
+            outbound: `{"type": "shadowsocks", "tag": "ss", "server": "ss.example.com", "server_port": 8388, "method": "aes-128-gcm", "password": "p"}`,
+            want:     map[string]interface{}{"name": "ss", "type": "ss", "server": "ss.example.com", "port": 8388, "cipher": "aes-128-gcm", "password": "p"},
+        },
+        {
+            name:     "shadowsocks udp_over_tcp bool",
+            outbound: `{"type": "shadowsocks", "tag": "ss", "server": "ss.example.com", "server_port": 8388, "method": "aes-128-gcm", "password": "p", "udp_over_tcp": true}`,
+            want:     map[string]interface{}{"name": "ss", "type": "ss", "server": "ss.example.com", "port": 8388, "cipher": "aes-128-gcm", "password": "p", "udp-over-tcp": true},
+        },
+        {
+            name:     "shadowsocks udp_over_tcp object",
+            outbound: `{"type": "shadowsocks", "tag": "ss", "server": "ss.example.com", "server_port": 8388, "method": "aes-128-gcm", "password": "p", "udp_over_tcp": {"enabled": true, "version": 2}}`,
+            want:     map[string]interface{}{"name": "ss", "type": "ss", "server": "ss.example.com", "port": 8388, "cipher": "aes-128-gcm", "password": "p", "udp-over-tcp": true, "udp-over-tcp-version": 2},
+        },
+        {
+            name:     "shadowsocks udp_over_tcp object disabled",
+            outbound: `{"type": "shadowsocks", "tag": "ss", "server": "ss.example.com", "server_port": 8388, "method": "aes-128-gcm", "password": "p", "udp_over_tcp": {"enabled": false, "version": 2}}`,
+            want:     map[string]interface{}{"name": "ss", "type": "ss", "server": "ss.example.com", "port": 8388, "cipher": "aes-128-gcm", "password": "p"},
+        },
+        {
+            name:     "shadowsocks obfs plugin",
+            outbound: `{"type": "shadowsocks", "tag": "ss", "server": "ss.example.com", "server_port": 8388, "method": "aes-128-gcm", "password": "p", "plugin": "obfs-local", "plugin_opts": "obfs=http;obfs-host=cdn.example.com"}`,
+            want: map[string]interface{}{"name": "ss", "type": "ss", "server": "ss.example.com", "port": 8388, "cipher": "aes-128-gcm", "password": "p",
+                "plugin": "obfs", "plugin-opts": map[string]interface{}{"mode": "http", "host": "cdn.example.com"}},
+        },
+        {
+            name:     "vmess ws tls",
+            outbound: `{"type": "vmess", "tag": "vm", "server": "vm.example.com", "server_port": "443", "uuid": "u", "tls": {"enabled": true, "server_name": "sni.example.com", "alpn": "h2"}, "transport": {"type": "ws", "path": "/ws", "headers": {"Host": "cdn.example.com"}}}`,
+            want: map[string]interface{}{"name": "vm", "type": "vmess", "server": "vm.example.com", "port": 443, "uuid": "u", "alterId": 0, "cipher": "auto",
+                "tls": true, "servername": "sni.example.com", "alpn": []string{"h2"},
+                "network": "ws", "ws-opts": map[string]interface{}{"path": "/ws", "headers": map[string]interface{}{"Host": "cdn.example.com"}}},
+        },
+        {
+            name:     "vless reality grpc",
+            outbound: `{"type": "vless", "tag": "vl", "server": "vl.example.com", "server_port": 443, "uuid": "u", "flow": "xtls-rprx-vision", "tls": {"enabled": true, "server_name": "www.example.com", "utls": {"fingerprint": "chrome"}, "reality": {"enabled": true, "public_key": "pk", "short_id": "sid"}}, "transport": {"type": "grpc", "service_name": "svc"}}`,
+            want: map[string]interface{}{"name": "vl", "type": "vless", "server": "vl.example.com", "port": 443, "uuid": "u", "flow": "xtls-rprx-vision",
+                "tls": true, "servername": "www.example.com", "client-fingerprint": "chrome",
+                "reality-opts": map[string]interface{}{"public-key": "pk", "short-id": "sid"},
+                "network": "grpc", "grpc-opts": map[string]interface{}{"grpc-service-name": "svc"}},
+        },
+        {
+            name:     "trojan sni",
+            outbound: `{"type": "trojan", "tag": "tj", "server": "tj.example.com", "server_port": 443, "password": "p", "tls": {"enabled": true, "server_name": "sni.example.com", "insecure": true}}`,
+            want:     map[string]interface{}{"name": "tj", "type": "trojan", "server": "tj.example.com", "port": 443, "password": "p", "sni": "sni.example.com", "skip-cert-verify": true},
+        },
+        {
+            name:     "hysteria2",
+            outbound: `{"type": "hysteria2", "tag": "hy", "server": "hy.example.com", "server_port": 443, "password": "p", "up_mbps": 50, "down_mbps": 100, "obfs": {"type": "salamander", "password": "o"}}`,
+            want:     map[string]interface{}{"name": "hy", "type": "hysteria2", "server": "hy.example.com", "port": 443, "password": "p", "up": 50, "down": 100, "obfs": "salamander", "obfs-password": "o"},
+        },
+        {
+            name:     "tuic",
+            outbound: `{"type": "tuic", "tag": "tu", "server": "tu.example.com", "server_port": 443, "uuid": "u", "password": "p", "congestion_control": "bbr", "udp_relay_mode": "native", "zero_rtt_handshake": true}`,
+            want:     map[string]interface{}{"name": "tu", "type": "tuic", "server": "tu.example.com", "port": 443, "uuid": "u", "password": "p", "congestion-controller": "bbr", "udp-relay-mode": "native", "reduce-rtt": true},
+        },
+        {
+            name:     "empty tag",
+            outbound: `{"type": "trojan", "server": "tj.example.com", "server_port": 443, "password": "p"}`,
+            want:     map[string]interface{}{"name": "tj.example.com:443", "type": "trojan", "server": "tj.example.com", "port": 443, "password": "p"},
+        },
+        {
+            name:     "non-proxy outbound",
+            outbound: `{"type": "selector", "tag": "select", "outbounds": ["ss"]}`,
+        },
+        {
+            name:     "missing server",
+            outbound: `{"type": "trojan", "tag": "tj", "server_port": 443, "password": "p"}`,
+            wantErr:  true,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := singBoxOutboundToProxy(decodeOutbound(t, tt.outbound))
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("singBoxOutboundToProxy() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("singBoxOutboundToProxy() = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestXrayOutboundToProxy(t *testing.T) {
+    tests := []struct {
+        name     string
+        outbound string
+        want     map[string]interface{}
+        wantErr  bool
+    }{
+        {
+            name:     "vmess ws tls",
+            outbound: `{"protocol": "vmess", "tag": "vm", "settings": {"vnext": [{"address": "vm.example.com", "port": 443, "users": [{"id": "u", "alterId": 0, "security": "aes-128-gcm"}]}]}, "streamSettings": {"network": "ws", "security": "tls", "tlsSettings": {"serverName": "sni.example.com"}, "wsSettings": {"path": "/ws", "headers": {"Host": "cdn.example.com"}}}}`,
+            want: map[string]interface{}{"name": "vm", "type": "vmess", "server": "vm.example.com", "port": 443, "uuid": "u", "alterId": 0, "cipher": "aes-128-gcm",
+                "tls": true, "servername": "sni.example.com",
+                "network": "ws", "ws-opts": map[string]interface{}{"path": "/ws", "headers": map[string]interface{}{"Host": "cdn.example.com"}}},
+        },
+        {
+            name:     "vless reality named proxy",
+            outbound: `{"protocol": "vless", "tag": "proxy", "settings": {"vnext": [{"address": "vl.example.com", "port": 443, "users": [{"id": "u", "flow": "xtls-rprx-vision"}]}]}, "streamSettings": {"network": "tcp", "security": "reality", "realitySettings": {"serverName": "www.example.com", "fingerprint": "chrome", "publicKey": "pk", "shortId": "sid"}}}`,
+            want: map[string]interface{}{"name": "vl.example.com:443", "type": "vless", "server": "vl.example.com", "port": 443, "uuid": "u", "flow": "xtls-rprx-vision",
+                "tls": true, "servername": "www.example.com", "client-fingerprint": "chrome",
+                "reality-opts": map[string]interface{}{"public-key": "pk", "short-id": "sid"}},
+        },
+        {
+            name:     "trojan grpc",
+            outbound: `{"protocol": "trojan", "tag": "tj", "settings": {"servers": [{"address": "tj.example.com", "port": 443, "password": "p"}]}, "streamSettings": {"network": "grpc", "security": "tls", "tlsSettings": {"serverName": "sni.example.com", "allowInsecure": true}, "grpcSettings": {"serviceName": "svc"}}}`,
+            want: map[string]interface{}{"name": "tj", "type": "trojan", "server": "tj.example.com", "port": 443, "password": "p", "sni": "sni.example.com", "skip-cert-verify": true,
+                "network": "grpc", "grpc-opts": map[string]interface{}{"grpc-service-name": "svc"}},
+        },
+        {
+            name:     "shadowsocks",
+            outbound: `{"protocol": "shadowsocks", "tag": "ss", "settings": {"servers": [{"address": "ss.example.com", "port": 8388, "method": "aes-128-gcm", "password": "p"}]}}`,
+            want:     map[string]interface{}{"name": "ss", "type": "ss", "server": "ss.example.com", "port": 8388, "cipher": "aes-128-gcm", "password": "p"},
+        },
+        {
+            name:     "non-proxy outbound",
+            outbound: `{"protocol": "freedom", "tag": "direct"}`,
+        },
+        {
+            name:     "missing vnext",
+            outbound: `{"protocol": "vmess", "tag": "vm", "settings": {}}`,
+            wantErr:  true,
+        },
+        {
+            name:     "missing port",
+            outbound: `{"protocol": "trojan", "tag": "tj", "settings": {"servers": [{"address": "tj.example.com", "password": "p"}]}}`,
+            wantErr:  true,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := xrayOutboundToProxy(decodeOutbound(t, tt.outbound))
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("xrayOutboundToProxy() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("xrayOutboundToProxy() = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestParseJSONSubscription(t *testing.T) {
+    singBox := `{"outbounds": [
+        {"type": "shadowsocks", "tag": "ss", "server": "ss.example.com", "server_port": 8388, "method": "aes-128-gcm", "password": "p", "udp_over_tcp": {"enabled": true, "version": 2}},
+        {"type": "trojan", "tag": "broken", "server_port": 443, "password": "p"},
+        {"type": "direct", "tag": "direct"}
+    ]}`
+    xray := `{"outbounds": [
+        {"protocol": "trojan", "tag": "proxy", "settings": {"servers": [{"address": "tj.example.com", "port": 443, "password": "p"}]}},
+        {"protocol": "freedom", "tag": "direct"}
+    ]}`
+    clash := `{"proxies": [{"name": "vm", "type": "vmess", "server": "vm.example.com", "port": 443, "uuid": "u", "alterId": 0, "cipher": "auto"}]}`
+
+    tests := []struct {
+        name    string
+        content string
+        want    []string
+        wantErr bool
+    }{
+        {name: "sing-box", content: singBox, want: []string{"ss ss ss.example.com:8388"}},
+        {name: "xray", content: xray, want: []string{"trojan tj.example.com:443 tj.example.com:443"}},
+        {name: "clash json", content: clash, want: []string{"vmess vm vm.example.com:443"}},
+        {
+            name:    "array of configs",
+            content: "[" + singBox + "," + xray + `, {"log": {}}` + "]",
+            want:    []string{"ss ss ss.example.com:8388", "trojan tj.example.com:443 tj.example.com:443"},
+        },
+        {name: "empty array", content: "[]", want: []string{}},
+        {name: "unsupported", content: `{"log": {"level": "info"}}`, wantErr: true},
+        {name: "invalid", content: `{"outbounds": [`, wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := detectSubscriptionType(tt.content); got != TypeJSON {
+                t.Fatalf("detectSubscriptionType() = %v, want %v", got, TypeJSON)
+            }
+            nodes, err := parseJSONSubscription(tt.content)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("parseJSONSubscription() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            if tt.wantErr {
+                return
+            }
+            got := make([]string, 0, len(nodes))
+            for _, node := range nodes {
+                got = append(got, fmt.Sprintf("%s %s %s:%d", node.Type, node.Alias, node.Address, node.Port))
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("nodes = %v, want %v", got, tt.want)
+            }
+        })
+    }
+
+    // udp_over_tcp 对象形式的版本号保留在节点参数中
+    nodes, err := parseJSONSubscription(singBox)
+    if err != nil || len(nodes) == 0 {
+        t.Fatalf("parseJSONSubscription() = %v, %v", nodes, err)
+    }
+    if got := nodes[0].Params["udp-over-tcp-version"]; got != 2 {
+        t.Errorf("udp-over-tcp-version = %v, want 2", got)
+    }
+}
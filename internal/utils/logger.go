@@ -38,16 +38,36 @@ var (
 )
 
 func init() {
+    // 在加载配置前先输出到标准错误
+    logger = log.New(os.Stderr, "", log.Ldate|log.Ltime)
+    logEntries = make([]LogEntry, 0)
+}
+
+// InitLogger 按配置初始化日志文件，需在加载配置后调用
+func InitLogger() error {
+    logPath := config.Get().Log.Path
+    if logPath == "" {
+        logPath = filepath.Join(config.Get().Storage.Path, "subsmanager.log")
+    }
+    if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+        return err
+    }
+
     // 创建日志文件
-    logPath := filepath.Join(config.GlobalConfig.Storage.Path, "subsmanager.log")
     logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
     if err != nil {
-        log.Fatal(err)
+        return err
     }
 
+    logEntriesMux.Lock()
+    defer logEntriesMux.Unlock()
+
     // 设置日志格式
     logger = log.New(logFile, "", log.Ldate|log.Ltime)
-    logEntries = make([]LogEntry, 0)
+    if config.Get().Log.MaxEntries > 0 {
+        maxLogEntries = config.Get().Log.MaxEntries
+    }
+    return nil
 }
 
 // addLogEntry 添加日志条目到内存
@@ -63,7 +83,7 @@ func addLogEntry(level LogLevel, message string, details string) {
     defer logEntriesMux.Unlock()
 
     // 如果超过最大数量，移除最旧的日志
-    if len(logEntries) >= maxLogEntries {
+    for len(logEntries) >= maxLogEntries {
         logEntries = logEntries[1:]
     }
     logEntries = append(logEntries, entry)
@@ -105,10 +125,10 @@ func LogSubscriptionDelete(subscriptionName string) {
 }
 
 // LogSpeedTest 记录节点测速日志
-func LogSpeedTest(total, latencyTested, latencyDropped, speedTested int) {
+func LogSpeedTest(total, latencyTested, latencyDropped, speedTested, failed, budgetSkipped int, bytesConsumed int64) {
     msg := fmt.Sprintf("测速完成")
-    details := fmt.Sprintf("共计%d个节点，延迟测速%d个节点，延迟测速丢弃%d个节点，下载测速%d个节点",
-        total, latencyTested, latencyDropped, speedTested)
+    details := fmt.Sprintf("共计%d个节点，延迟测速%d个节点，延迟测速丢弃%d个节点，下载测速%d个节点，测试失败%d个节点，流量预算不足跳过%d个节点，消耗流量%s",
+        total, latencyTested, latencyDropped, speedTested, failed, budgetSkipped, FormatByteSize(bytesConsumed))
     addLogEntry(SUCCESS, msg, details)
 }
 
@@ -0,0 +1,128 @@
+package utils
+
+import (
+    "regexp"
+    "strings"
+)
+
+// Region 地区信息
+type Region struct {
+    Code     string   // ISO 3166-1 二位代码
+    Name     string   // 中文名称
+    Keywords []string // 别名关键字
+}
+
+// 地区关键字表
+var regions = []Region{
+    {Code: "HK", Name: "香港", Keywords: []string{"香港", "港", "hong kong", "hongkong"}},
+    {Code: "TW", Name: "台湾", Keywords: []string{"台湾", "臺灣", "台北", "新北", "彰化", "taiwan", "taipei"}},
+    {Code: "MO", Name: "澳门", Keywords: []string{"澳门", "macao", "macau"}},
+    {Code: "JP", Name: "日本", Keywords: []string{"日本", "东京", "東京", "大阪", "埼玉", "japan", "tokyo", "osaka"}},
+    {Code: "KR", Name: "韩国", Keywords: []string{"韩国", "韓國", "首尔", "春川", "korea", "seoul"}},
+    {Code: "SG", Name: "新加坡", Keywords: []string{"新加坡", "狮城", "singapore"}},
+    {Code: "US", Name: "美国", Keywords: []string{"美国", "美國", "洛杉矶", "圣何塞", "硅谷", "西雅图", "芝加哥", "纽约", "达拉斯", "凤凰城", "united states", "los angeles", "san jose", "seattle", "chicago", "new york", "dallas"}},
+    {Code: "GB", Name: "英国", Keywords: []string{"英国", "伦敦", "united kingdom", "london"}},
+    {Code: "DE", Name: "德国", Keywords: []string{"德国", "法兰克福", "germany", "frankfurt"}},
+    {Code: "FR", Name: "法国", Keywords: []string{"法国", "巴黎", "france", "paris"}},
+    {Code: "NL", Name: "荷兰", Keywords: []string{"荷兰", "阿姆斯特丹", "netherlands", "amsterdam"}},
+    {Code: "CA", Name: "加拿大", Keywords: []string{"加拿大", "多伦多", "温哥华", "canada", "toronto", "vancouver"}},
+    {Code: "AU", Name: "澳大利亚", Keywords: []string{"澳大利亚", "澳洲", "悉尼", "australia", "sydney"}},
+    {Code: "RU", Name: "俄罗斯", Keywords: []string{"俄罗斯", "莫斯科", "russia", "moscow"}},
+    {Code: "IN", Name: "印度", Keywords: []string{"印度", "孟买", "india", "mumbai"}},
+    {Code: "TR", Name: "土耳其", Keywords: []string{"土耳其", "伊斯坦布尔", "turkey", "istanbul"}},
+    {Code: "MY", Name: "马来西亚", Keywords: []string{"马来西亚", "吉隆坡", "malaysia"}},
+    {Code: "TH", Name: "泰国", Keywords: []string{"泰国", "曼谷", "thailand", "bangkok"}},
+    {Code: "VN", Name: "越南", Keywords: []string{"越南", "vietnam"}},
+    {Code: "PH", Name: "菲律宾", Keywords: []string{"菲律宾", "philippines"}},
+    {Code: "ID", Name: "印尼", Keywords: []string{"印尼", "印度尼西亚", "雅加达", "indonesia", "jakarta"}},
+    {Code: "AR", Name: "阿根廷", Keywords: []string{"阿根廷", "argentina"}},
+    {Code: "BR", Name: "巴西", Keywords: []string{"巴西", "brazil"}},
+}
+
+// 二位大写代码匹配，要求前后不是字母
+var regionCodePattern = regexp.MustCompile(`(?:^|[^A-Za-z])([A-Z]{2})(?:[^A-Za-z]|$)`)
+
+// DetectRegion 根据节点别名识别地区，返回二位代码，无法识别时返回空字符串
+func DetectRegion(alias string) string {
+    // 优先识别旗帜emoji
+    if code := flagToCode(alias); code != "" {
+        return code
+    }
+
+    // 取别名中最早出现的关键字，位置相同时取最长的关键字，如 "印度尼西亚" 优先于 "印度"
+    lower := strings.ToLower(alias)
+    best, bestPos, bestLen := "", -1, 0
+    for _, r := range regions {
+        for _, k := range r.Keywords {
+            pos := strings.Index(lower, k)
+            if pos < 0 {
+                continue
+            }
+            if bestPos < 0 || pos < bestPos || (pos == bestPos && len(k) > bestLen) {
+                best, bestPos, bestLen = r.Code, pos, len(k)
+            }
+        }
+    }
+    if best != "" {
+        return best
+    }
+
+    // 最后尝试二位代码，如 "HK 01"、"US-LA"
+    for _, m := range regionCodePattern.FindAllStringSubmatch(alias, -1) {
+        code := m[1]
+        if code == "UK" {
+            code = "GB"
+        }
+        if RegionName(code) != "" {
+            return code
+        }
+    }
+
+    return ""
+}
+
+// RegionName 获取地区中文名称
+func RegionName(code string) string {
+    for _, r := range regions {
+        if r.Code == code {
+            return r.Name
+        }
+    }
+    return ""
+}
+
+// RegionFlag 获取地区旗帜emoji
+func RegionFlag(code string) string {
+    if len(code) != 2 {
+        return ""
+    }
+    code = strings.ToUpper(code)
+    return string([]rune{
+        rune(0x1F1E6 + int(code[0]-'A')),
+        rune(0x1F1E6 + int(code[1]-'A')),
+    })
+}
+
+// HasRegionFlag 判断字符串中是否已包含旗帜emoji
+func HasRegionFlag(s string) bool {
+    return flagToCode(s) != ""
+}
+
+// flagToCode 从字符串中提取第一个旗帜emoji对应的二位代码
+func flagToCode(s string) string {
+    runes := []rune(s)
+    for i := 0; i+1 < len(runes); i++ {
+        if isRegionalIndicator(runes[i]) && isRegionalIndicator(runes[i+1]) {
+            return string([]rune{
+                'A' + (runes[i] - 0x1F1E6),
+                'A' + (runes[i+1] - 0x1F1E6),
+            })
+        }
+    }
+    return ""
+}
+
+// isRegionalIndicator 判断是否为区域指示符号
+func isRegionalIndicator(r rune) bool {
+    return r >= 0x1F1E6 && r <= 0x1F1FF
+}
@@ -0,0 +1,17 @@
+package utils
+
+import "fmt"
+
+// FormatByteSize 将字节数格式化为易读的大小
+func FormatByteSize(n int64) string {
+    switch {
+    case n >= 1<<30:
+        return fmt.Sprintf("%.2fGB", float64(n)/(1<<30))
+    case n >= 1<<20:
+        return fmt.Sprintf("%.2fMB", float64(n)/(1<<20))
+    case n >= 1<<10:
+        return fmt.Sprintf("%.2fKB", float64(n)/(1<<10))
+    default:
+        return fmt.Sprintf("%dB", n)
+    }
+}
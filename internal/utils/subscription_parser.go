@@ -1,13 +1,17 @@
 package utils
 
 import (
+    "context"
     "encoding/base64"
     "encoding/json"
     "fmt"
     "io"
     "net/http"
     "net/url"
+    "os"
+    "sort"
     "strings"
+    "subsmanager/config"
     "subsmanager/internal/models"
     "time"
 
@@ -22,6 +26,7 @@ const (
     TypeBase64  SubscriptionType = "base64"
     TypeYAML    SubscriptionType = "yaml"
     TypeJSON    SubscriptionType = "json"
+    TypeURIList SubscriptionType = "uri_list" // 未编码的分享链接列表，每行一个
 )
 
 // NodeType 节点类型
@@ -30,6 +35,8 @@ const (
     NodeTypeSS         = "ss"
     NodeTypeHysteria2  = "hysteria2"
     NodeTypeTrojan     = "trojan"
+    NodeTypeVless      = "vless"
+    NodeTypeTUIC       = "tuic"
 )
 
 // ParseStats 解析统计
@@ -44,30 +51,58 @@ type SubscriptionParseResult struct {
     Type      SubscriptionType
     NodeCount int
     Nodes     []*models.Node
+    Info      *models.SubscriptionInfo // 从信息节点提取的订阅信息
     Stats     ParseStats
 }
 
 // OpenClashConfig OpenClash配置结构
 type OpenClashConfig struct {
-    Proxies []map[string]interface{} `yaml:"proxies"`
+    Proxies        []map[string]interface{} `yaml:"proxies"`
+    ProxyProviders map[string]ProxyProvider `yaml:"proxy-providers"`
+}
+
+// ProxyProvider Clash配置中的代理集合
+type ProxyProvider struct {
+    Type    string                   `yaml:"type"`    // http/file/inline
+    URL     string                   `yaml:"url"`     // http类型的订阅地址
+    Path    string                   `yaml:"path"`    // file类型的文件路径，只允许导入目录中的文件
+    Payload []map[string]interface{} `yaml:"payload"` // inline类型的节点列表
+}
+
+// FetchOptions 获取订阅时的请求选项
+type FetchOptions struct {
+    UserAgent string            // 为空时使用配置的UA
+    Headers   map[string]string // 额外的请求头，可覆盖UA
 }
 
 // ParseSubscription 解析订阅链接
-func ParseSubscription(url string) (*SubscriptionParseResult, error) {
+func ParseSubscription(url string, opts FetchOptions) (*SubscriptionParseResult, error) {
+    return ParseSubscriptionContext(context.Background(), url, opts)
+}
+
+// ParseSubscriptionContext 解析订阅链接，ctx 取消后中止获取
+func ParseSubscriptionContext(ctx context.Context, url string, opts FetchOptions) (*SubscriptionParseResult, error) {
     // 获取订阅内容
-    content, err := fetchSubscriptionContent(url)
+    content, err := fetchSubscriptionContent(ctx, url, opts)
     if err != nil {
         return nil, fmt.Errorf("fetch subscription content failed: %v", err)
     }
+    return ParseSubscriptionContent(content)
+}
 
+// ParseSubscriptionContent 识别并解析订阅内容，支持Base64、分享链接列表、YAML和JSON格式
+func ParseSubscriptionContent(content string) (*SubscriptionParseResult, error) {
     // 识别订阅类型
     subType := detectSubscriptionType(content)
 
     // 根据类型解析节点
     var nodes []*models.Node
+    var err error
     switch subType {
     case TypeBase64:
         nodes, err = parseBase64Subscription(content)
+    case TypeURIList:
+        nodes, err = parseURIList(content, "uri_list")
     case TypeYAML:
         nodes, err = parseYAMLSubscription(content)
     case TypeJSON:
@@ -80,21 +115,50 @@ func ParseSubscription(url string) (*SubscriptionParseResult, error) {
         return nil, fmt.Errorf("parse subscription failed: %v", err)
     }
 
+    // 剔除流量、到期等信息节点
+    nodes, info := ExtractInfoNodes(nodes)
+
+    // 识别节点地区
+    for _, node := range nodes {
+        node.Region = DetectNodeRegion(node.Alias, node.Address)
+    }
+
     return &SubscriptionParseResult{
         Type:      subType,
         NodeCount: len(nodes),
         Nodes:     nodes,
+        Info:      info,
     }, nil
 }
 
 // fetchSubscriptionContent 获取订阅内容
-func fetchSubscriptionContent(url string) (string, error) {
-    resp, err := http.Get(url)
+func fetchSubscriptionContent(ctx context.Context, url string, opts FetchOptions) (string, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+    if err != nil {
+        return "", err
+    }
+    userAgent := opts.UserAgent
+    if userAgent == "" {
+        userAgent = config.Get().Fetch.UserAgent
+    }
+    req.Header.Set("User-Agent", userAgent)
+    for k, v := range opts.Headers {
+        req.Header.Set(k, v)
+    }
+
+    client := &http.Client{
+        Timeout: config.Duration(config.Get().Fetch.Timeout, 30*time.Second),
+    }
+    resp, err := client.Do(req)
     if err != nil {
         return "", err
     }
     defer resp.Body.Close()
 
+    if resp.StatusCode != http.StatusOK {
+        return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+    }
+
     body, err := io.ReadAll(resp.Body)
     if err != nil {
         return "", err
@@ -111,13 +175,18 @@ func detectSubscriptionType(content string) SubscriptionType {
         return TypeBase64
     }
 
+    // 检查是否是未编码的分享链接列表
+    if strings.Contains(content, "://") && isValidBase64NodeList(content) {
+        return TypeURIList
+    }
+
     // 检查是否是YAML格式
-    if strings.Contains(content, "proxies:") {
+    if strings.Contains(content, "proxies:") || strings.Contains(content, "proxy-providers:") {
         return TypeYAML
     }
 
-    // 检查是否是JSON格式
-    if strings.HasPrefix(strings.TrimSpace(content), "{") {
+    // 检查是否是JSON格式，数组形式为多个完整配置
+    if trimmed := strings.TrimSpace(content); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
         return TypeJSON
     }
 
@@ -150,9 +219,13 @@ func parseBase64Subscription(content string) ([]*models.Node, error) {
     if err != nil {
         return nil, fmt.Errorf("base64 decode failed: %v", err)
     }
+    return parseURIList(string(decoded), "base64")
+}
 
+// parseURIList 解析分享链接列表，每行一个节点，source 用于日志区分来源格式
+func parseURIList(content, source string) ([]*models.Node, error) {
     // 分割成单个节点
-    lines := strings.Split(string(decoded), "\n")
+    lines := strings.Split(content, "\n")
     nodes := make([]*models.Node, 0)
     stats := ParseStats{
         Total:   0,
@@ -175,25 +248,25 @@ func parseBase64Subscription(content string) ([]*models.Node, error) {
             node, err = parseVmessNode(line)
             if err != nil {
                 stats.Failed[NodeTypeVmess]++
-                LogParseError("base64", NodeTypeVmess, err)
+                LogParseError(source, NodeTypeVmess, err)
             }
         case strings.HasPrefix(strings.ToLower(line), "ss://"):
             node, err = parseSSNode(line)
             if err != nil {
                 stats.Failed[NodeTypeSS]++
-                LogParseError("base64", NodeTypeSS, err)
+                LogParseError(source, NodeTypeSS, err)
             }
         case strings.HasPrefix(strings.ToLower(line), "hysteria2://"):
             node, err = parseHysteria2Node(line)
             if err != nil {
                 stats.Failed[NodeTypeHysteria2]++
-                LogParseError("base64", NodeTypeHysteria2, err)
+                LogParseError(source, NodeTypeHysteria2, err)
             }
         case strings.HasPrefix(strings.ToLower(line), "trojan://"):
             node, err = parseTrojanNode(line)
             if err != nil {
                 stats.Failed[NodeTypeTrojan]++
-                LogParseError("base64", NodeTypeTrojan, err)
+                LogParseError(source, NodeTypeTrojan, err)
             }
         }
 
@@ -207,8 +280,8 @@ func parseBase64Subscription(content string) ([]*models.Node, error) {
         }
     }
 
-    LogInfo("Base64 subscription parse stats: Total=%d, Success=%d, Failed=%v", 
-        stats.Total, stats.Success, stats.Failed)
+    LogInfo("%s subscription parse stats: Total=%d, Success=%d, Failed=%v",
+        source, stats.Total, stats.Success, stats.Failed)
 
     return nodes, nil
 }
@@ -226,24 +299,59 @@ func parseVmessNode(line string) (*models.Node, error) {
 
     // 解析JSON
     var vmessInfo struct {
-        Add  string `json:"add"`
-        Port int    `json:"port"`
-        ID   string `json:"id"`
-        Net  string `json:"net"`
-        Type string `json:"type"`
-        PS   string `json:"ps"`
+        Add  string      `json:"add"`
+        Port int         `json:"port"`
+        ID   string      `json:"id"`
+        Aid  interface{} `json:"aid"`
+        Scy  string      `json:"scy"`
+        Net  string      `json:"net"`
+        Type string      `json:"type"`
+        Host string      `json:"host"`
+        Path string      `json:"path"`
+        TLS  string      `json:"tls"`
+        SNI  string      `json:"sni"`
+        PS   string      `json:"ps"`
     }
 
     if err := json.Unmarshal(decoded, &vmessInfo); err != nil {
         return nil, err
     }
 
+    params := map[string]interface{}{
+        "uuid":    vmessInfo.ID,
+        "alterId": vmessInfo.Aid,
+        "cipher":  "auto",
+    }
+    if vmessInfo.Aid == nil {
+        params["alterId"] = 0
+    }
+    if vmessInfo.Scy != "" {
+        params["cipher"] = vmessInfo.Scy
+    }
+    if vmessInfo.Net != "" {
+        params["network"] = vmessInfo.Net
+    }
+    if vmessInfo.TLS == "tls" {
+        params["tls"] = true
+        if vmessInfo.SNI != "" {
+            params["servername"] = vmessInfo.SNI
+        }
+    }
+    if vmessInfo.Net == "ws" {
+        wsOpts := map[string]interface{}{"path": vmessInfo.Path}
+        if vmessInfo.Host != "" {
+            wsOpts["headers"] = map[string]interface{}{"Host": vmessInfo.Host}
+        }
+        params["ws-opts"] = wsOpts
+    }
+
     return &models.Node{
         Type:         "vmess",
         Alias:        vmessInfo.PS,
         Address:      vmessInfo.Add,
         Port:         vmessInfo.Port,
         Protocol:     vmessInfo.Net,
+        Params:       params,
         LastTestedAt: time.Time{},
     }, nil
 }
@@ -286,12 +394,19 @@ func parseSSNode(line string) (*models.Node, error) {
     port := 0
     fmt.Sscanf(addrParts[1], "%d", &port)
 
+    // 解析加密方式和密码
+    method := strings.SplitN(config[0], ":", 2)
+    if len(method) != 2 {
+        return nil, fmt.Errorf("invalid ss method format")
+    }
+
     return &models.Node{
         Type:         "ss",
         Alias:        alias,
         Address:      addrParts[0],
         Port:         port,
         Protocol:     "shadowsocks",
+        Params:       map[string]interface{}{"cipher": method[0], "password": method[1]},
         LastTestedAt: time.Time{},
     }, nil
 }
@@ -315,12 +430,21 @@ func parseHysteria2Node(line string) (*models.Node, error) {
     portNum := 0
     fmt.Sscanf(port, "%d", &portNum)
 
+    params := map[string]interface{}{"password": u.User.Username()}
+    if sni := u.Query().Get("sni"); sni != "" {
+        params["sni"] = sni
+    }
+    if u.Query().Get("insecure") == "1" {
+        params["skip-cert-verify"] = true
+    }
+
     return &models.Node{
         Type:         NodeTypeHysteria2,
         Alias:        u.Fragment,
         Address:      u.Hostname(),
         Port:         portNum,
         Protocol:     "hysteria2",
+        Params:       params,
         LastTestedAt: time.Time{},
     }, nil
 }
@@ -344,31 +468,116 @@ func parseTrojanNode(line string) (*models.Node, error) {
     portNum := 0
     fmt.Sscanf(port, "%d", &portNum)
 
+    params := map[string]interface{}{"password": u.User.Username()}
+    if sni := u.Query().Get("sni"); sni != "" {
+        params["sni"] = sni
+    }
+    if u.Query().Get("allowInsecure") == "1" {
+        params["skip-cert-verify"] = true
+    }
+    if network := u.Query().Get("type"); network != "" && network != "tcp" {
+        params["network"] = network
+    }
+
     return &models.Node{
         Type:         NodeTypeTrojan,
         Alias:        u.Fragment,
         Address:      u.Hostname(),
         Port:         portNum,
         Protocol:     "trojan",
+        Params:       params,
         LastTestedAt: time.Time{},
     }, nil
 }
 
-// parseYAMLSubscription 解析YAML格式的订阅
+// parseYAMLSubscription 解析YAML格式的订阅，包括 proxy-providers 中的节点
 func parseYAMLSubscription(content string) ([]*models.Node, error) {
     var config OpenClashConfig
     if err := yaml.Unmarshal([]byte(content), &config); err != nil {
         return nil, fmt.Errorf("yaml unmarshal failed: %v", err)
     }
 
+    nodes := parseYAMLProxies(config.Proxies)
+
+    // 按名称顺序解析代理集合，单个集合失败不影响其他节点
+    names := make([]string, 0, len(config.ProxyProviders))
+    for name := range config.ProxyProviders {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        providerNodes, err := parseProxyProvider(config.ProxyProviders[name])
+        if err != nil {
+            LogParseError("proxy-provider", name, err)
+            continue
+        }
+        for _, node := range providerNodes {
+            node.Group = name
+        }
+        nodes = append(nodes, providerNodes...)
+    }
+
+    return nodes, nil
+}
+
+// parseProxyProvider 获取并解析代理集合中的节点
+//
+// http类型通过订阅获取逻辑下载，file类型只读取导入目录中的文件；
+// 集合内容可以是YAML、Base64或分享链接列表，集合中嵌套的 proxy-providers 不再解析。
+func parseProxyProvider(provider ProxyProvider) ([]*models.Node, error) {
+    var content string
+    switch provider.Type {
+    case "http":
+        if provider.URL == "" {
+            return nil, fmt.Errorf("missing url")
+        }
+        body, err := fetchSubscriptionContent(context.Background(), provider.URL, FetchOptions{})
+        if err != nil {
+            return nil, fmt.Errorf("fetch provider failed: %v", err)
+        }
+        content = body
+    case "file":
+        path, err := ResolveImportPath(provider.Path)
+        if err != nil {
+            return nil, err
+        }
+        data, err := os.ReadFile(path)
+        if err != nil {
+            return nil, fmt.Errorf("read provider file failed: %v", err)
+        }
+        content = string(data)
+    case "inline":
+        return parseYAMLProxies(provider.Payload), nil
+    default:
+        return nil, fmt.Errorf("unsupported provider type: %s", provider.Type)
+    }
+
+    switch detectSubscriptionType(content) {
+    case TypeBase64:
+        return parseBase64Subscription(content)
+    case TypeURIList:
+        return parseURIList(content, "proxy-provider")
+    case TypeYAML:
+        var config OpenClashConfig
+        if err := yaml.Unmarshal([]byte(content), &config); err != nil {
+            return nil, fmt.Errorf("yaml unmarshal failed: %v", err)
+        }
+        return parseYAMLProxies(config.Proxies), nil
+    default:
+        return nil, fmt.Errorf("unsupported provider content")
+    }
+}
+
+// parseYAMLProxies 解析Clash配置中的节点列表
+func parseYAMLProxies(proxies []map[string]interface{}) []*models.Node {
     nodes := make([]*models.Node, 0)
     stats := ParseStats{
-        Total:   len(config.Proxies),
+        Total:   len(proxies),
         Success: 0,
         Failed:  make(map[string]int),
     }
 
-    for _, proxy := range config.Proxies {
+    for _, proxy := range proxies {
         nodeType, ok := proxy["type"].(string)
         if !ok {
             continue
@@ -386,6 +595,10 @@ func parseYAMLSubscription(content string) ([]*models.Node, error) {
             node, err = parseYAMLHysteria2Node(proxy)
         case NodeTypeTrojan:
             node, err = parseYAMLTrojanNode(proxy)
+        case NodeTypeVless:
+            node, err = parseYAMLVlessNode(proxy)
+        case NodeTypeTUIC:
+            node, err = parseYAMLTUICNode(proxy)
         default:
             continue
         }
@@ -405,7 +618,7 @@ func parseYAMLSubscription(content string) ([]*models.Node, error) {
     LogInfo("YAML subscription parse stats: Total=%d, Success=%d, Failed=%v",
         stats.Total, stats.Success, stats.Failed)
 
-    return nodes, nil
+    return nodes
 }
 
 // parseYAMLVmessNode 解析YAML格式的Vmess节点
@@ -421,6 +634,7 @@ func parseYAMLVmessNode(proxy map[string]interface{}) (*models.Node, error) {
         Address:      server,
         Port:         port,
         Protocol:     network,
+        Params:       ProxyParams(proxy),
         LastTestedAt: time.Time{},
     }, nil
 }
@@ -437,6 +651,7 @@ func parseYAMLSSNode(proxy map[string]interface{}) (*models.Node, error) {
         Address:      server,
         Port:         port,
         Protocol:     "shadowsocks",
+        Params:       ProxyParams(proxy),
         LastTestedAt: time.Time{},
     }, nil
 }
@@ -453,6 +668,7 @@ func parseYAMLHysteria2Node(proxy map[string]interface{}) (*models.Node, error)
         Address:      server,
         Port:         port,
         Protocol:     "hysteria2",
+        Params:       ProxyParams(proxy),
         LastTestedAt: time.Time{},
     }, nil
 }
@@ -469,17 +685,55 @@ func parseYAMLTrojanNode(proxy map[string]interface{}) (*models.Node, error) {
         Address:      server,
         Port:         port,
         Protocol:     "trojan",
+        Params:       ProxyParams(proxy),
         LastTestedAt: time.Time{},
     }, nil
 }
 
-// parseJSONSubscription 解析JSON格式的订阅
-func parseJSONSubscription(content string) ([]*models.Node, error) {
-    // 尝试解析为OpenClash格式的JSON
-    var config OpenClashConfig
-    if err := json.Unmarshal([]byte(content), &config); err == nil {
-        return parseYAMLSubscription(content)
-    }
+// parseYAMLVlessNode 解析YAML格式的VLESS节点
+func parseYAMLVlessNode(proxy map[string]interface{}) (*models.Node, error) {
+    name, _ := proxy["name"].(string)
+    server, _ := proxy["server"].(string)
+    port, _ := proxy["port"].(int)
+    network, _ := proxy["network"].(string)
 
-    return nil, fmt.Errorf("unsupported json format")
-} 
\ No newline at end of file
+    return &models.Node{
+        Type:         NodeTypeVless,
+        Alias:        name,
+        Address:      server,
+        Port:         port,
+        Protocol:     network,
+        Params:       ProxyParams(proxy),
+        LastTestedAt: time.Time{},
+    }, nil
+}
+
+// parseYAMLTUICNode 解析YAML格式的TUIC节点
+func parseYAMLTUICNode(proxy map[string]interface{}) (*models.Node, error) {
+    name, _ := proxy["name"].(string)
+    server, _ := proxy["server"].(string)
+    port, _ := proxy["port"].(int)
+
+    return &models.Node{
+        Type:         NodeTypeTUIC,
+        Alias:        name,
+        Address:      server,
+        Port:         port,
+        Protocol:     "tuic",
+        Params:       ProxyParams(proxy),
+        LastTestedAt: time.Time{},
+    }, nil
+}
+
+// ProxyParams 提取OpenClash代理配置中除名称、类型、地址、端口外的参数
+func ProxyParams(proxy map[string]interface{}) map[string]interface{} {
+    params := make(map[string]interface{}, len(proxy))
+    for k, v := range proxy {
+        switch k {
+        case "name", "type", "server", "port":
+            continue
+        }
+        params[k] = v
+    }
+    return params
+}
\ No newline at end of file
@@ -0,0 +1,142 @@
+package utils
+
+import (
+    "encoding/base64"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "reflect"
+    "subsmanager/config"
+    "subsmanager/internal/models"
+    "testing"
+)
+
+// nodeLabels 以 "分组/名称" 的形式列出节点
+func nodeLabels(nodes []*models.Node) []string {
+    labels := make([]string, 0, len(nodes))
+    for _, node := range nodes {
+        labels = append(labels, node.Group+"/"+node.Alias)
+    }
+    return labels
+}
+
+func TestParseYAMLProxyProviders(t *testing.T) {
+    storage := t.TempDir()
+    t.Cleanup(config.Override(func(c *config.Config) { c.Storage.Path = storage }))
+    importDir := ImportDir()
+    if err := os.MkdirAll(importDir, 0755); err != nil {
+        t.Fatal(err)
+    }
+    files := map[string]string{
+        filepath.Join(importDir, "hk.yaml"): "proxies:\n  - {name: hk-file, type: ss, server: hk.example.com, port: 443, cipher: aes-128-gcm, password: p}\n",
+        filepath.Join(importDir, "jp.txt"):  base64.StdEncoding.EncodeToString([]byte("trojan://p@jp.example.com:443#jp-base64\n")),
+        filepath.Join(storage, "secret.yaml"): "proxies:\n  - {name: secret, type: ss, server: s.example.com, port: 443, cipher: aes-128-gcm, password: p}\n",
+    }
+    for path, content := range files {
+        if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path != "/us" {
+            http.NotFound(w, r)
+            return
+        }
+        w.Write([]byte("trojan://p@us.example.com:443#us-http\n"))
+    }))
+    defer server.Close()
+
+    tests := []struct {
+        name      string
+        providers string
+        want      []string
+    }{
+        {
+            name:      "inline",
+            providers: "  inline:\n    type: inline\n    payload:\n      - {name: sg-inline, type: ss, server: sg.example.com, port: 443, cipher: aes-128-gcm, password: p}\n",
+            want:      []string{"/direct", "inline/sg-inline"},
+        },
+        {
+            name:      "file yaml",
+            providers: "  hk:\n    type: file\n    path: hk.yaml\n",
+            want:      []string{"/direct", "hk/hk-file"},
+        },
+        {
+            name:      "file base64",
+            providers: "  jp:\n    type: file\n    path: jp.txt\n",
+            want:      []string{"/direct", "jp/jp-base64"},
+        },
+        {
+            name:      "http",
+            providers: "  us:\n    type: http\n    url: " + server.URL + "/us\n",
+            want:      []string{"/direct", "us/us-http"},
+        },
+        {
+            name:      "sorted by provider name",
+            providers: "  us:\n    type: http\n    url: " + server.URL + "/us\n  hk:\n    type: file\n    path: hk.yaml\n",
+            want:      []string{"/direct", "hk/hk-file", "us/us-http"},
+        },
+        {
+            name:      "file outside import dir",
+            providers: "  secret:\n    type: file\n    path: ../secret.yaml\n  hk:\n    type: file\n    path: hk.yaml\n",
+            want:      []string{"/direct", "hk/hk-file"},
+        },
+        {
+            name:      "absolute file path",
+            providers: "  secret:\n    type: file\n    path: " + filepath.Join(storage, "secret.yaml") + "\n",
+            want:      []string{"/direct"},
+        },
+        {
+            name:      "missing file",
+            providers: "  missing:\n    type: file\n    path: missing.yaml\n",
+            want:      []string{"/direct"},
+        },
+        {
+            name:      "http without url",
+            providers: "  us:\n    type: http\n",
+            want:      []string{"/direct"},
+        },
+        {
+            name:      "http error status",
+            providers: "  us:\n    type: http\n    url: " + server.URL + "/missing\n",
+            want:      []string{"/direct"},
+        },
+        {
+            name:      "unsupported type",
+            providers: "  ftp:\n    type: ftp\n    url: ftp://example.com/sub\n",
+            want:      []string{"/direct"},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            content := "proxies:\n  - {name: direct, type: ss, server: direct.example.com, port: 443, cipher: aes-128-gcm, password: p}\nproxy-providers:\n" + tt.providers
+            if got := detectSubscriptionType(content); got != TypeYAML {
+                t.Fatalf("detectSubscriptionType() = %v, want %v", got, TypeYAML)
+            }
+            nodes, err := parseYAMLSubscription(content)
+            if err != nil {
+                t.Fatalf("parseYAMLSubscription() error = %v", err)
+            }
+            if got := nodeLabels(nodes); !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("nodes = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestParseYAMLProvidersOnly(t *testing.T) {
+    content := "proxy-providers:\n  inline:\n    type: inline\n    payload:\n      - {name: a, type: ss, server: a.example.com, port: 443, cipher: aes-128-gcm, password: p}\n"
+    if got := detectSubscriptionType(content); got != TypeYAML {
+        t.Fatalf("detectSubscriptionType() = %v, want %v", got, TypeYAML)
+    }
+    nodes, err := parseYAMLSubscription(content)
+    if err != nil {
+        t.Fatalf("parseYAMLSubscription() error = %v", err)
+    }
+    if got := nodeLabels(nodes); !reflect.DeepEqual(got, []string{"inline/a"}) {
+        t.Errorf("nodes = %v, want [inline/a]", got)
+    }
+}
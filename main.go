@@ -1,29 +1,115 @@
 package main
 
 import (
+    "flag"
     "fmt"
     "log"
     "os"
+    "path/filepath"
     "subsmanager/api"
     "subsmanager/config"
     "subsmanager/internal/services"
+    "subsmanager/internal/utils"
+)
+
+var (
+    configPath   = flag.String("config", os.Getenv("SUBSMANAGER_CONFIG"), "配置文件路径，默认读取当前目录下的config.yaml")
+    hashPassword = flag.String("hash-password", "", "输出密码的bcrypt哈希后退出，用于配置auth.password_hash")
 )
 
 func init() {
-    // 初始化配置
-    if err := config.Init(); err != nil {
-        log.Fatalf("Failed to initialize config: %v", err)
+    flag.Parse()
+
+    if *hashPassword != "" {
+        hash, err := services.HashPassword(*hashPassword)
+        if err != nil {
+            log.Fatal(err)
+        }
+        fmt.Println(hash)
+        os.Exit(0)
+    }
+
+    // 加载配置
+    if err := config.Load(*configPath); err != nil {
+        log.Fatalf("Failed to load config: %v", err)
     }
 
     // 创建数据目录
-    if err := os.MkdirAll(config.GlobalConfig.Storage.Path, 0755); err != nil {
+    if err := os.MkdirAll(config.Get().Storage.Path, 0755); err != nil {
         log.Fatalf("Failed to create data directory: %v", err)
     }
 
+    // 创建导入目录
+    if err := os.MkdirAll(utils.ImportDir(), 0755); err != nil {
+        log.Fatalf("Failed to create import directory: %v", err)
+    }
+
+    // 初始化日志
+    if err := utils.InitLogger(); err != nil {
+        log.Fatalf("Failed to initialize logger: %v", err)
+    }
+
+    // 追加配置中的信息节点过滤规则
+    filter := config.Get().Filter
+    if err := utils.SetInfoNodeRules(
+        append(append([]string{}, utils.DefaultInfoNodeKeywords...), filter.InfoKeywords...),
+        append(append([]string{}, utils.DefaultInfoNodePatterns...), filter.InfoPatterns...),
+    ); err != nil {
+        log.Fatalf("Failed to apply info node rules: %v", err)
+    }
+
+    // 加载GeoIP数据库
+    if path := config.Get().GeoIP.MMDBPath; path != "" {
+        if err := utils.InitGeoIP(path, config.Get().GeoIP.ResolveDomains); err != nil {
+            log.Printf("Failed to load GeoIP database: %v", err)
+        }
+    }
+
     // 加载数据
     if err := services.DefaultSubscriptionService.LoadFromFile(); err != nil {
         log.Printf("Failed to load data from file: %v", err)
     }
+
+    // 加载API令牌和发布链接
+    if err := services.InitAuth(); err != nil {
+        log.Fatalf("Failed to initialize auth: %v", err)
+    }
+    if err := services.DefaultPublishService.Load(); err != nil {
+        log.Printf("Failed to load published links: %v", err)
+    }
+
+    // 加载测速目标
+    services.InitTestTargets()
+
+    // 初始化定时任务
+    if err := initScheduler(); err != nil {
+        log.Fatalf("Failed to initialize scheduler: %v", err)
+    }
+}
+
+// initScheduler 加载已保存的任务，并按配置的周期注册默认任务
+func initScheduler() error {
+    logService, err := services.NewLogService(
+        filepath.Join(config.Get().Storage.Path, "task.log"),
+        config.Get().Log.MaxEntries,
+    )
+    if err != nil {
+        return err
+    }
+
+    statusService := services.NewStatusService(services.DefaultSubscriptionService)
+    scheduler := services.NewSchedulerService(services.DefaultSubscriptionService, statusService, logService)
+    if err := scheduler.LoadTasks(); err != nil {
+        return err
+    }
+    if err := scheduler.EnsureDefaultTasks(); err != nil {
+        return err
+    }
+    scheduler.WatchConfig()
+    scheduler.Start()
+
+    services.DefaultSchedulerService = scheduler
+    return nil
 }
 
 func main() {
@@ -31,7 +117,7 @@ func main() {
     r := api.SetupRouter()
 
     // 启动服务器
-    addr := fmt.Sprintf("%s:%d", config.GlobalConfig.Server.Host, config.GlobalConfig.Server.Port)
+    addr := fmt.Sprintf("%s:%d", config.Get().Server.Host, config.Get().Server.Port)
     log.Printf("Server starting on %s", addr)
     if err := r.Run(addr); err != nil {
         log.Fatalf("Server failed to start: %v", err)